@@ -0,0 +1,101 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/gig-tech/ovc-sdk-go/v4/ovc/inventory"
+)
+
+func init() {
+	resources["inventory"] = map[string]command{
+		"export":     {"export the inventory of an account (or all accounts with -all)", inventoryExport},
+		"ansible":    {"print an Ansible dynamic inventory of a cloudspace", inventoryAnsible},
+		"ssh-config": {"print an ~/.ssh/config fragment for the machines of a cloudspace", inventorySSHConfig},
+	}
+}
+
+func inventoryExport(c *cli, args []string) error {
+	fs := c.flags("inventory", "export")
+	account := fs.String("account", "", "account name or ID")
+	all := fs.Bool("all", false, "export all accounts")
+	format := fs.String("format", "json", "export format: json or dot")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *format != "json" && *format != "dot" {
+		return fmt.Errorf("unknown export format %q", *format)
+	}
+	client, err := c.connect()
+	if err != nil {
+		return err
+	}
+
+	var inv *inventory.Inventory
+	if *all {
+		inv, err = inventory.SnapshotAll(client)
+	} else {
+		var accountID int
+		accountID, err = c.resolveAccount(*account)
+		if err != nil {
+			return err
+		}
+		inv, err = inventory.Snapshot(client, accountID)
+	}
+	if err != nil {
+		return err
+	}
+
+	if *format == "dot" {
+		return inv.WriteDOT(c.out)
+	}
+	return inv.WriteJSON(c.out)
+}
+
+func inventoryHosts(c *cli, verb string, args []string, extra func(*flag.FlagSet)) ([]*inventory.Host, error) {
+	fs := c.flags("inventory", verb)
+	cloudSpace := fs.String("cloudspace", "", "cloudspace name or ID")
+	account := fs.String("account", "", "account name or ID, to resolve the cloudspace by name")
+	sshPort := fs.Int("ssh-port", 22, "port SSH listens on inside the machines")
+	credentials := fs.Bool("credentials", false, "include the initial credentials of the machines")
+	if extra != nil {
+		extra(fs)
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	client, err := c.connect()
+	if err != nil {
+		return nil, err
+	}
+	cloudSpaceID, err := c.resolveCloudSpace(*cloudSpace, *account)
+	if err != nil {
+		return nil, err
+	}
+	return inventory.Hosts(client, cloudSpaceID, inventory.HostsOptions{
+		SSHPort:            *sshPort,
+		IncludeCredentials: *credentials,
+	})
+}
+
+func inventoryAnsible(c *cli, args []string) error {
+	hosts, err := inventoryHosts(c, "ansible", args, func(fs *flag.FlagSet) {
+		// accepted so the command can be used as an Ansible inventory script
+		fs.Bool("list", true, "print the whole inventory")
+	})
+	if err != nil {
+		return err
+	}
+	return inventory.WriteAnsibleInventory(c.out, hosts)
+}
+
+func inventorySSHConfig(c *cli, args []string) error {
+	var identityFile *string
+	hosts, err := inventoryHosts(c, "ssh-config", args, func(fs *flag.FlagSet) {
+		identityFile = fs.String("identity-file", "", "IdentityFile to add to every host")
+	})
+	if err != nil {
+		return err
+	}
+	return inventory.WriteSSHConfig(c.out, hosts, *identityFile)
+}
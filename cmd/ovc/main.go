@@ -0,0 +1,161 @@
+// Command ovc is a command-line interface to the G8 API built on top of the
+// OpenvCloud client.
+//
+// Usage:
+//
+//	ovc [global flags] <resource> <verb> [flags] [name or ID]
+//
+// Run `ovc help` for the list of resources and verbs.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/gig-tech/ovc-sdk-go/v4/ovc"
+)
+
+// command is a single verb on a resource
+type command struct {
+	description string
+	run         func(c *cli, args []string) error
+}
+
+// cli holds the state shared by all commands
+type cli struct {
+	out     io.Writer
+	output  string
+	profile *Profile
+	verbose bool
+	client  *ovc.Client
+}
+
+func main() {
+	err := run(os.Args[1:], os.Stdout)
+	if err == flag.ErrHelp {
+		return
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ovc: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("ovc", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	configFile := fs.String("config", defaultConfigFile(), "path to the profile configuration file")
+	profileName := fs.String("profile", os.Getenv("OVC_PROFILE"), "name of the profile to use")
+	output := fs.String("o", "table", "output format: table, json or yaml")
+	verbose := fs.Bool("v", false, "enable debug logging")
+	fs.Usage = func() { usage(fs) }
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	args = fs.Args()
+
+	if len(args) == 0 || args[0] == "help" {
+		usage(fs)
+		return nil
+	}
+	if len(args) < 2 {
+		return fmt.Errorf("missing verb for %s, run `ovc help` for usage", args[0])
+	}
+	switch *output {
+	case outputTable, outputJSON, outputYAML:
+	default:
+		return fmt.Errorf("unknown output format %q", *output)
+	}
+
+	verbs, ok := resources[args[0]]
+	if !ok {
+		return fmt.Errorf("unknown resource %q, run `ovc help` for usage", args[0])
+	}
+	cmd, ok := verbs[args[1]]
+	if !ok {
+		return fmt.Errorf("unknown verb %q for %s, run `ovc help` for usage", args[1], args[0])
+	}
+
+	profile, err := loadProfile(*configFile, *profileName)
+	if err != nil {
+		return err
+	}
+
+	c := &cli{
+		out:     out,
+		output:  *output,
+		profile: profile,
+		verbose: *verbose,
+	}
+	return cmd.run(c, args[2:])
+}
+
+// connect lazily creates the API client from the selected profile
+func (c *cli) connect() (*ovc.Client, error) {
+	if c.client != nil {
+		return c.client, nil
+	}
+	if c.profile.URL == "" {
+		return nil, fmt.Errorf("no G8 URL configured, set it in the profile or in OVC_URL")
+	}
+	client, err := ovc.NewClient(&ovc.Config{
+		URL:          c.profile.URL,
+		ClientID:     c.profile.ClientID,
+		ClientSecret: c.profile.ClientSecret,
+		JWT:          c.profile.JWT,
+		Verbose:      c.verbose,
+	})
+	if err != nil {
+		return nil, err
+	}
+	c.client = client
+	return client, nil
+}
+
+// flags returns a flag set for the subcommand resource verb
+func (c *cli) flags(resource string, verb string) *flag.FlagSet {
+	fs := flag.NewFlagSet("ovc "+resource+" "+verb, flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	return fs
+}
+
+func usage(fs *flag.FlagSet) {
+	w := fs.Output()
+	fmt.Fprintln(w, "Usage: ovc [global flags] <resource> <verb> [flags] [name or ID]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Global flags:")
+	fs.PrintDefaults()
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Resources and verbs:")
+
+	names := make([]string, 0, len(resources))
+	for name := range resources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		verbs := make([]string, 0, len(resources[name]))
+		for verb := range resources[name] {
+			verbs = append(verbs, verb)
+		}
+		sort.Strings(verbs)
+		fmt.Fprintf(w, "  %s\n", name)
+		for _, verb := range verbs {
+			fmt.Fprintf(w, "    %-10s %s\n", verb, resources[name][verb].description)
+		}
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Run `ovc <resource> <verb> -h` for the flags of a verb.")
+}
+
+// singleArg returns the only positional argument left after parsing fs
+func singleArg(fs *flag.FlagSet, what string) (string, error) {
+	if fs.NArg() != 1 {
+		return "", fmt.Errorf("expected exactly one %s, got %q", what, strings.Join(fs.Args(), " "))
+	}
+	return fs.Arg(0), nil
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	jwtLib "github.com/dgrijalva/jwt-go"
+	"github.com/gig-tech/ovc-sdk-go/v4/ovc"
+	"github.com/stretchr/testify/assert"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// fakeResults are the task results of the fake G8 by endpoint
+var fakeResults = map[string]interface{}{
+	"/cloudapi/accounts/list": []map[string]interface{}{{"id": 7, "name": "acme"}},
+	"/cloudapi/accounts/get":  map[string]interface{}{"id": 7, "name": "acme", "status": "CONFIRMED"},
+	"/cloudapi/cloudspaces/list": []map[string]interface{}{
+		{"id": 3, "name": "prod", "accountId": 7, "accountName": "acme", "status": "DEPLOYED", "externalnetworkip": "185.1.2.3/24"},
+	},
+	"/cloudapi/cloudspaces/get": map[string]interface{}{
+		"id": 3, "name": "prod", "accountId": 7, "status": "DEPLOYED", "externalnetworkip": "185.1.2.3/29", "privatenetwork": "192.168.103.0/24",
+	},
+	"/cloudapi/cloudspaces/create": 3,
+	"/cloudapi/cloudspaces/update": true,
+	"/cloudapi/cloudspaces/delete": true,
+	"/cloudapi/machines/list": []map[string]interface{}{
+		{"id": 10, "name": "web", "status": "RUNNING", "imageId": 1},
+	},
+	"/cloudapi/machines/get": map[string]interface{}{
+		"id": 10, "name": "web", "status": "RUNNING", "cloudspaceid": 3, "hostname": "web",
+	},
+	"/cloudapi/machines/create":        10,
+	"/cloudapi/machines/update":        true,
+	"/cloudapi/machines/delete":        true,
+	"/cloudapi/machines/start":         true,
+	"/cloudapi/machines/stop":          true,
+	"/cloudapi/machines/reboot":        true,
+	"/cloudapi/machines/reset":         true,
+	"/cloudapi/machines/pause":         true,
+	"/cloudapi/machines/resume":        true,
+	"/cloudapi/machines/getConsoleUrl": "https://g8/console/10",
+	"/cloudapi/disks/list":             []map[string]interface{}{{"id": 20, "name": "data", "type": "D", "sizeMax": 10, "status": "CREATED"}},
+	"/cloudapi/disks/get":              map[string]interface{}{"id": 20, "name": "data", "type": "D", "sizeMax": 10, "status": "CREATED"},
+	"/cloudapi/disks/create":           20,
+	"/cloudapi/disks/resize":           true,
+	"/cloudapi/disks/limitIO":          true,
+	"/cloudapi/disks/delete":           true,
+	"/cloudapi/portforwarding/list": []map[string]interface{}{
+		{"id": 1, "publicIp": "185.1.2.3", "publicPort": "2022", "protocol": "tcp", "machineId": 10, "machineName": "web", "localIp": "192.168.103.10", "localPort": "22"},
+	},
+	"/cloudapi/portforwarding/create":       true,
+	"/cloudapi/portforwarding/updateByPort": true,
+	"/cloudapi/portforwarding/deleteByPort": true,
+	"/cloudapi/ipsec/listTunnels": []map[string]interface{}{
+		{"remoteAddr": "1.2.3.4", "remoteprivatenetwork": "10.0.0.0/24", "psk": "secret", "status": "up"},
+	},
+	"/cloudapi/ipsec/addTunnelToCloudspace":      "secret",
+	"/cloudapi/ipsec/removeTunnelFromCloudspace": true,
+	"/cloudapi/images/list":                      []map[string]interface{}{{"id": 1, "name": "ubuntu", "type": "Linux", "status": "CREATED"}},
+	"/cloudapi/images/get":                       map[string]interface{}{"id": 1, "name": "ubuntu", "type": "Linux", "status": "CREATED"},
+	"/cloudapi/images/delete":                    true,
+	"/cloudbroker/image/enable":                  true,
+	"/cloudbroker/image/disable":                 true,
+	"/cloudapi/sizes/list":                       []map[string]interface{}{{"id": 2, "name": "medium", "vcpus": 2, "memory": 4096, "disks": []int{10, 20}}},
+	"/cloudapi/locations/list":                   []map[string]interface{}{{"id": 1, "name": "be-g8-3", "locationCode": "be-g8-3", "gid": 1}},
+	"/cloudapi/externalnetwork/list": []map[string]interface{}{
+		{"id": 5, "name": "public", "network": "185.1.2.0", "subnetmask": "255.255.255.248", "gateway": "185.1.2.1"},
+	},
+	"/cloudapi/externalnetwork/get": map[string]interface{}{
+		"id": 5, "name": "public", "network": "185.1.2.0", "subnetmask": "255.255.255.248", "gateway": "185.1.2.1",
+	},
+}
+
+// fakeParams are the parameters of the last call to the fake G8 by endpoint
+var fakeParams = map[string]map[string]interface{}{}
+
+// newTestCLI runs a fake G8 answering with fakeResults and points the
+// environment of the CLI at it
+func newTestCLI(t *testing.T) func() {
+	key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	assert.NoError(t, err)
+	publicKey, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	assert.NoError(t, err)
+	assert.NoError(t, ovc.SetJWTPublicKey(string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicKey}))))
+	token, err := jwtLib.NewWithClaims(jwtLib.SigningMethodES384, jwtLib.MapClaims{
+		"exp":      time.Now().Add(time.Hour).Unix(),
+		"username": "tester",
+	}).SignedString(key)
+	assert.NoError(t, err)
+
+	var mu sync.Mutex
+	tasks := make(map[string][]interface{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		params := make(map[string]interface{})
+		body, _ := ioutil.ReadAll(r.Body)
+		_ = json.Unmarshal(body, &params)
+		if r.URL.Path == "/restmachine/system/task/get" {
+			json.NewEncoder(w).Encode(tasks[params["taskguid"].(string)])
+			return
+		}
+		endpoint := strings.TrimPrefix(r.URL.Path, "/restmachine")
+		fakeParams[endpoint] = params
+		result, ok := fakeResults[endpoint]
+		if !ok {
+			result = "unexpected call to " + endpoint
+		}
+		guid := fmt.Sprintf("task-%d", len(tasks))
+		tasks[guid] = []interface{}{ok, result}
+		data, _ := json.Marshal(guid)
+		w.Write(data)
+	}))
+
+	env := map[string]string{
+		"OVC_CONFIG":  "/nonexistent/ovc.yaml",
+		"OVC_URL":     srv.URL,
+		"OVC_JWT":     token,
+		"OVC_ACCOUNT": "7",
+	}
+	for name, value := range env {
+		os.Setenv(name, value)
+	}
+	return func() {
+		for name := range env {
+			os.Unsetenv(name)
+		}
+		srv.Close()
+	}
+}
+
+func TestCommandOutputs(t *testing.T) {
+	defer newTestCLI(t)()
+
+	commands := [][]string{
+		{"accounts", "list"},
+		{"accounts", "get", "7"},
+		{"cloudspaces", "list"},
+		{"cloudspaces", "get", "prod"},
+		{"cloudspaces", "create", "-name", "prod", "-location", "be-g8-3"},
+		{"cloudspaces", "update", "-name", "staging", "3"},
+		{"cloudspaces", "delete", "3"},
+		{"machines", "list", "-cloudspace", "3"},
+		{"machines", "get", "10"},
+		{"machines", "create", "-cloudspace", "3", "-image", "ubuntu", "-name", "web", "-size", "2", "-disksize", "10"},
+		{"machines", "update", "-name", "www", "10"},
+		{"machines", "delete", "10"},
+		{"machines", "start", "10"},
+		{"machines", "stop", "-force", "10"},
+		{"machines", "reboot", "10"},
+		{"machines", "reset", "10"},
+		{"machines", "pause", "10"},
+		{"machines", "resume", "10"},
+		{"machines", "console", "10"},
+		{"disks", "list"},
+		{"disks", "get", "20"},
+		{"disks", "create", "-name", "data", "-size", "10", "-gid", "1"},
+		{"disks", "update", "-size", "20", "20"},
+		{"disks", "delete", "20"},
+		{"portforwards", "list", "-cloudspace", "3"},
+		{"portforwards", "get", "-cloudspace", "3", "-public-port", "2022"},
+		{"portforwards", "create", "-cloudspace", "3", "-machine", "10", "-public-port", "2023", "-local-port", "22"},
+		{"portforwards", "update", "-cloudspace", "3", "-machine", "10", "-source-public-port", "2022", "-public-port", "2024", "-local-port", "22"},
+		{"portforwards", "delete", "-cloudspace", "3", "-public-port", "2022"},
+		{"ipsec", "list", "-cloudspace", "3"},
+		{"ipsec", "create", "-cloudspace", "3", "-remote-address", "1.2.3.5", "-remote-network", "10.0.1.0/24"},
+		{"ipsec", "update", "-cloudspace", "3", "-remote-address", "1.2.3.4", "-remote-network", "10.0.0.0/24", "-psk", "new"},
+		{"ipsec", "status", "-cloudspace", "3", "-remote-address", "1.2.3.4", "-remote-network", "10.0.0.0/24"},
+		{"ipsec", "delete", "-cloudspace", "3", "-remote-address", "1.2.3.4", "-remote-network", "10.0.0.0/24"},
+		{"images", "list"},
+		{"images", "get", "1"},
+		{"images", "enable", "1"},
+		{"images", "disable", "ubuntu"},
+		{"images", "delete", "1"},
+		{"sizes", "list", "-cloudspace", "3"},
+		{"locations", "list"},
+		{"externalnetworks", "list"},
+		{"externalnetworks", "get", "public"},
+		{"externalnetworks", "usage", "5"},
+	}
+	for _, output := range []string{outputTable, outputJSON, outputYAML} {
+		for _, command := range commands {
+			name := output + " " + strings.Join(command[:2], " ")
+			out := &bytes.Buffer{}
+			assert.NotPanics(t, func() {
+				assert.NoError(t, run(append([]string{"-o", output}, command...), out), name)
+			}, name)
+			if !assert.NotEmpty(t, out.String(), name) {
+				continue
+			}
+			switch output {
+			case outputJSON:
+				var v interface{}
+				assert.NoError(t, json.Unmarshal(out.Bytes(), &v), name)
+			case outputYAML:
+				var v interface{}
+				assert.NoError(t, yaml.Unmarshal(out.Bytes(), &v), name)
+			}
+		}
+	}
+}
+
+func TestPrintMapTable(t *testing.T) {
+	defer newTestCLI(t)()
+
+	for args, expected := range map[string]string{
+		"cloudspaces create -name prod -location be-g8-3": "ID\n3\n",
+		"machines console 10":                             "URL\nhttps://g8/console/10\n",
+		"ipsec create -cloudspace 3 -remote-address 1.2.3.5 -remote-network 10.0.1.0/24":                      "PSK\nsecret\n",
+		"ipsec update -cloudspace 3 -remote-address 1.2.3.4 -remote-network 10.0.0.0/24 -psk new":             "PSK\nsecret\n",
+		"portforwards create -cloudspace 3 -machine 10 -public-port 2023 -local-port 22 -public-ip 185.1.2.3": "PUBLICPORT\n2023\n",
+	} {
+		out := &bytes.Buffer{}
+		assert.NoError(t, run(strings.Fields(args), out), args)
+		assert.Equal(t, expected, out.String(), args)
+	}
+
+	out := &bytes.Buffer{}
+	c := &cli{out: out, output: outputTable}
+	assert.NoError(t, c.print(map[string]interface{}{"id": 3, "name": "prod"}, "name", "id", "missing"))
+	assert.Equal(t, "NAME  ID  MISSING\nprod  3   \n", out.String())
+}
+
+func TestPortForwardsUpdate(t *testing.T) {
+	defer newTestCLI(t)()
+
+	out := &bytes.Buffer{}
+	assert.NoError(t, run(strings.Fields("portforwards create -cloudspace 3 -machine 10 -public-port 2023 -local-port 22"), out))
+	assert.Equal(t, "185.1.2.3", fakeParams["/cloudapi/portforwarding/create"]["publicIp"],
+		"the prefix length of the cloudspace IP should be stripped")
+
+	args := "portforwards update -cloudspace 3 -machine 10 -source-public-ip 185.1.2.4 -source-public-port 2022 -source-protocol udp -public-port 2024 -local-port 22"
+	assert.NoError(t, run(strings.Fields(args), out))
+	params := fakeParams["/cloudapi/portforwarding/updateByPort"]
+	assert.Equal(t, "185.1.2.4", params["sourcePublicIp"])
+	assert.Equal(t, float64(2022), params["sourcePublicPort"])
+	assert.Equal(t, "udp", params["sourceProtocol"])
+	assert.Equal(t, "185.1.2.3", params["publicIp"])
+	assert.Equal(t, "tcp", params["protocol"])
+
+	assert.NoError(t, run(strings.Fields("portforwards update -cloudspace 3 -machine 10 -source-public-port 2022 -public-port 2024 -local-port 22"), out))
+	params = fakeParams["/cloudapi/portforwarding/updateByPort"]
+	assert.Equal(t, "185.1.2.3", params["sourcePublicIp"])
+	assert.Equal(t, "tcp", params["sourceProtocol"])
+
+	assert.Error(t, run(strings.Fields("portforwards update -cloudspace 3 -machine 10 -public-port 2024 -local-port 22"), out),
+		"the source public port should be required")
+}
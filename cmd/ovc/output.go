@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"text/tabwriter"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+	outputYAML  = "yaml"
+)
+
+// print writes v in the selected output format. For table output, columns
+// are the names of the struct fields or map keys shown; v can be a struct or
+// a map, a pointer to one or a (pointer to a) slice of them.
+func (c *cli) print(v interface{}, columns ...string) error {
+	switch c.output {
+	case outputJSON:
+		data, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(c.out, string(data))
+		return err
+	case outputYAML:
+		// go through JSON so the field names match the API and the JSON output
+		data, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		var generic interface{}
+		if err := json.Unmarshal(data, &generic); err != nil {
+			return err
+		}
+		data, err = yaml.Marshal(generic)
+		if err != nil {
+			return err
+		}
+		_, err = c.out.Write(data)
+		return err
+	}
+	return c.printTable(v, columns)
+}
+
+// printMessage prints a confirmation of an action that has no result object
+func (c *cli) printMessage(format string, args ...interface{}) error {
+	if c.output != outputTable {
+		return c.print(map[string]string{"result": fmt.Sprintf(format, args...)})
+	}
+	_, err := fmt.Fprintf(c.out, format+"\n", args...)
+	return err
+}
+
+func (c *cli) printTable(v interface{}, columns []string) error {
+	value := reflect.ValueOf(v)
+	for value.Kind() == reflect.Ptr {
+		value = value.Elem()
+	}
+
+	rows := []reflect.Value{}
+	if value.Kind() == reflect.Slice {
+		for i := 0; i < value.Len(); i++ {
+			rows = append(rows, reflect.Indirect(value.Index(i)))
+		}
+	} else {
+		rows = append(rows, value)
+	}
+
+	w := tabwriter.NewWriter(c.out, 0, 4, 2, ' ', 0)
+	headers := make([]string, len(columns))
+	for i, column := range columns {
+		headers[i] = strings.ToUpper(column)
+	}
+	fmt.Fprintln(w, strings.Join(headers, "\t"))
+	for _, row := range rows {
+		cells := make([]string, len(columns))
+		for i, column := range columns {
+			cells[i] = formatCell(cell(row, column))
+		}
+		fmt.Fprintln(w, strings.Join(cells, "\t"))
+	}
+	return w.Flush()
+}
+
+// cell returns the field or map value column of row
+func cell(row reflect.Value, column string) reflect.Value {
+	switch row.Kind() {
+	case reflect.Struct:
+		return row.FieldByName(column)
+	case reflect.Map:
+		if row.Type().Key().Kind() != reflect.String {
+			return reflect.Value{}
+		}
+		return row.MapIndex(reflect.ValueOf(column).Convert(row.Type().Key()))
+	}
+	return reflect.Value{}
+}
+
+func formatCell(v reflect.Value) string {
+	if !v.IsValid() {
+		return ""
+	}
+	if v.Kind() == reflect.Interface {
+		v = v.Elem()
+	}
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+	if v.Kind() == reflect.Slice {
+		items := make([]string, v.Len())
+		for i := range items {
+			items[i] = formatCell(v.Index(i))
+		}
+		return strings.Join(items, ",")
+	}
+	return fmt.Sprint(v.Interface())
+}
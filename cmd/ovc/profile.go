@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Profile contains the connection settings for a G8
+type Profile struct {
+	URL          string `yaml:"url"`
+	ClientID     string `yaml:"clientId"`
+	ClientSecret string `yaml:"clientSecret"`
+	JWT          string `yaml:"jwt"`
+	// Account is used to resolve names of account bound resources when no
+	// account is passed on the command line
+	Account string `yaml:"account"`
+}
+
+// ProfileConfig is the content of the profile configuration file, e.g.:
+//
+//	default: be-g8-3
+//	profiles:
+//	  be-g8-3:
+//	    url: https://be-g8-3.gig.tech
+//	    clientId: my-client-id
+//	    clientSecret: my-client-secret
+//	    account: my-account
+type ProfileConfig struct {
+	Default  string              `yaml:"default"`
+	Profiles map[string]*Profile `yaml:"profiles"`
+}
+
+func defaultConfigFile() string {
+	if file := os.Getenv("OVC_CONFIG"); file != "" {
+		return file
+	}
+	return filepath.Join(os.Getenv("HOME"), ".ovc", "config.yaml")
+}
+
+// loadProfile reads profile name from configFile. Without a name the default
+// profile of the file is used. Settings can be overridden with the OVC_URL,
+// OVC_CLIENT_ID, OVC_CLIENT_SECRET, OVC_JWT and OVC_ACCOUNT environment variables.
+func loadProfile(configFile string, name string) (*Profile, error) {
+	profile := &Profile{}
+
+	config := &ProfileConfig{}
+	data, err := ioutil.ReadFile(configFile)
+	switch {
+	case err == nil:
+		if err := yaml.UnmarshalStrict(data, config); err != nil {
+			return nil, fmt.Errorf("invalid configuration file %s: %s", configFile, err)
+		}
+	case os.IsNotExist(err) && name == "":
+		// no configuration file, rely on the environment
+	default:
+		return nil, err
+	}
+
+	if name == "" {
+		name = config.Default
+	}
+	if name != "" {
+		p, ok := config.Profiles[name]
+		if !ok {
+			return nil, fmt.Errorf("profile %q not found in %s", name, configFile)
+		}
+		*profile = *p
+	}
+
+	overrides := map[string]*string{
+		"OVC_URL":           &profile.URL,
+		"OVC_CLIENT_ID":     &profile.ClientID,
+		"OVC_CLIENT_SECRET": &profile.ClientSecret,
+		"OVC_JWT":           &profile.JWT,
+		"OVC_ACCOUNT":       &profile.Account,
+	}
+	for env, field := range overrides {
+		if value, ok := os.LookupEnv(env); ok {
+			*field = value
+		}
+	}
+	// a JWT from the environment replaces client credentials from the file
+	if _, ok := os.LookupEnv("OVC_JWT"); ok {
+		profile.ClientID = ""
+		profile.ClientSecret = ""
+	}
+
+	return profile, nil
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// The resolve functions accept either the numeric ID or the name of a
+// resource and return its ID. Names are looked up within their parent.
+
+func (c *cli) resolveAccount(nameOrID string) (int, error) {
+	if nameOrID == "" {
+		nameOrID = c.profile.Account
+	}
+	if nameOrID == "" {
+		return 0, fmt.Errorf("no account given, pass -account or set it in the profile")
+	}
+	if id, err := strconv.Atoi(nameOrID); err == nil {
+		return id, nil
+	}
+	client, err := c.connect()
+	if err != nil {
+		return 0, err
+	}
+	return client.Accounts.GetIDByName(nameOrID)
+}
+
+func (c *cli) resolveCloudSpace(nameOrID string, account string) (int, error) {
+	if nameOrID == "" {
+		return 0, fmt.Errorf("no cloudspace given, pass -cloudspace")
+	}
+	if id, err := strconv.Atoi(nameOrID); err == nil {
+		return id, nil
+	}
+	client, err := c.connect()
+	if err != nil {
+		return 0, err
+	}
+	if account == "" {
+		account = c.profile.Account
+	}
+	cloudSpaces, err := client.CloudSpaces.List()
+	if err != nil {
+		return 0, err
+	}
+	matches := []int{}
+	for _, cs := range *cloudSpaces {
+		if cs.Name != nameOrID {
+			continue
+		}
+		if account != "" && cs.AccountName != account && strconv.Itoa(cs.AccountID) != account {
+			continue
+		}
+		matches = append(matches, cs.ID)
+	}
+	switch len(matches) {
+	case 0:
+		return 0, fmt.Errorf("cloudspace %q not found", nameOrID)
+	case 1:
+		return matches[0], nil
+	}
+	return 0, fmt.Errorf("cloudspace name %q is ambiguous (IDs %v), pass -account or use the ID", nameOrID, matches)
+}
+
+func (c *cli) resolveMachine(nameOrID string, cloudSpace string, account string) (int, error) {
+	if id, err := strconv.Atoi(nameOrID); err == nil {
+		return id, nil
+	}
+	cloudSpaceID, err := c.resolveCloudSpace(cloudSpace, account)
+	if err != nil {
+		return 0, fmt.Errorf("machine names are resolved within a cloudspace: %s", err)
+	}
+	client, err := c.connect()
+	if err != nil {
+		return 0, err
+	}
+	machine, err := client.Machines.GetByName(nameOrID, cloudSpaceID)
+	if err != nil {
+		return 0, err
+	}
+	return machine.ID, nil
+}
+
+func (c *cli) resolveDisk(nameOrID string, account string) (int, error) {
+	if id, err := strconv.Atoi(nameOrID); err == nil {
+		return id, nil
+	}
+	accountID, err := c.resolveAccount(account)
+	if err != nil {
+		return 0, fmt.Errorf("disk names are resolved within an account: %s", err)
+	}
+	client, err := c.connect()
+	if err != nil {
+		return 0, err
+	}
+	disk, err := client.Disks.GetByName(nameOrID, accountID, "")
+	if err != nil {
+		return 0, err
+	}
+	return disk.ID, nil
+}
+
+func (c *cli) resolveImage(nameOrID string, account string) (int, error) {
+	if id, err := strconv.Atoi(nameOrID); err == nil {
+		return id, nil
+	}
+	accountID, err := c.resolveAccount(account)
+	if err != nil {
+		return 0, fmt.Errorf("image names are resolved within an account: %s", err)
+	}
+	client, err := c.connect()
+	if err != nil {
+		return 0, err
+	}
+	image, err := client.Images.GetByName(nameOrID, accountID)
+	if err != nil {
+		return 0, err
+	}
+	return image.ID, nil
+}
+
+func (c *cli) resolveExternalNetwork(nameOrID string, account string) (int, error) {
+	if id, err := strconv.Atoi(nameOrID); err == nil {
+		return id, nil
+	}
+	accountID, err := c.resolveAccount(account)
+	if err != nil {
+		return 0, fmt.Errorf("external network names are resolved within an account: %s", err)
+	}
+	client, err := c.connect()
+	if err != nil {
+		return 0, err
+	}
+	externalNetworks, err := client.ExternalNetworks.List(accountID)
+	if err != nil {
+		return 0, err
+	}
+	for _, externalNetwork := range *externalNetworks {
+		if externalNetwork.Name == nameOrID {
+			return externalNetwork.ID, nil
+		}
+	}
+	return 0, fmt.Errorf("external network %q not found", nameOrID)
+}
@@ -0,0 +1,1098 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+
+	"github.com/gig-tech/ovc-sdk-go/v4/ovc"
+)
+
+// resources maps every resource and verb to its command
+var resources = map[string]map[string]command{
+	"accounts": {
+		"list": {"list accounts", accountsList},
+		"get":  {"show an account", accountsGet},
+	},
+	"cloudspaces": {
+		"list":   {"list cloudspaces", cloudSpacesList},
+		"get":    {"show a cloudspace", cloudSpacesGet},
+		"create": {"create a cloudspace", cloudSpacesCreate},
+		"update": {"update a cloudspace", cloudSpacesUpdate},
+		"delete": {"delete a cloudspace", cloudSpacesDelete},
+	},
+	"machines": {
+		"list":    {"list machines of a cloudspace", machinesList},
+		"get":     {"show a machine", machinesGet},
+		"create":  {"create a machine", machinesCreate},
+		"update":  {"update name or description of a machine", machinesUpdate},
+		"delete":  {"delete a machine", machinesDelete},
+		"start":   {"start a machine", machinesStart},
+		"stop":    {"stop a machine", machinesStop},
+		"reboot":  {"reboot a machine", machinePowerAction("reboot", "rebooted", ovc.MachineService.Reboot)},
+		"reset":   {"hard reset a machine", machinePowerAction("reset", "reset", ovc.MachineService.Reset)},
+		"pause":   {"pause a machine", machinePowerAction("pause", "paused", ovc.MachineService.Pause)},
+		"resume":  {"resume a paused machine", machinePowerAction("resume", "resumed", ovc.MachineService.Resume)},
+		"console": {"show the console URL of a machine", machinesConsole},
+	},
+	"disks": {
+		"list":   {"list disks of an account", disksList},
+		"get":    {"show a disk", disksGet},
+		"create": {"create a disk", disksCreate},
+		"update": {"resize or limit the IOPS of a disk", disksUpdate},
+		"delete": {"delete a disk", disksDelete},
+	},
+	"portforwards": {
+		"list":   {"list port forwards of a cloudspace", portForwardsList},
+		"get":    {"show the port forward of a public port", portForwardsGet},
+		"create": {"create a port forward", portForwardsCreate},
+		"update": {"update a port forward", portForwardsUpdate},
+		"delete": {"delete a port forward", portForwardsDelete},
+	},
+	"ipsec": {
+		"list":   {"list IPsec tunnels of a cloudspace", ipsecList},
+		"create": {"create an IPsec tunnel", ipsecCreate},
+		"delete": {"delete an IPsec tunnel", ipsecDelete},
+		"update": {"update the pre-shared key or remote network of an IPsec tunnel", ipsecUpdate},
+		"status": {"show the status of an IPsec tunnel", ipsecStatus},
+	},
+	"images": {
+		"list":    {"list images available to an account", imagesList},
+		"get":     {"show an image", imagesGet},
+		"delete":  {"delete an image", imagesDelete},
+		"enable":  {"enable a disabled image", imageAction("enable", "enabled", ovc.ImageService.Enable)},
+		"disable": {"disable an image for new machines", imageAction("disable", "disabled", ovc.ImageService.Disable)},
+	},
+	"sizes": {
+		"list": {"list machine sizes available in a cloudspace", sizesList},
+	},
+	"locations": {
+		"list": {"list locations of the G8", locationsList},
+	},
+	"externalnetworks": {
+		"list":  {"list external networks available to an account", externalNetworksList},
+		"get":   {"show an external network", externalNetworksGet},
+		"usage": {"show the addresses of an external network in use", externalNetworksUsage},
+	},
+}
+
+func accountsList(c *cli, args []string) error {
+	fs := c.flags("accounts", "list")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	client, err := c.connect()
+	if err != nil {
+		return err
+	}
+	accounts, err := client.Accounts.List()
+	if err != nil {
+		return err
+	}
+	return c.print(accounts, "ID", "Name")
+}
+
+func accountsGet(c *cli, args []string) error {
+	fs := c.flags("accounts", "get")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	nameOrID, err := singleArg(fs, "account")
+	if err != nil {
+		return err
+	}
+	id, err := c.resolveAccount(nameOrID)
+	if err != nil {
+		return err
+	}
+	client, err := c.connect()
+	if err != nil {
+		return err
+	}
+	account, err := client.Accounts.Get(id)
+	if err != nil {
+		return err
+	}
+	return c.print(account, "ID", "Name", "Status", "CreationTime", "UpdateTime")
+}
+
+func cloudSpacesList(c *cli, args []string) error {
+	fs := c.flags("cloudspaces", "list")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	client, err := c.connect()
+	if err != nil {
+		return err
+	}
+	cloudSpaces, err := client.CloudSpaces.List()
+	if err != nil {
+		return err
+	}
+	return c.print(cloudSpaces, "ID", "Name", "AccountName", "Location", "Status", "Externalnetworkip")
+}
+
+func cloudSpacesGet(c *cli, args []string) error {
+	fs := c.flags("cloudspaces", "get")
+	account := fs.String("account", "", "account name or ID, used to resolve the cloudspace name")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	nameOrID, err := singleArg(fs, "cloudspace")
+	if err != nil {
+		return err
+	}
+	id, err := c.resolveCloudSpace(nameOrID, *account)
+	if err != nil {
+		return err
+	}
+	client, err := c.connect()
+	if err != nil {
+		return err
+	}
+	cloudSpace, err := client.CloudSpaces.Get(id)
+	if err != nil {
+		return err
+	}
+	return c.print(cloudSpace, "ID", "Name", "AccountID", "Location", "Status", "Externalnetworkip", "PrivateNetwork")
+}
+
+// cloudSpaceConfigFlags registers the flags shared by cloudspace create and update
+func cloudSpaceConfigFlags(fs *flag.FlagSet, config *ovc.CloudSpaceConfig) {
+	fs.StringVar(&config.Name, "name", "", "name of the cloudspace")
+	fs.Float64Var(&config.MaxMemoryCapacity, "max-memory", 0, "maximum memory capacity in GB")
+	fs.IntVar(&config.MaxCPUCapacity, "max-cpu", 0, "maximum number of vCPUs")
+	fs.IntVar(&config.MaxDiskCapacity, "max-disk", 0, "maximum disk capacity in GB")
+	fs.IntVar(&config.MaxNetworkPeerTransfer, "max-transfer", 0, "maximum network transfer in GB")
+	fs.IntVar(&config.MaxNumPublicIP, "max-public-ips", 0, "maximum number of public IPs")
+}
+
+func cloudSpacesCreate(c *cli, args []string) error {
+	fs := c.flags("cloudspaces", "create")
+	config := &ovc.CloudSpaceConfig{}
+	cloudSpaceConfigFlags(fs, config)
+	account := fs.String("account", "", "account name or ID")
+	externalNetwork := fs.String("external-network", "", "external network name or ID")
+	fs.StringVar(&config.Location, "location", "", "location code of the cloudspace")
+	fs.StringVar(&config.PrivateNetwork, "private-network", "", "private network CIDR of the cloudspace")
+	fs.StringVar(&config.Mode, "mode", "", "mode of the cloudspace")
+	fs.StringVar(&config.Type, "type", "", "type of the cloudspace")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	accountID, err := c.resolveAccount(*account)
+	if err != nil {
+		return err
+	}
+	config.AccountID = accountID
+	if *externalNetwork != "" {
+		config.ExternalnetworkID, err = c.resolveExternalNetwork(*externalNetwork, *account)
+		if err != nil {
+			return err
+		}
+	}
+	client, err := c.connect()
+	if err != nil {
+		return err
+	}
+	config.Access = client.Access
+	id, err := client.CloudSpaces.Create(config)
+	if err != nil {
+		return err
+	}
+	return c.print(map[string]int{"id": id}, "id")
+}
+
+func cloudSpacesUpdate(c *cli, args []string) error {
+	fs := c.flags("cloudspaces", "update")
+	config := &ovc.CloudSpaceConfig{}
+	cloudSpaceConfigFlags(fs, config)
+	account := fs.String("account", "", "account name or ID, used to resolve the cloudspace name")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	nameOrID, err := singleArg(fs, "cloudspace")
+	if err != nil {
+		return err
+	}
+	config.CloudSpaceID, err = c.resolveCloudSpace(nameOrID, *account)
+	if err != nil {
+		return err
+	}
+	client, err := c.connect()
+	if err != nil {
+		return err
+	}
+	if err := client.CloudSpaces.Update(config); err != nil {
+		return err
+	}
+	return c.printMessage("cloudspace %d updated", config.CloudSpaceID)
+}
+
+func cloudSpacesDelete(c *cli, args []string) error {
+	fs := c.flags("cloudspaces", "delete")
+	account := fs.String("account", "", "account name or ID, used to resolve the cloudspace name")
+	permanently := fs.Bool("permanently", false, "delete the cloudspace permanently")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	nameOrID, err := singleArg(fs, "cloudspace")
+	if err != nil {
+		return err
+	}
+	id, err := c.resolveCloudSpace(nameOrID, *account)
+	if err != nil {
+		return err
+	}
+	client, err := c.connect()
+	if err != nil {
+		return err
+	}
+	err = client.CloudSpaces.Delete(&ovc.CloudSpaceDeleteConfig{CloudSpaceID: id, Permanently: *permanently})
+	if err != nil {
+		return err
+	}
+	return c.printMessage("cloudspace %d deleted", id)
+}
+
+func machinesList(c *cli, args []string) error {
+	fs := c.flags("machines", "list")
+	cloudSpace := fs.String("cloudspace", "", "cloudspace name or ID")
+	account := fs.String("account", "", "account name or ID, used to resolve the cloudspace name")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	cloudSpaceID, err := c.resolveCloudSpace(*cloudSpace, *account)
+	if err != nil {
+		return err
+	}
+	client, err := c.connect()
+	if err != nil {
+		return err
+	}
+	machines, err := client.Machines.List(cloudSpaceID)
+	if err != nil {
+		return err
+	}
+	return c.print(machines, "ID", "Name", "Status", "Vcpus", "Memory", "Storage", "ImageID")
+}
+
+// machineArg parses fs and resolves its single machine argument
+func (c *cli) machineArg(fs *flag.FlagSet, args []string) (int, error) {
+	cloudSpace := fs.String("cloudspace", "", "cloudspace name or ID, used to resolve the machine name")
+	account := fs.String("account", "", "account name or ID, used to resolve the cloudspace name")
+	if err := fs.Parse(args); err != nil {
+		return 0, err
+	}
+	nameOrID, err := singleArg(fs, "machine")
+	if err != nil {
+		return 0, err
+	}
+	return c.resolveMachine(nameOrID, *cloudSpace, *account)
+}
+
+func machinesGet(c *cli, args []string) error {
+	id, err := c.machineArg(c.flags("machines", "get"), args)
+	if err != nil {
+		return err
+	}
+	client, err := c.connect()
+	if err != nil {
+		return err
+	}
+	machine, err := client.Machines.Get(id)
+	if err != nil {
+		return err
+	}
+	return c.print(machine, "ID", "Name", "Status", "CloudspaceID", "Vcpus", "Memory", "OsImage", "Hostname")
+}
+
+func machinesCreate(c *cli, args []string) error {
+	fs := c.flags("machines", "create")
+	config := &ovc.MachineConfig{}
+	cloudSpace := fs.String("cloudspace", "", "cloudspace name or ID")
+	account := fs.String("account", "", "account name or ID, used to resolve cloudspace and image names")
+	image := fs.String("image", "", "image name or ID")
+	fs.StringVar(&config.Name, "name", "", "name of the machine")
+	fs.StringVar(&config.Description, "description", "", "description of the machine")
+	fs.IntVar(&config.SizeID, "size", 0, "size ID of the machine")
+	fs.IntVar(&config.Vcpus, "vcpus", 0, "number of vCPUs, instead of a size")
+	fs.IntVar(&config.Memory, "memory", 0, "memory in MB, instead of a size")
+	fs.IntVar(&config.Disksize, "disksize", 0, "boot disk size in GB")
+	fs.StringVar(&config.Userdata, "userdata", "", "cloud-init user data")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	var err error
+	config.CloudspaceID, err = c.resolveCloudSpace(*cloudSpace, *account)
+	if err != nil {
+		return err
+	}
+	if *image == "" {
+		return fmt.Errorf("no image given, pass -image")
+	}
+	config.ImageID, err = c.resolveImage(*image, *account)
+	if err != nil {
+		return err
+	}
+	client, err := c.connect()
+	if err != nil {
+		return err
+	}
+	id, err := client.Machines.Create(config)
+	if err != nil {
+		return err
+	}
+	return c.print(map[string]int{"id": id}, "id")
+}
+
+func machinesUpdate(c *cli, args []string) error {
+	fs := c.flags("machines", "update")
+	name := fs.String("name", "", "new name of the machine")
+	description := fs.String("description", "", "new description of the machine")
+	id, err := c.machineArg(fs, args)
+	if err != nil {
+		return err
+	}
+	client, err := c.connect()
+	if err != nil {
+		return err
+	}
+	_, err = client.Machines.Update(&ovc.MachineConfig{
+		MachineID:   strconv.Itoa(id),
+		Name:        *name,
+		Description: *description,
+	})
+	if err != nil {
+		return err
+	}
+	return c.printMessage("machine %d updated", id)
+}
+
+func machinesDelete(c *cli, args []string) error {
+	fs := c.flags("machines", "delete")
+	permanently := fs.Bool("permanently", false, "delete the machine permanently")
+	id, err := c.machineArg(fs, args)
+	if err != nil {
+		return err
+	}
+	client, err := c.connect()
+	if err != nil {
+		return err
+	}
+	if err := client.Machines.Delete(id, *permanently); err != nil {
+		return err
+	}
+	return c.printMessage("machine %d deleted", id)
+}
+
+func machinesStart(c *cli, args []string) error {
+	id, err := c.machineArg(c.flags("machines", "start"), args)
+	if err != nil {
+		return err
+	}
+	client, err := c.connect()
+	if err != nil {
+		return err
+	}
+	if err := client.Machines.Start(id, 0); err != nil {
+		return err
+	}
+	return c.printMessage("machine %d started", id)
+}
+
+func machinesStop(c *cli, args []string) error {
+	fs := c.flags("machines", "stop")
+	force := fs.Bool("force", false, "stop the machine without a graceful shutdown")
+	id, err := c.machineArg(fs, args)
+	if err != nil {
+		return err
+	}
+	client, err := c.connect()
+	if err != nil {
+		return err
+	}
+	if *force {
+		err = client.Machines.Stop(id, true)
+	} else {
+		err = client.Machines.Shutdown(id)
+	}
+	if err != nil {
+		return err
+	}
+	return c.printMessage("machine %d stopped", id)
+}
+
+func machinesConsole(c *cli, args []string) error {
+	id, err := c.machineArg(c.flags("machines", "console"), args)
+	if err != nil {
+		return err
+	}
+	client, err := c.connect()
+	if err != nil {
+		return err
+	}
+	consoleURL, err := client.Machines.GetConsoleURL(id)
+	if err != nil {
+		return err
+	}
+	return c.print(map[string]string{"url": consoleURL}, "url")
+}
+
+// machinePowerAction returns a verb running a power action on a machine
+func machinePowerAction(verb string, done string, action func(ovc.MachineService, int) error) func(*cli, []string) error {
+	return func(c *cli, args []string) error {
+		id, err := c.machineArg(c.flags("machines", verb), args)
+		if err != nil {
+			return err
+		}
+		client, err := c.connect()
+		if err != nil {
+			return err
+		}
+		if err := action(client.Machines, id); err != nil {
+			return err
+		}
+		return c.printMessage("machine %d %s", id, done)
+	}
+}
+
+func disksList(c *cli, args []string) error {
+	fs := c.flags("disks", "list")
+	account := fs.String("account", "", "account name or ID")
+	diskType := fs.String("type", "", "only list disks of this type (B for boot disks, D for data disks)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	accountID, err := c.resolveAccount(*account)
+	if err != nil {
+		return err
+	}
+	client, err := c.connect()
+	if err != nil {
+		return err
+	}
+	disks, err := client.Disks.List(accountID, *diskType)
+	if err != nil {
+		return err
+	}
+	return c.print(disks, "ID", "Name", "Type", "Size", "Status")
+}
+
+func disksGet(c *cli, args []string) error {
+	fs := c.flags("disks", "get")
+	account := fs.String("account", "", "account name or ID, used to resolve the disk name")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	nameOrID, err := singleArg(fs, "disk")
+	if err != nil {
+		return err
+	}
+	id, err := c.resolveDisk(nameOrID, *account)
+	if err != nil {
+		return err
+	}
+	client, err := c.connect()
+	if err != nil {
+		return err
+	}
+	disk, err := client.Disks.Get(id)
+	if err != nil {
+		return err
+	}
+	return c.print(disk, "ID", "Name", "Type", "SizeMax", "SizeUsed", "Status")
+}
+
+func disksCreate(c *cli, args []string) error {
+	fs := c.flags("disks", "create")
+	config := &ovc.DiskConfig{}
+	account := fs.String("account", "", "account name or ID")
+	fs.StringVar(&config.Name, "name", "", "name of the disk")
+	fs.StringVar(&config.Description, "description", "", "description of the disk")
+	fs.IntVar(&config.GridID, "gid", 0, "grid ID to create the disk in")
+	fs.IntVar(&config.Size, "size", 0, "size of the disk in GB")
+	fs.StringVar(&config.Type, "type", "D", "type of the disk")
+	fs.IntVar(&config.IOPS, "iops", 0, "maximum IOPS of the disk")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	var err error
+	config.AccountID, err = c.resolveAccount(*account)
+	if err != nil {
+		return err
+	}
+	client, err := c.connect()
+	if err != nil {
+		return err
+	}
+	id, err := client.Disks.Create(config)
+	if err != nil {
+		return err
+	}
+	return c.print(map[string]int{"id": id}, "id")
+}
+
+func disksUpdate(c *cli, args []string) error {
+	fs := c.flags("disks", "update")
+	config := &ovc.DiskConfig{}
+	account := fs.String("account", "", "account name or ID, used to resolve the disk name")
+	fs.IntVar(&config.Size, "size", 0, "new size of the disk in GB")
+	fs.IntVar(&config.IOPS, "iops", 0, "new maximum IOPS of the disk")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	nameOrID, err := singleArg(fs, "disk")
+	if err != nil {
+		return err
+	}
+	config.DiskID, err = c.resolveDisk(nameOrID, *account)
+	if err != nil {
+		return err
+	}
+	client, err := c.connect()
+	if err != nil {
+		return err
+	}
+	if err := client.Disks.Update(config); err != nil {
+		return err
+	}
+	return c.printMessage("disk %d updated", config.DiskID)
+}
+
+func disksDelete(c *cli, args []string) error {
+	fs := c.flags("disks", "delete")
+	account := fs.String("account", "", "account name or ID, used to resolve the disk name")
+	detach := fs.Bool("detach", false, "detach the disk from its machine first")
+	permanently := fs.Bool("permanently", false, "delete the disk permanently")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	nameOrID, err := singleArg(fs, "disk")
+	if err != nil {
+		return err
+	}
+	id, err := c.resolveDisk(nameOrID, *account)
+	if err != nil {
+		return err
+	}
+	client, err := c.connect()
+	if err != nil {
+		return err
+	}
+	err = client.Disks.Delete(&ovc.DiskDeleteConfig{DiskID: id, Detach: *detach, Permanently: *permanently})
+	if err != nil {
+		return err
+	}
+	return c.printMessage("disk %d deleted", id)
+}
+
+// portForwardConfig registers the flags shared by the port forward verbs and
+// returns a function resolving them into a PortForwardingConfig
+func (c *cli) portForwardConfig(fs *flag.FlagSet) func() (*ovc.PortForwardingConfig, error) {
+	config := &ovc.PortForwardingConfig{}
+	cloudSpace := fs.String("cloudspace", "", "cloudspace name or ID")
+	account := fs.String("account", "", "account name or ID, used to resolve the cloudspace name")
+	machine := fs.String("machine", "", "machine name or ID")
+	fs.StringVar(&config.PublicIP, "public-ip", "", "public IP of the port forward, defaults to the cloudspace IP")
+	fs.IntVar(&config.PublicPort, "public-port", 0, "public port of the port forward")
+	fs.IntVar(&config.LocalPort, "local-port", 0, "port on the machine")
+	fs.StringVar(&config.Protocol, "protocol", "tcp", "protocol of the port forward, tcp or udp")
+	return func() (*ovc.PortForwardingConfig, error) {
+		var err error
+		config.CloudspaceID, err = c.resolveCloudSpace(*cloudSpace, *account)
+		if err != nil {
+			return nil, err
+		}
+		if *machine != "" {
+			config.MachineID, err = c.resolveMachine(*machine, strconv.Itoa(config.CloudspaceID), *account)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if config.PublicIP == "" {
+			config.PublicIP, err = c.cloudSpacePublicIP(config.CloudspaceID)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return config, nil
+	}
+}
+
+// cloudSpacePublicIP returns the IP port forwards of a cloudspace default to
+func (c *cli) cloudSpacePublicIP(cloudSpaceID int) (string, error) {
+	client, err := c.connect()
+	if err != nil {
+		return "", err
+	}
+	cloudSpace, err := client.CloudSpaces.Get(cloudSpaceID)
+	if err != nil {
+		return "", err
+	}
+	return cloudSpace.PublicIP(), nil
+}
+
+func portForwardsList(c *cli, args []string) error {
+	fs := c.flags("portforwards", "list")
+	cloudSpace := fs.String("cloudspace", "", "cloudspace name or ID")
+	account := fs.String("account", "", "account name or ID, used to resolve the cloudspace name")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	cloudSpaceID, err := c.resolveCloudSpace(*cloudSpace, *account)
+	if err != nil {
+		return err
+	}
+	client, err := c.connect()
+	if err != nil {
+		return err
+	}
+	portForwards, err := client.Portforwards.List(&ovc.PortForwardingConfig{CloudspaceID: cloudSpaceID})
+	if err != nil {
+		return err
+	}
+	return c.print(portForwards, "ID", "PublicIP", "PublicPort", "Protocol", "MachineName", "LocalIP", "LocalPort")
+}
+
+func portForwardsGet(c *cli, args []string) error {
+	fs := c.flags("portforwards", "get")
+	resolve := c.portForwardConfig(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	config, err := resolve()
+	if err != nil {
+		return err
+	}
+	client, err := c.connect()
+	if err != nil {
+		return err
+	}
+	portForward, err := client.Portforwards.Get(config)
+	if err != nil {
+		return err
+	}
+	return c.print(portForward, "ID", "PublicIP", "PublicPort", "Protocol", "MachineName", "LocalIP", "LocalPort")
+}
+
+func portForwardsCreate(c *cli, args []string) error {
+	fs := c.flags("portforwards", "create")
+	resolve := c.portForwardConfig(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	config, err := resolve()
+	if err != nil {
+		return err
+	}
+	client, err := c.connect()
+	if err != nil {
+		return err
+	}
+	publicPort, err := client.Portforwards.Create(config)
+	if err != nil {
+		return err
+	}
+	return c.print(map[string]int{"publicPort": publicPort}, "publicPort")
+}
+
+func portForwardsUpdate(c *cli, args []string) error {
+	fs := c.flags("portforwards", "update")
+	resolve := c.portForwardConfig(fs)
+	sourcePublicIP := fs.String("source-public-ip", "", "current public IP of the port forward, defaults to the cloudspace IP")
+	sourcePublicPort := fs.Int("source-public-port", 0, "current public port of the port forward (required)")
+	sourceProtocol := fs.String("source-protocol", "tcp", "current protocol of the port forward, tcp or udp")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *sourcePublicPort == 0 {
+		return fmt.Errorf("no source public port given, pass -source-public-port")
+	}
+	config, err := resolve()
+	if err != nil {
+		return err
+	}
+	config.SourcePublicIP = *sourcePublicIP
+	config.SourcePublicPort = *sourcePublicPort
+	config.SourceProtocol = *sourceProtocol
+	if config.SourcePublicIP == "" {
+		config.SourcePublicIP, err = c.cloudSpacePublicIP(config.CloudspaceID)
+		if err != nil {
+			return err
+		}
+	}
+	client, err := c.connect()
+	if err != nil {
+		return err
+	}
+	if err := client.Portforwards.Update(config); err != nil {
+		return err
+	}
+	return c.printMessage("port forward %s:%d updated", config.PublicIP, config.PublicPort)
+}
+
+func portForwardsDelete(c *cli, args []string) error {
+	fs := c.flags("portforwards", "delete")
+	resolve := c.portForwardConfig(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	config, err := resolve()
+	if err != nil {
+		return err
+	}
+	client, err := c.connect()
+	if err != nil {
+		return err
+	}
+	if err := client.Portforwards.Delete(config); err != nil {
+		return err
+	}
+	return c.printMessage("port forward %s:%d deleted", config.PublicIP, config.PublicPort)
+}
+
+func ipsecList(c *cli, args []string) error {
+	fs := c.flags("ipsec", "list")
+	cloudSpace := fs.String("cloudspace", "", "cloudspace name or ID")
+	account := fs.String("account", "", "account name or ID, used to resolve the cloudspace name")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	cloudSpaceID, err := c.resolveCloudSpace(*cloudSpace, *account)
+	if err != nil {
+		return err
+	}
+	client, err := c.connect()
+	if err != nil {
+		return err
+	}
+	tunnels, err := client.Ipsec.List(&ovc.IpsecConfig{CloudspaceID: cloudSpaceID})
+	if err != nil {
+		return err
+	}
+	return c.print(tunnels, "RemoteAddr", "RemotePrivateNetwork", "Status")
+}
+
+func ipsecCreate(c *cli, args []string) error {
+	fs := c.flags("ipsec", "create")
+	config := &ovc.IpsecConfig{}
+	cloudSpace := fs.String("cloudspace", "", "cloudspace name or ID")
+	account := fs.String("account", "", "account name or ID, used to resolve the cloudspace name")
+	fs.StringVar(&config.RemotePublicAddr, "remote-address", "", "public address of the remote end")
+	fs.StringVar(&config.RemotePrivateNetwork, "remote-network", "", "private network CIDR of the remote end")
+	fs.StringVar(&config.PskSecret, "psk", "", "pre-shared key, generated when empty")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	var err error
+	config.CloudspaceID, err = c.resolveCloudSpace(*cloudSpace, *account)
+	if err != nil {
+		return err
+	}
+	client, err := c.connect()
+	if err != nil {
+		return err
+	}
+	psk, err := client.Ipsec.Create(config)
+	if err != nil {
+		return err
+	}
+	return c.print(map[string]string{"psk": psk}, "psk")
+}
+
+func ipsecDelete(c *cli, args []string) error {
+	fs := c.flags("ipsec", "delete")
+	config := &ovc.IpsecConfig{}
+	cloudSpace := fs.String("cloudspace", "", "cloudspace name or ID")
+	account := fs.String("account", "", "account name or ID, used to resolve the cloudspace name")
+	fs.StringVar(&config.RemotePublicAddr, "remote-address", "", "public address of the remote end")
+	fs.StringVar(&config.RemotePrivateNetwork, "remote-network", "", "private network CIDR of the remote end")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	var err error
+	config.CloudspaceID, err = c.resolveCloudSpace(*cloudSpace, *account)
+	if err != nil {
+		return err
+	}
+	client, err := c.connect()
+	if err != nil {
+		return err
+	}
+	if err := client.Ipsec.Delete(config); err != nil {
+		return err
+	}
+	return c.printMessage("IPsec tunnel to %s deleted", config.RemotePublicAddr)
+}
+
+func ipsecUpdate(c *cli, args []string) error {
+	fs := c.flags("ipsec", "update")
+	config := &ovc.IpsecUpdateConfig{}
+	cloudSpace := fs.String("cloudspace", "", "cloudspace name or ID")
+	account := fs.String("account", "", "account name or ID, used to resolve the cloudspace name")
+	fs.StringVar(&config.RemotePublicAddr, "remote-address", "", "public address of the remote end")
+	fs.StringVar(&config.RemotePrivateNetwork, "remote-network", "", "current private network CIDR of the remote end")
+	fs.StringVar(&config.NewRemotePrivateNetwork, "new-remote-network", "", "new private network CIDR of the remote end")
+	fs.StringVar(&config.PskSecret, "psk", "", "new pre-shared key")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	var err error
+	config.CloudspaceID, err = c.resolveCloudSpace(*cloudSpace, *account)
+	if err != nil {
+		return err
+	}
+	client, err := c.connect()
+	if err != nil {
+		return err
+	}
+	psk, err := client.Ipsec.Update(config)
+	if err != nil {
+		return err
+	}
+	return c.print(map[string]string{"psk": psk}, "psk")
+}
+
+func ipsecStatus(c *cli, args []string) error {
+	fs := c.flags("ipsec", "status")
+	config := &ovc.IpsecConfig{}
+	cloudSpace := fs.String("cloudspace", "", "cloudspace name or ID")
+	account := fs.String("account", "", "account name or ID, used to resolve the cloudspace name")
+	fs.StringVar(&config.RemotePublicAddr, "remote-address", "", "public address of the remote end")
+	fs.StringVar(&config.RemotePrivateNetwork, "remote-network", "", "private network CIDR of the remote end")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	var err error
+	config.CloudspaceID, err = c.resolveCloudSpace(*cloudSpace, *account)
+	if err != nil {
+		return err
+	}
+	client, err := c.connect()
+	if err != nil {
+		return err
+	}
+	status, err := client.Ipsec.Status(config)
+	if err != nil {
+		return err
+	}
+	return c.print(status, "Up", "Known", "CloudSpaceStatus")
+}
+
+func imagesList(c *cli, args []string) error {
+	fs := c.flags("images", "list")
+	account := fs.String("account", "", "account name or ID")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	accountID, err := c.resolveAccount(*account)
+	if err != nil {
+		return err
+	}
+	client, err := c.connect()
+	if err != nil {
+		return err
+	}
+	images, err := client.Images.List(accountID)
+	if err != nil {
+		return err
+	}
+	return c.print(images, "ID", "Name", "Type", "Size", "Status")
+}
+
+func imagesGet(c *cli, args []string) error {
+	fs := c.flags("images", "get")
+	account := fs.String("account", "", "account name or ID")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	nameOrID, err := singleArg(fs, "image")
+	if err != nil {
+		return err
+	}
+	id, err := c.resolveImage(nameOrID, *account)
+	if err != nil {
+		return err
+	}
+	client, err := c.connect()
+	if err != nil {
+		return err
+	}
+	image, err := client.Images.Get(id)
+	if err != nil {
+		return err
+	}
+	return c.print(image, "ID", "Name", "Description", "Type", "Size", "Status")
+}
+
+func imagesDelete(c *cli, args []string) error {
+	fs := c.flags("images", "delete")
+	account := fs.String("account", "", "account name or ID, used to resolve the image name")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	nameOrID, err := singleArg(fs, "image")
+	if err != nil {
+		return err
+	}
+	id, err := c.resolveImage(nameOrID, *account)
+	if err != nil {
+		return err
+	}
+	client, err := c.connect()
+	if err != nil {
+		return err
+	}
+	if err := client.Images.Delete(id); err != nil {
+		return err
+	}
+	return c.printMessage("image %d deleted", id)
+}
+
+func imageAction(verb string, done string, action func(ovc.ImageService, int) error) func(*cli, []string) error {
+	return func(c *cli, args []string) error {
+		fs := c.flags("images", verb)
+		account := fs.String("account", "", "account name or ID, used to resolve the image name")
+		if err := fs.Parse(args); err != nil {
+			return err
+		}
+		nameOrID, err := singleArg(fs, "image")
+		if err != nil {
+			return err
+		}
+		id, err := c.resolveImage(nameOrID, *account)
+		if err != nil {
+			return err
+		}
+		client, err := c.connect()
+		if err != nil {
+			return err
+		}
+		if err := action(client.Images, id); err != nil {
+			return err
+		}
+		return c.printMessage("image %d %s", id, done)
+	}
+}
+
+func sizesList(c *cli, args []string) error {
+	fs := c.flags("sizes", "list")
+	cloudSpace := fs.String("cloudspace", "", "cloudspace name or ID")
+	account := fs.String("account", "", "account name or ID, used to resolve the cloudspace name")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	cloudSpaceID, err := c.resolveCloudSpace(*cloudSpace, *account)
+	if err != nil {
+		return err
+	}
+	client, err := c.connect()
+	if err != nil {
+		return err
+	}
+	sizes, err := client.Sizes.List(cloudSpaceID)
+	if err != nil {
+		return err
+	}
+	return c.print(sizes, "ID", "Name", "Vcpus", "Memory", "Disks")
+}
+
+func locationsList(c *cli, args []string) error {
+	fs := c.flags("locations", "list")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	client, err := c.connect()
+	if err != nil {
+		return err
+	}
+	locations, err := client.Locations.List()
+	if err != nil {
+		return err
+	}
+	return c.print(locations, "ID", "Name", "Code", "GridID")
+}
+
+func externalNetworksList(c *cli, args []string) error {
+	fs := c.flags("externalnetworks", "list")
+	account := fs.String("account", "", "account name or ID")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	accountID, err := c.resolveAccount(*account)
+	if err != nil {
+		return err
+	}
+	client, err := c.connect()
+	if err != nil {
+		return err
+	}
+	externalNetworks, err := client.ExternalNetworks.List(accountID)
+	if err != nil {
+		return err
+	}
+	return c.print(externalNetworks, "ID", "Name", "Network", "Subnetmask", "Gateway")
+}
+
+func externalNetworksGet(c *cli, args []string) error {
+	fs := c.flags("externalnetworks", "get")
+	account := fs.String("account", "", "account name or ID, used to resolve the external network name")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	nameOrID, err := singleArg(fs, "external network")
+	if err != nil {
+		return err
+	}
+	id, err := c.resolveExternalNetwork(nameOrID, *account)
+	if err != nil {
+		return err
+	}
+	client, err := c.connect()
+	if err != nil {
+		return err
+	}
+	externalNetwork, err := client.ExternalNetworks.Get(id)
+	if err != nil {
+		return err
+	}
+	return c.print(externalNetwork, "ID", "Name", "Network", "Subnetmask", "Gateway", "DHCP")
+}
+
+func externalNetworksUsage(c *cli, args []string) error {
+	fs := c.flags("externalnetworks", "usage")
+	account := fs.String("account", "", "account name or ID, limits the addresses listed")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	nameOrID, err := singleArg(fs, "external network")
+	if err != nil {
+		return err
+	}
+	id, err := c.resolveExternalNetwork(nameOrID, *account)
+	if err != nil {
+		return err
+	}
+	accountID := 0
+	if *account != "" {
+		if accountID, err = c.resolveAccount(*account); err != nil {
+			return err
+		}
+	}
+	client, err := c.connect()
+	if err != nil {
+		return err
+	}
+	usage, err := client.ExternalNetworks.Usage(id, accountID)
+	if err != nil {
+		return err
+	}
+	if c.output != outputTable {
+		return c.print(usage)
+	}
+	if err := c.print(usage.Used, "IP", "CloudSpaceID", "MachineID"); err != nil {
+		return err
+	}
+	return c.printMessage("%d of %d addresses free", usage.Free, usage.Size)
+}
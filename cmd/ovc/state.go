@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/gig-tech/ovc-sdk-go/v4/ovc/reconcile"
+)
+
+func init() {
+	resources["state"] = map[string]command{
+		"plan":  {"show the changes needed to reach a desired state document", statePlan},
+		"apply": {"apply a desired state document", stateApply},
+	}
+}
+
+// statePlanner parses the flags shared by state plan and apply and returns
+// the reconciler and the plan for the document
+func (c *cli) statePlanner(resource string, verb string, args []string) (*reconcile.Reconciler, *reconcile.Plan, error) {
+	fs := c.flags(resource, verb)
+	file := fs.String("f", "", "desired state document (YAML or JSON)")
+	prune := fs.Bool("prune", false, "delete machines, disks and tunnels that are not in the document")
+	permanently := fs.Bool("permanently", false, "delete machines and disks permanently")
+	if err := fs.Parse(args); err != nil {
+		return nil, nil, err
+	}
+	if *file == "" {
+		return nil, nil, fmt.Errorf("no desired state document given, pass -f")
+	}
+	f, err := os.Open(*file)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+	doc, err := reconcile.ReadDocument(f)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	client, err := c.connect()
+	if err != nil {
+		return nil, nil, err
+	}
+	reconciler := reconcile.NewReconciler(client, reconcile.Options{Prune: *prune, PermanentlyDelete: *permanently})
+	plan, err := reconciler.Plan(doc)
+	if err != nil {
+		return nil, nil, err
+	}
+	return reconciler, plan, nil
+}
+
+func statePlan(c *cli, args []string) error {
+	_, plan, err := c.statePlanner("state", "plan", args)
+	if err != nil {
+		return err
+	}
+	if c.output != outputTable {
+		return c.print(plan)
+	}
+	_, err = fmt.Fprint(c.out, plan)
+	return err
+}
+
+func stateApply(c *cli, args []string) error {
+	reconciler, plan, err := c.statePlanner("state", "apply", args)
+	if err != nil {
+		return err
+	}
+	fmt.Fprint(c.out, plan)
+	if plan.Empty() {
+		return nil
+	}
+	result, err := reconciler.Apply(plan)
+	for _, step := range result.Applied {
+		fmt.Fprintf(c.out, "done:    %s\n", step)
+	}
+	for _, failure := range result.Failed {
+		fmt.Fprintf(c.out, "failed:  %s: %s\n", failure.Step, failure.Err)
+	}
+	for _, step := range result.Skipped {
+		fmt.Fprintf(c.out, "skipped: %s\n", step)
+	}
+	return err
+}
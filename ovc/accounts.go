@@ -3,6 +3,8 @@ package ovc
 import (
 	"encoding/json"
 	"errors"
+	"strconv"
+	"time"
 )
 
 // Account contains
@@ -31,11 +33,94 @@ type AccountInfo struct {
 	ACL          []AccountACL `json:"acl"`
 }
 
+// AccountDetails contains all information related to an account
+// Returned when using the Get method
+type AccountDetails struct {
+	ID             int            `json:"id"`
+	Name           string         `json:"name"`
+	Status         string         `json:"status"`
+	CreationTime   int            `json:"creationTime"`
+	UpdateTime     int            `json:"updateTime"`
+	ResourceLimits ResourceLimits `json:"resourceLimits"`
+	ACL            []AccountACL   `json:"acl"`
+}
+
+// AccountAccess grants a user access to an account when creating it
+type AccountAccess struct {
+	UserID string
+	Right  ACLRight
+}
+
+// AccountConfig is used when creating or updating an account. Limits of -1
+// are unlimited.
+type AccountConfig struct {
+	AccountID int    `json:"accountId,omitempty"`
+	Name      string `json:"name,omitempty"`
+	// Username is the owner of the account, it gets admin access
+	Username string `json:"username,omitempty"`
+	// EmailAddress receives the billing information of the account
+	EmailAddress           string  `json:"emailaddress,omitempty"`
+	Location               string  `json:"location,omitempty"`
+	MaxMemoryCapacity      float64 `json:"maxMemoryCapacity,omitempty"`
+	MaxCPUCapacity         int     `json:"maxCPUCapacity,omitempty"`
+	MaxDiskCapacity        int     `json:"maxVDiskCapacity,omitempty"`
+	MaxNetworkPeerTransfer int     `json:"maxNetworkPeerTransfer,omitempty"`
+	MaxNumPublicIP         int     `json:"maxNumPublicIP,omitempty"`
+	// Access lists additional users to grant access to a new account
+	Access []AccountAccess `json:"-"`
+}
+
+// Validate checks an AccountConfig used to create an account
+func (c *AccountConfig) Validate() error {
+	v := &validator{}
+	v.required("name", c.Name)
+	v.required("username", c.Username)
+	for field, limit := range map[string]float64{
+		"maxMemoryCapacity":      c.MaxMemoryCapacity,
+		"maxCPUCapacity":         float64(c.MaxCPUCapacity),
+		"maxVDiskCapacity":       float64(c.MaxDiskCapacity),
+		"maxNetworkPeerTransfer": float64(c.MaxNetworkPeerTransfer),
+		"maxNumPublicIP":         float64(c.MaxNumPublicIP),
+	} {
+		if limit < -1 {
+			v.add(field, "must be -1 (unlimited) or more, got %v", limit)
+		}
+	}
+	for i, access := range c.Access {
+		if access.UserID == "" {
+			v.add("access", "user of entry %d is required", i)
+		} else if err := access.Right.Validate(); err != nil {
+			v.add("access", "%s: %s", access.UserID, err)
+		}
+	}
+	return v.err()
+}
+
+// AccountDeleteConfig is used to delete an account
+type AccountDeleteConfig struct {
+	AccountID   int    `json:"accountId"`
+	Reason      string `json:"reason"`
+	Permanently bool   `json:"permanently"`
+}
+
 // AccountService is an interface for interfacing with the Account
-// endpoints of the OVC API
+// endpoints of the OVC API. Creating, deleting and restoring accounts is only
+// possible through the cloudbroker (G8 administrator) API, the other methods
+// use the cloudapi so account administrators can call them.
 type AccountService interface {
 	GetIDByName(string) (int, error)
 	List() (*[]AccountInfo, error)
+	Get(int) (*AccountDetails, error)
+	Create(*AccountConfig) (int, error)
+	Update(*AccountConfig) error
+	Delete(*AccountDeleteConfig) error
+	Restore(int, string) error
+	AddUser(int, string, ACLRight) error
+	UpdateUser(int, string, ACLRight) error
+	DeleteUser(int, string, bool) error
+	GetConsumedCloudUnits(int) (*CloudUnits, error)
+	GetReservedCloudUnits(int) (*CloudUnits, error)
+	GetConsumption(int, time.Time, time.Time) ([]byte, error)
 }
 
 // AccountServiceOp handles communication with the account related methods of the
@@ -74,3 +159,70 @@ func (s *AccountServiceOp) List() (*[]AccountInfo, error) {
 
 	return accounts, nil
 }
+
+// Get individual account
+func (s *AccountServiceOp) Get(id int) (*AccountDetails, error) {
+	accountMap := make(map[string]interface{})
+	accountMap["accountId"] = id
+
+	body, err := s.client.Post("/cloudapi/accounts/get", accountMap, ModelActionTimeout)
+	if err != nil {
+		return nil, err
+	}
+	account := new(AccountDetails)
+	err = json.Unmarshal(body, &account)
+	if err != nil {
+		return nil, err
+	}
+
+	return account, nil
+}
+
+// Create a new account and grant the users of the access list access to it.
+// Requires G8 administrator rights, the cloudapi can't create accounts.
+func (s *AccountServiceOp) Create(accountConfig *AccountConfig) (int, error) {
+	if err := accountConfig.Validate(); err != nil {
+		return 0, err
+	}
+	body, err := s.client.Post("/cloudbroker/account/create", *accountConfig, ModelActionTimeout)
+	if err != nil {
+		return 0, err
+	}
+	id, err := strconv.Atoi(string(body))
+	if err != nil {
+		return 0, err
+	}
+
+	for _, access := range accountConfig.Access {
+		if err := s.AddUser(id, access.UserID, access.Right); err != nil {
+			return id, err
+		}
+	}
+
+	return id, nil
+}
+
+// Update the name and resource limits of an account, allowed for account
+// administrators
+func (s *AccountServiceOp) Update(accountConfig *AccountConfig) error {
+	_, err := s.client.Post("/cloudapi/accounts/update", *accountConfig, ModelActionTimeout)
+	return err
+}
+
+// Delete an account, an account that isn't deleted permanently can be
+// restored. Requires G8 administrator rights, the cloudapi can't delete
+// accounts.
+func (s *AccountServiceOp) Delete(accountDeleteConfig *AccountDeleteConfig) error {
+	_, err := s.client.Post("/cloudbroker/account/delete", *accountDeleteConfig, OperationalActionTimeout)
+	return err
+}
+
+// Restore a deleted account, requires G8 administrator rights
+func (s *AccountServiceOp) Restore(id int, reason string) error {
+	accountMap := make(map[string]interface{})
+	accountMap["accountId"] = id
+	accountMap["reason"] = reason
+
+	_, err := s.client.Post("/cloudbroker/account/restore", accountMap, OperationalActionTimeout)
+	return err
+}
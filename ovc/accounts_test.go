@@ -0,0 +1,63 @@
+package ovc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAccountLifecycle(t *testing.T) {
+	calls := []string{}
+	client, stop := newTestClient(t, func(endpoint string, params map[string]interface{}) (interface{}, bool) {
+		calls = append(calls, endpoint)
+		switch endpoint {
+		case "/cloudbroker/account/create":
+			assert.Equal(t, map[string]interface{}{"name": "acme", "username": "alice", "maxCPUCapacity": float64(-1)}, params)
+			return 7, true
+		case "/cloudapi/accounts/addUser":
+			assert.Equal(t, map[string]interface{}{"accountId": float64(7), "userId": "bob", "accesstype": "R"}, params)
+			return true, true
+		case "/cloudapi/accounts/get":
+			assert.Equal(t, map[string]interface{}{"accountId": float64(7)}, params)
+			return map[string]interface{}{"id": 7, "name": "acme", "status": "CONFIRMED", "resourceLimits": map[string]interface{}{"CU_C": -1}}, true
+		case "/cloudapi/accounts/update":
+			assert.Equal(t, map[string]interface{}{"accountId": float64(7), "name": "acme-corp", "maxNumPublicIP": float64(4)}, params)
+			return true, true
+		case "/cloudbroker/account/delete":
+			assert.Equal(t, map[string]interface{}{"accountId": float64(7), "reason": "closed", "permanently": false}, params)
+			return true, true
+		case "/cloudbroker/account/restore":
+			assert.Equal(t, map[string]interface{}{"accountId": float64(7), "reason": "reopened"}, params)
+			return true, true
+		}
+		return "unexpected call to " + endpoint, false
+	})
+	defer stop()
+	client.Accounts = &AccountServiceOp{client: client}
+
+	id, err := client.Accounts.Create(&AccountConfig{Name: "acme", Username: "alice", MaxCPUCapacity: -1,
+		Access: []AccountAccess{{UserID: "bob", Right: ACLRightRead}}})
+	assert.NoError(t, err)
+	assert.Equal(t, 7, id)
+
+	account, err := client.Accounts.Get(7)
+	assert.NoError(t, err)
+	assert.Equal(t, "acme", account.Name)
+	assert.Equal(t, -1, account.ResourceLimits.CUC)
+
+	assert.NoError(t, client.Accounts.Update(&AccountConfig{AccountID: 7, Name: "acme-corp", MaxNumPublicIP: 4}))
+	assert.NoError(t, client.Accounts.Delete(&AccountDeleteConfig{AccountID: 7, Reason: "closed"}))
+	assert.NoError(t, client.Accounts.Restore(7, "reopened"))
+	assert.Equal(t, []string{
+		"/cloudbroker/account/create",
+		"/cloudapi/accounts/addUser",
+		"/cloudapi/accounts/get",
+		"/cloudapi/accounts/update",
+		"/cloudbroker/account/delete",
+		"/cloudbroker/account/restore",
+	}, calls)
+
+	_, err = client.Accounts.Create(&AccountConfig{Name: "acme"})
+	assert.Error(t, err, "accounts without owner should be rejected")
+	assert.Len(t, calls, 6)
+}
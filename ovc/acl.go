@@ -0,0 +1,206 @@
+package ovc
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ACLRight is a set of access rights on an account, cloudspace or machine,
+// each letter grants a permission: A(dmin), R(ead), C(reate), (e)X(ecute),
+// D(elete) and U(ser management)
+type ACLRight string
+
+// Access rights as offered by the G8 portal
+const (
+	ACLRightRead  ACLRight = "R"
+	ACLRightWrite ACLRight = "RCX"
+	ACLRightAdmin ACLRight = "ARCXDU"
+)
+
+// aclPermissions lists all permissions in their canonical order
+const aclPermissions = "ARCXDU"
+
+// Validate checks that the right only consists of known permissions
+func (r ACLRight) Validate() error {
+	if r == "" {
+		return fmt.Errorf("Access right is empty")
+	}
+	for _, p := range string(r) {
+		if !strings.ContainsRune(aclPermissions, p) {
+			return fmt.Errorf("Unknown permission %q in access right %s", p, r)
+		}
+	}
+	return nil
+}
+
+// Has reports whether r grants all permissions of other
+func (r ACLRight) Has(other ACLRight) bool {
+	for _, p := range string(other) {
+		if !strings.ContainsRune(string(r), p) {
+			return false
+		}
+	}
+	return true
+}
+
+// Union returns the permissions granted by either r or other
+func (r ACLRight) Union(other ACLRight) ACLRight {
+	union := ""
+	for _, p := range aclPermissions {
+		if strings.ContainsRune(string(r), p) || strings.ContainsRune(string(other), p) {
+			union += string(p)
+		}
+	}
+	return ACLRight(union)
+}
+
+// sameUser compares user IDs with or without the identity provider suffix
+func sameUser(a string, b string) bool {
+	return strings.TrimSuffix(a, "@itsyouonline") == strings.TrimSuffix(b, "@itsyouonline")
+}
+
+// userRights returns the rights granted to a user by a list of ACL entries
+func userRights(userID string, entries []ACL) ACLRight {
+	rights := ACLRight("")
+	for _, entry := range entries {
+		if entry.Status != "DELETED" && sameUser(entry.UserGroupID, userID) {
+			rights = rights.Union(ACLRight(entry.Right))
+		}
+	}
+	return rights
+}
+
+func (s *AccountServiceOp) postUser(action string, accountID int, userID string, right ACLRight) error {
+	if err := right.Validate(); err != nil {
+		return err
+	}
+	accountMap := make(map[string]interface{})
+	accountMap["accountId"] = accountID
+	accountMap["userId"] = userID
+	accountMap["accesstype"] = string(right)
+
+	_, err := s.client.Post("/cloudapi/accounts/"+action, accountMap, ModelActionTimeout)
+	return err
+}
+
+// AddUser grants a user access to an account
+func (s *AccountServiceOp) AddUser(accountID int, userID string, right ACLRight) error {
+	return s.postUser("addUser", accountID, userID, right)
+}
+
+// UpdateUser changes the access right of a user on an account
+func (s *AccountServiceOp) UpdateUser(accountID int, userID string, right ACLRight) error {
+	return s.postUser("updateUser", accountID, userID, right)
+}
+
+// DeleteUser revokes the access of a user to an account, recursive also
+// revokes access to all cloudspaces and machines of the account
+func (s *AccountServiceOp) DeleteUser(accountID int, userID string, recursive bool) error {
+	accountMap := make(map[string]interface{})
+	accountMap["accountId"] = accountID
+	accountMap["userId"] = userID
+	accountMap["recursivedelete"] = recursive
+
+	_, err := s.client.Post("/cloudapi/accounts/deleteUser", accountMap, ModelActionTimeout)
+	return err
+}
+
+func (s *CloudSpaceServiceOp) postUser(action string, cloudSpaceID int, userID string, right ACLRight) error {
+	if err := right.Validate(); err != nil {
+		return err
+	}
+	cloudSpaceMap := make(map[string]interface{})
+	cloudSpaceMap["cloudspaceId"] = cloudSpaceID
+	cloudSpaceMap["userId"] = userID
+	cloudSpaceMap["accesstype"] = string(right)
+
+	_, err := s.client.Post("/cloudapi/cloudspaces/"+action, cloudSpaceMap, ModelActionTimeout)
+	return err
+}
+
+// AddUser grants a user access to a cloudspace
+func (s *CloudSpaceServiceOp) AddUser(cloudSpaceID int, userID string, right ACLRight) error {
+	return s.postUser("addUser", cloudSpaceID, userID, right)
+}
+
+// UpdateUser changes the access right of a user on a cloudspace
+func (s *CloudSpaceServiceOp) UpdateUser(cloudSpaceID int, userID string, right ACLRight) error {
+	return s.postUser("updateUser", cloudSpaceID, userID, right)
+}
+
+// DeleteUser revokes the access of a user to a cloudspace, recursive also
+// revokes access to all machines of the cloudspace
+func (s *CloudSpaceServiceOp) DeleteUser(cloudSpaceID int, userID string, recursive bool) error {
+	cloudSpaceMap := make(map[string]interface{})
+	cloudSpaceMap["cloudspaceId"] = cloudSpaceID
+	cloudSpaceMap["userId"] = userID
+	cloudSpaceMap["recursivedelete"] = recursive
+
+	_, err := s.client.Post("/cloudapi/cloudspaces/deleteUser", cloudSpaceMap, ModelActionTimeout)
+	return err
+}
+
+func (s *MachineServiceOp) postUser(action string, machineID int, userID string, right ACLRight) error {
+	if err := right.Validate(); err != nil {
+		return err
+	}
+	machineMap := make(map[string]interface{})
+	machineMap["machineId"] = machineID
+	machineMap["userId"] = userID
+	machineMap["accessType"] = string(right)
+
+	_, err := s.client.Post("/cloudapi/machines/"+action, machineMap, ModelActionTimeout)
+	return err
+}
+
+// AddUser grants a user access to a machine
+func (s *MachineServiceOp) AddUser(machineID int, userID string, right ACLRight) error {
+	return s.postUser("addUser", machineID, userID, right)
+}
+
+// UpdateUser changes the access right of a user on a machine
+func (s *MachineServiceOp) UpdateUser(machineID int, userID string, right ACLRight) error {
+	return s.postUser("updateUser", machineID, userID, right)
+}
+
+// DeleteUser revokes the access of a user to a machine
+func (s *MachineServiceOp) DeleteUser(machineID int, userID string) error {
+	machineMap := make(map[string]interface{})
+	machineMap["machineId"] = machineID
+	machineMap["userId"] = userID
+
+	_, err := s.client.Post("/cloudapi/machines/deleteUser", machineMap, ModelActionTimeout)
+	return err
+}
+
+// EffectiveRights returns the rights of a user on a machine, combining the
+// access granted on the machine, its cloudspace and its account. Only direct
+// grants to the user are taken into account, not grants to groups.
+func (s *MachineServiceOp) EffectiveRights(machineID int, userID string) (ACLRight, error) {
+	machine, err := s.client.Machines.Get(machineID)
+	if err != nil {
+		return "", err
+	}
+	cloudSpace, err := s.client.CloudSpaces.Get(machine.CloudspaceID)
+	if err != nil {
+		return "", err
+	}
+	accounts, err := s.client.Accounts.List()
+	if err != nil {
+		return "", err
+	}
+
+	rights := userRights(userID, machine.ACL).Union(userRights(userID, cloudSpace.ACL))
+	for _, account := range *accounts {
+		if account.ID != cloudSpace.AccountID {
+			continue
+		}
+		entries := make([]ACL, len(account.ACL))
+		for i, entry := range account.ACL {
+			entries[i] = ACL{Status: entry.Status, Right: entry.Right, Type: entry.Type, UserGroupID: entry.UserGroupID}
+		}
+		rights = rights.Union(userRights(userID, entries))
+	}
+
+	return rights, nil
+}
@@ -0,0 +1,54 @@
+package ovc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestACLRight(t *testing.T) {
+	assert.NoError(t, ACLRightAdmin.Validate())
+	assert.Error(t, ACLRight("RWX").Validate())
+	assert.True(t, ACLRightAdmin.Has(ACLRightWrite))
+	assert.False(t, ACLRightWrite.Has(ACLRight("D")))
+	assert.Equal(t, ACLRight("RCXD"), ACLRight("DR").Union(ACLRightWrite))
+
+	entries := []ACL{
+		{UserGroupID: "jdoe@itsyouonline", Right: "R", Status: "CONFIRMED"},
+		{UserGroupID: "jdoe", Right: "CX", Status: "CONFIRMED"},
+		{UserGroupID: "jdoe", Right: "D", Status: "DELETED"},
+		{UserGroupID: "other", Right: "ARCXDU", Status: "CONFIRMED"},
+	}
+	assert.Equal(t, ACLRightWrite, userRights("jdoe@itsyouonline", entries))
+}
+
+func TestEffectiveRights(t *testing.T) {
+	client, stop := newTestClient(t, func(endpoint string, params map[string]interface{}) (interface{}, bool) {
+		switch endpoint {
+		case "/cloudapi/machines/get":
+			return map[string]interface{}{"id": 1, "cloudspaceid": 3, "acl": []ACL{
+				{UserGroupID: "other", Right: "ARCXDU", Status: "CONFIRMED"},
+			}}, true
+		case "/cloudapi/cloudspaces/get":
+			return map[string]interface{}{"id": 3, "accountId": 7, "acl": []ACL{
+				{UserGroupID: "jdoe", Right: "R", Status: "CONFIRMED"},
+			}}, true
+		case "/cloudapi/accounts/list":
+			return []AccountInfo{
+				{ID: 6, ACL: []AccountACL{{UserGroupID: "jdoe", Right: "ARCXDU", Status: "CONFIRMED"}}},
+				{ID: 7, ACL: []AccountACL{
+					{UserGroupID: "jdoe@itsyouonline", Right: "CX", Status: "CONFIRMED"},
+					{UserGroupID: "jdoe", Right: "D", Status: "DELETED"},
+				}},
+			}, true
+		}
+		return "unexpected call to " + endpoint, false
+	})
+	defer stop()
+	client.CloudSpaces = &CloudSpaceServiceOp{client: client}
+	client.Accounts = &AccountServiceOp{client: client}
+
+	rights, err := client.Machines.EffectiveRights(1, "jdoe")
+	assert.NoError(t, err)
+	assert.Equal(t, ACLRightWrite, rights, "the cloudspace and account rights should be combined")
+}
@@ -0,0 +1,256 @@
+package ovc
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// AuditOutcomeSuccess is recorded when a mutating call completed successfully
+	AuditOutcomeSuccess = "success"
+	// AuditOutcomeFailure is recorded when a mutating call returned an error
+	AuditOutcomeFailure = "failure"
+
+	auditRedacted = "********"
+)
+
+// sensitiveAuditKeys contains the (lower case) fragments of parameter names
+// whose values are never written to the audit journal
+var sensitiveAuditKeys = []string{"password", "passwd", "secret", "psk", "token", "userdata"}
+
+// AuditRecord describes a single mutating call issued against the G8 API
+type AuditRecord struct {
+	Timestamp  time.Time              `json:"timestamp"`
+	Identity   string                 `json:"identity"`
+	Location   string                 `json:"location"`
+	Endpoint   string                 `json:"endpoint"`
+	Parameters map[string]interface{} `json:"parameters,omitempty"`
+	TaskGUID   string                 `json:"taskGuid,omitempty"`
+	Outcome    string                 `json:"outcome"`
+	Error      string                 `json:"error,omitempty"`
+	DurationMS int64                  `json:"durationMs"`
+}
+
+// AuditSink receives an AuditRecord for every mutating call made by the Client
+type AuditSink interface {
+	Record(*AuditRecord) error
+}
+
+// JSONAuditSink writes every AuditRecord as a single line of JSON to a writer
+type JSONAuditSink struct {
+	mu      sync.Mutex
+	w       io.Writer
+	encoder *json.Encoder
+}
+
+// NewJSONAuditSink returns an AuditSink writing JSON lines to w
+func NewJSONAuditSink(w io.Writer) *JSONAuditSink {
+	return &JSONAuditSink{
+		w:       w,
+		encoder: json.NewEncoder(w),
+	}
+}
+
+// NewRotatingFileAuditSink returns an AuditSink writing JSON lines to a file
+// that is rotated once it grows beyond maxSize bytes
+func NewRotatingFileAuditSink(filename string, maxSize int64, maxBackups int) (*JSONAuditSink, error) {
+	file, err := NewRotatingFile(filename, maxSize, maxBackups)
+	if err != nil {
+		return nil, err
+	}
+	return NewJSONAuditSink(file), nil
+}
+
+// Record implements AuditSink.Record
+func (s *JSONAuditSink) Record(record *AuditRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.encoder.Encode(record)
+}
+
+// Close closes the underlying writer if it can be closed
+func (s *JSONAuditSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if closer, ok := s.w.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// RotatingFile is an io.WriteCloser appending to a file which is rotated to
+// filename.1, filename.2, ... once it would grow beyond its maximum size.
+// Only maxBackups rotated files are kept.
+type RotatingFile struct {
+	mu         sync.Mutex
+	filename   string
+	maxSize    int64
+	maxBackups int
+	file       *os.File
+	size       int64
+}
+
+// NewRotatingFile opens (or creates) filename for appending
+func NewRotatingFile(filename string, maxSize int64, maxBackups int) (*RotatingFile, error) {
+	if maxSize <= 0 {
+		return nil, fmt.Errorf("maximum file size must be positive, got %d", maxSize)
+	}
+	if maxBackups < 0 {
+		return nil, fmt.Errorf("number of backups can't be negative, got %d", maxBackups)
+	}
+	r := &RotatingFile{
+		filename:   filename,
+		maxSize:    maxSize,
+		maxBackups: maxBackups,
+	}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Write implements io.Writer. A single write is never split over two files.
+func (r *RotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil {
+		return 0, os.ErrClosed
+	}
+	if r.size > 0 && r.size+int64(len(p)) > r.maxSize {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// Close closes the current file
+func (r *RotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
+
+func (r *RotatingFile) open() error {
+	file, err := os.OpenFile(r.filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	r.file = file
+	r.size = info.Size()
+	return nil
+}
+
+func (r *RotatingFile) rotate() error {
+	if err := r.file.Close(); err != nil {
+		return err
+	}
+	r.file = nil
+	if r.maxBackups == 0 {
+		if err := os.Remove(r.filename); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return r.open()
+	}
+	for i := r.maxBackups - 1; i > 0; i-- {
+		err := os.Rename(r.backupName(i), r.backupName(i+1))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if err := os.Rename(r.filename, r.backupName(1)); err != nil {
+		return err
+	}
+	return r.open()
+}
+
+func (r *RotatingFile) backupName(i int) string {
+	return fmt.Sprintf("%s.%d", r.filename, i)
+}
+
+// isMutatingEndpoint reports whether a call to endpoint changes state on the G8.
+// Only list and get style calls are considered read-only.
+func isMutatingEndpoint(endpoint string) bool {
+	method := path.Base(endpoint)
+	return !strings.HasPrefix(method, "list") && !strings.HasPrefix(method, "get")
+}
+
+// sanitizeAuditParameters returns a copy of params with the values of
+// sensitive keys redacted
+func sanitizeAuditParameters(params map[string]interface{}) map[string]interface{} {
+	if params == nil {
+		return nil
+	}
+	sanitized := make(map[string]interface{}, len(params))
+	for key, value := range params {
+		if isSensitiveAuditKey(key) {
+			sanitized[key] = auditRedacted
+			continue
+		}
+		if nested, ok := value.(map[string]interface{}); ok {
+			value = sanitizeAuditParameters(nested)
+		}
+		sanitized[key] = value
+	}
+	return sanitized
+}
+
+func isSensitiveAuditKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, fragment := range sensitiveAuditKeys {
+		if strings.Contains(key, fragment) {
+			return true
+		}
+	}
+	return false
+}
+
+// audit records the outcome of a mutating call in the configured AuditSink
+func (c *Client) audit(endpoint string, params map[string]interface{}, start time.Time, taskID string, callErr error) {
+	record := &AuditRecord{
+		Timestamp:  start.UTC(),
+		Identity:   c.auditIdentity(),
+		Location:   c.GetLocation(),
+		Endpoint:   endpoint,
+		Parameters: sanitizeAuditParameters(params),
+		TaskGUID:   taskID,
+		Outcome:    AuditOutcomeSuccess,
+		DurationMS: int64(time.Since(start) / time.Millisecond),
+	}
+	if callErr != nil {
+		record.Outcome = AuditOutcomeFailure
+		record.Error = callErr.Error()
+	}
+	if err := c.auditSink.Record(record); err != nil {
+		c.logger.Errorf("Failed to write audit record for %s: %s", endpoint, err)
+	}
+}
+
+func (c *Client) auditIdentity() string {
+	username, err := c.JWT.Claim("username")
+	if err != nil {
+		return c.Access
+	}
+	if name, ok := username.(string); ok {
+		return name
+	}
+	return c.Access
+}
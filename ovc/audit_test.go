@@ -0,0 +1,105 @@
+package ovc
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type memoryAuditSink struct {
+	records []*AuditRecord
+}
+
+func (s *memoryAuditSink) Record(record *AuditRecord) error {
+	s.records = append(s.records, record)
+	return nil
+}
+
+func TestAuditedCalls(t *testing.T) {
+	client, done := newTestClient(t, func(endpoint string, params map[string]interface{}) (interface{}, bool) {
+		if endpoint == "/cloudapi/machines/delete" {
+			return "machine is locked", false
+		}
+		return 1, true
+	})
+	defer done()
+	sink := &memoryAuditSink{}
+	client.auditSink = sink
+
+	_, err := client.Post("/cloudapi/machines/create", map[string]interface{}{"name": "vm", "userdata": "secret"}, ModelActionTimeout)
+	assert.NoError(t, err)
+	_, err = client.Post("/cloudapi/machines/list", map[string]interface{}{"cloudspaceId": 1}, ModelActionTimeout)
+	assert.NoError(t, err)
+	_, err = client.Post("/cloudapi/machines/delete", map[string]interface{}{"machineId": 1}, ModelActionTimeout)
+	assert.Error(t, err)
+
+	if assert.Len(t, sink.records, 2, "read-only calls should not be audited") {
+		created := sink.records[0]
+		assert.Equal(t, "tester", created.Identity)
+		assert.Equal(t, "127", created.Location)
+		assert.Equal(t, "/cloudapi/machines/create", created.Endpoint)
+		assert.Equal(t, "task-0-/cloudapi/machines/create", created.TaskGUID)
+		assert.Equal(t, AuditOutcomeSuccess, created.Outcome)
+		assert.Equal(t, "vm", created.Parameters["name"])
+		assert.Equal(t, auditRedacted, created.Parameters["userdata"])
+
+		deleted := sink.records[1]
+		assert.Equal(t, AuditOutcomeFailure, deleted.Outcome)
+		assert.NotEmpty(t, deleted.Error)
+	}
+}
+
+func TestSanitizeAuditParameters(t *testing.T) {
+	params := map[string]interface{}{
+		"cloudspaceId": 3,
+		"pskSecret":    "foo",
+		"nested":       map[string]interface{}{"Password": "bar", "login": "user"},
+	}
+	sanitized := sanitizeAuditParameters(params)
+	assert.Equal(t, 3, sanitized["cloudspaceId"])
+	assert.Equal(t, auditRedacted, sanitized["pskSecret"])
+	assert.Equal(t, map[string]interface{}{"Password": auditRedacted, "login": "user"}, sanitized["nested"])
+	assert.Equal(t, "foo", params["pskSecret"], "the original parameters should not be modified")
+}
+
+func TestIsMutatingEndpoint(t *testing.T) {
+	assert.False(t, isMutatingEndpoint("/cloudapi/machines/list"))
+	assert.False(t, isMutatingEndpoint("/cloudapi/machines/getByReferenceId"))
+	assert.False(t, isMutatingEndpoint("/cloudapi/ipsec/listTunnels"))
+	assert.True(t, isMutatingEndpoint("/cloudapi/machines/create"))
+	assert.True(t, isMutatingEndpoint("/cloudapi/disks/expose"))
+}
+
+func TestRotatingFileAuditSink(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ovc-audit")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "audit.log")
+
+	sink, err := NewRotatingFileAuditSink(filename, 200, 2)
+	assert.NoError(t, err)
+	for i := 0; i < 10; i++ {
+		assert.NoError(t, sink.Record(&AuditRecord{Endpoint: "/cloudapi/machines/start", Outcome: AuditOutcomeSuccess}))
+	}
+	assert.NoError(t, sink.Close())
+
+	for _, name := range []string{filename, filename + ".1", filename + ".2"} {
+		data, err := ioutil.ReadFile(name)
+		assert.NoError(t, err)
+		assert.True(t, len(data) <= 200, "%s should not exceed the maximum size", name)
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		for scanner.Scan() {
+			record := AuditRecord{}
+			assert.NoError(t, json.Unmarshal(scanner.Bytes(), &record))
+			assert.Equal(t, "/cloudapi/machines/start", record.Endpoint)
+		}
+	}
+	_, err = os.Stat(filename + ".3")
+	assert.True(t, os.IsNotExist(err), "only two backups should be kept")
+}
@@ -12,6 +12,7 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/sirupsen/logrus"
@@ -47,6 +48,14 @@ type Config struct {
 	// Use an appropriately configured logger instead.
 	Verbose bool
 	Logger  Logger
+	// AuditSink optionally receives a record of every mutating API call
+	AuditSink AuditSink
+	// QuotaChecks enables pre-flight checks against the resource limits of
+	// cloudspaces and accounts before creating or resizing machines and disks
+	QuotaChecks bool
+	// PublicPortRanges are the ranges random public ports of port forwards
+	// are allocated from, DefaultPortRange when empty
+	PublicPortRanges []PortRange
 }
 
 // Credentials used to authenticate
@@ -63,6 +72,13 @@ type Client struct {
 
 	logger         Logger
 	requestLimiter *limiter.Limiter
+	requestLimit   int
+	auditSink      AuditSink
+	quotaChecks    bool
+	portRanges     []PortRange
+
+	portAllocatorsMu sync.Mutex
+	portAllocators   map[string]*PortAllocator
 
 	Machines         MachineService
 	CloudSpaces      CloudSpaceService
@@ -75,6 +91,7 @@ type Client struct {
 	Ipsec            IpsecService
 	ExternalNetworks ExternalNetworkService
 	Locations        LocationService
+	Quotas           QuotaService
 }
 
 func setupLogger(c *Config) Logger {
@@ -132,6 +149,9 @@ func NewClient(c *Config) (*Client, error) {
 	client.Access = username.(string) + "@itsyouonline"
 
 	client.logger = logger
+	client.auditSink = c.AuditSink
+	client.quotaChecks = c.QuotaChecks
+	client.portRanges = c.PublicPortRanges
 
 	requestLimitConfiguration, found := os.LookupEnv("G8_API_CONCURRENT_REQUESTS")
 	limit := 5
@@ -142,6 +162,7 @@ func NewClient(c *Config) (*Client, error) {
 		}
 	}
 	client.requestLimiter = limiter.New(limit)
+	client.requestLimit = limit
 
 	client.Machines = &MachineServiceOp{client: client}
 	client.CloudSpaces = &CloudSpaceServiceOp{client: client}
@@ -154,6 +175,7 @@ func NewClient(c *Config) (*Client, error) {
 	client.Ipsec = &IpsecServiceOp{client: client}
 	client.ExternalNetworks = &ExternalNetworkServiceOp{client: client}
 	client.Locations = &LocationServiceOp{client: client}
+	client.Quotas = &QuotaServiceOp{client: client}
 
 	return client, nil
 }
@@ -200,17 +222,35 @@ func (c *Client) doHTTPRequest(client *http.Client, method string, url string, b
 	return client.Do(asyncReq)
 }
 
-// Do sends and API Request and returns the body as an array of bytes
+// do sends and API Request and returns the body as an array of bytes
 func (c *Client) do(req *http.Request, timeout ResponseTimeout) ([]byte, error) {
-	var requestTimeoutMultiplier int = 0
-	var requestErrorCount int = 0
-	var taskID string
-	client := &http.Client{}
+	body, _, err := c.doTask(req, timeout)
+	return body, err
+}
+
+// doTask sends an API request, waits for the resulting task and returns its
+// body together with the task GUID the G8 assigned to it
+func (c *Client) doTask(req *http.Request, timeout ResponseTimeout) ([]byte, string, error) {
+	client := &http.Client{Timeout: time.Duration(timeout)}
 	asyncBody, err := c.async(req)
 	if err != nil {
 		c.logger.Errorf("Failed to make request body async")
-		return nil, err
+		return nil, "", err
+	}
+	taskID, err := c.submitTask(client, req, asyncBody)
+	if err != nil {
+		return nil, taskID, err
 	}
+	body, err := c.waitForTask(client, req, taskID, timeout)
+	return body, taskID, err
+}
+
+// submitTask sends the async body of an API request and returns the GUID of
+// the task the G8 started for it
+func (c *Client) submitTask(client *http.Client, req *http.Request, asyncBody []byte) (string, error) {
+	var requestTimeoutMultiplier int = 0
+	var requestErrorCount int = 0
+	var taskID string
 	// Try to issue request, but retry if it would fail due 2 2 many concurrent requests
 	for {
 		resp, err := c.doHTTPRequest(client, req.Method, req.URL.String(), bytes.NewBuffer(asyncBody))
@@ -225,14 +265,14 @@ func (c *Client) do(req *http.Request, timeout ResponseTimeout) ([]byte, error)
 				continue
 			} else {
 				c.logger.Errorf("Could not do G8 Api request: %s", err)
-				return nil, err
+				return "", err
 			}
 		}
 
 		body, err := ioutil.ReadAll(resp.Body)
 		if err != nil {
 			c.logger.Errorf("Could not read response body: %s", err)
-			return nil, err
+			return "", err
 		}
 		taskID = string(body)
 
@@ -249,21 +289,25 @@ func (c *Client) do(req *http.Request, timeout ResponseTimeout) ([]byte, error)
 			continue
 		case resp.StatusCode == http.StatusUnauthorized:
 			c.logger.Errorf("Unauthorized: %s", ErrAuthentication)
-			return nil, ErrAuthentication
+			return "", ErrAuthentication
 		case resp.StatusCode == http.StatusTooManyRequests:
 			requestTimeoutMultiplier++
 			time.Sleep(time.Duration(requestTimeoutMultiplier) * time.Second)
 			continue
 		case resp.StatusCode > http.StatusAccepted:
 			c.logger.Errorf("Request failed with error: %s", err)
-			return body, errors.New(taskID)
+			return "", errors.New(taskID)
 		}
 		break
 	}
 
 	// remove quotes from taskID if contains any
-	taskID = strings.Replace(taskID, "\"", "", -1)
+	return strings.Replace(taskID, "\"", "", -1), nil
+}
 
+// waitForTask polls the task started for an API request until it is done and
+// returns its result
+func (c *Client) waitForTask(client *http.Client, req *http.Request, taskID string, timeout ResponseTimeout) ([]byte, error) {
 	// create request to get result of an async API call by job id
 	taskJSON, err := json.Marshal(
 		struct {
@@ -282,7 +326,7 @@ func (c *Client) do(req *http.Request, timeout ResponseTimeout) ([]byte, error)
 	start := time.Now()
 
 	// wait for result of the async task
-	requestErrorCount = 0
+	requestErrorCount := 0
 	fourOFourCount := 0
 	for {
 		if now := time.Now(); now.Sub(start) > time.Duration(timeout) {
@@ -384,7 +428,10 @@ func (c *Client) do(req *http.Request, timeout ResponseTimeout) ([]byte, error)
 func (c *Client) GetLocation() string {
 	u, _ := url.Parse(c.ServerURL)
 	hostName := u.Hostname()
-	return hostName[:strings.IndexByte(hostName, '.')]
+	if i := strings.IndexByte(hostName, '.'); i >= 0 {
+		return hostName[:i]
+	}
+	return hostName
 }
 
 // jwtFromIYO fetches a JWT into the itsyouonline platform using the config struct
@@ -417,6 +464,9 @@ func jwtFromIYO(c *Config) (string, error) {
 
 // PostRaw POSTs a request with `raw` as data (nil is permitted) to `c.ServerUrl + endpoint`
 func (c *Client) PostRaw(endpoint string, raw io.Reader, timeout ResponseTimeout) ([]byte, error) {
+	if c.auditSink != nil && isMutatingEndpoint(endpoint) {
+		return c.postAudited(endpoint, raw, timeout)
+	}
 	req, err := http.NewRequest("POST", c.ServerURL+endpoint, raw)
 	if err != nil {
 		return nil, err
@@ -424,6 +474,33 @@ func (c *Client) PostRaw(endpoint string, raw io.Reader, timeout ResponseTimeout
 	return c.do(req, timeout)
 }
 
+// postAudited POSTs a request like PostRaw and records the call in the audit sink
+func (c *Client) postAudited(endpoint string, raw io.Reader, timeout ResponseTimeout) ([]byte, error) {
+	var params map[string]interface{}
+	var reqBody io.Reader
+	if raw != nil {
+		data, err := ioutil.ReadAll(raw)
+		if err != nil {
+			return nil, err
+		}
+		if len(data) != 0 {
+			// parameters that can't be parsed are simply not recorded, the
+			// request itself will report the malformed body
+			_ = json.Unmarshal(data, &params)
+		}
+		reqBody = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest("POST", c.ServerURL+endpoint, reqBody)
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	body, taskID, err := c.doTask(req, timeout)
+	c.audit(endpoint, params, start, taskID, err)
+	return body, err
+}
+
 // Post marshals `in` to JSON and POSTs a request to `c.ServerUrl + endpoint`
 func (c *Client) Post(endpoint string, in interface{}, timeout ResponseTimeout) ([]byte, error) {
 	jsonIn, err := json.Marshal(in)
@@ -432,3 +509,32 @@ func (c *Client) Post(endpoint string, in interface{}, timeout ResponseTimeout)
 	}
 	return c.PostRaw(endpoint, bytes.NewBuffer(jsonIn), timeout)
 }
+
+// download marshals `in` to JSON and POSTs a synchronous request to
+// `c.ServerUrl + endpoint`, returning the response body as is. It is used for
+// endpoints returning files, which can't be fetched as the result of a task.
+func (c *Client) download(endpoint string, in interface{}, timeout ResponseTimeout) ([]byte, error) {
+	jsonIn, err := json.Marshal(in)
+	if err != nil {
+		return nil, err
+	}
+	client := &http.Client{Timeout: time.Duration(timeout)}
+	resp, err := c.doHTTPRequest(client, http.MethodPost, c.ServerURL+endpoint, bytes.NewBuffer(jsonIn))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized:
+		return nil, ErrAuthentication
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, ErrNotFound
+	case resp.StatusCode > http.StatusAccepted:
+		return nil, fmt.Errorf("Download of %s failed: %s", endpoint, body)
+	}
+	return body, nil
+}
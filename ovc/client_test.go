@@ -0,0 +1,71 @@
+package ovc
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/tidwall/limiter"
+)
+
+// newTestClient returns a Client talking to a fake G8 which answers every
+// call with a task whose result is produced by results. Synchronous calls are
+// answered with the result itself, as is when it is a []byte.
+func newTestClient(t *testing.T, results func(endpoint string, params map[string]interface{}) (interface{}, bool)) (*Client, func()) {
+	claims := map[string]string{"username": "tester"}
+	tokenStr, err := createJWT(t, time.Hour, "", claims)
+	assert.NoError(t, err)
+	logger := LogrusAdapter{logrus.New().WithField("source", "OpenvCloud client test")}
+	jwt, err := NewJWTFromIYO(tokenStr, logger)
+	assert.NoError(t, err)
+
+	var mu sync.Mutex
+	tasks := make(map[string][]interface{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		params := make(map[string]interface{})
+		body, _ := ioutil.ReadAll(r.Body)
+		_ = json.Unmarshal(body, &params)
+		if r.URL.Path == "/restmachine/system/task/get" {
+			json.NewEncoder(w).Encode(tasks[params["taskguid"].(string)])
+			return
+		}
+		_, async := params["_async"]
+		delete(params, "_async")
+		endpoint := r.URL.Path[len("/restmachine"):]
+		result, ok := results(endpoint, params)
+		if !async {
+			if !ok {
+				w.WriteHeader(http.StatusInternalServerError)
+			}
+			if data, isBytes := result.([]byte); isBytes {
+				w.Write(data)
+			} else {
+				json.NewEncoder(w).Encode(result)
+			}
+			return
+		}
+		guid := fmt.Sprintf("task-%d-%s", len(tasks), endpoint)
+		tasks[guid] = []interface{}{ok, result}
+		data, _ := json.Marshal(guid)
+		w.Write(data)
+	}))
+
+	client := &Client{
+		JWT:            jwt,
+		ServerURL:      srv.URL + "/restmachine",
+		logger:         logger,
+		requestLimiter: limiter.New(2),
+		requestLimit:   2,
+	}
+	client.Machines = &MachineServiceOp{client: client}
+	return client, srv.Close
+}
@@ -0,0 +1,106 @@
+package ovc
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// CloudUnits holds an amount of each type of cloud unit
+type CloudUnits struct {
+	// Memory in GB
+	CUM float64 `json:"CU_M"`
+	// Vcpus
+	CUC float64 `json:"CU_C"`
+	// Disk capacity in GB
+	CUD float64 `json:"CU_D"`
+	// Primary storage (SSD) in GB
+	CUS float64 `json:"CU_S"`
+	// Secondary storage (archive) in TB
+	CUA float64 `json:"CU_A"`
+	// Transfer over external networks in GB
+	CUNO float64 `json:"CU_NO"`
+	// Transfer between cloudspaces (peering) in GB
+	CUNP float64 `json:"CU_NP"`
+	// Public IP addresses
+	CUI float64 `json:"CU_I"`
+}
+
+func (s *AccountServiceOp) cloudUnits(endpoint string, id int) (*CloudUnits, error) {
+	accountMap := make(map[string]interface{})
+	accountMap["accountId"] = id
+
+	body, err := s.client.Post("/cloudapi/accounts/"+endpoint, accountMap, ModelActionTimeout)
+	if err != nil {
+		return nil, err
+	}
+	units := new(CloudUnits)
+	err = json.Unmarshal(body, &units)
+	if err != nil {
+		return nil, err
+	}
+
+	return units, nil
+}
+
+// GetConsumedCloudUnits returns the cloud units consumed by an account
+func (s *AccountServiceOp) GetConsumedCloudUnits(id int) (*CloudUnits, error) {
+	return s.cloudUnits("getConsumedCloudUnits", id)
+}
+
+// GetReservedCloudUnits returns the cloud units reserved by the resources of
+// an account
+func (s *AccountServiceOp) GetReservedCloudUnits(id int) (*CloudUnits, error) {
+	return s.cloudUnits("getReservedAccountUnits", id)
+}
+
+// GetConsumption downloads the consumption export of an account between start
+// and end: a zip archive with the resource usage per hour, see the consumption
+// package to parse it
+func (s *AccountServiceOp) GetConsumption(id int, start time.Time, end time.Time) ([]byte, error) {
+	accountMap := make(map[string]interface{})
+	accountMap["accountId"] = id
+	accountMap["start"] = start.Unix()
+	accountMap["end"] = end.Unix()
+
+	return s.client.download("/cloudapi/accounts/getConsumption", accountMap, DataActionTimeout)
+}
+
+// GetConsumedCloudUnits returns the cloud units consumed by a cloudspace
+func (s *CloudSpaceServiceOp) GetConsumedCloudUnits(id int) (*CloudUnits, error) {
+	cloudSpaceMap := make(map[string]interface{})
+	cloudSpaceMap["cloudspaceId"] = id
+
+	body, err := s.client.Post("/cloudapi/cloudspaces/getConsumedCloudUnits", cloudSpaceMap, ModelActionTimeout)
+	if err != nil {
+		return nil, err
+	}
+	units := new(CloudUnits)
+	err = json.Unmarshal(body, &units)
+	if err != nil {
+		return nil, err
+	}
+
+	return units, nil
+}
+
+// EstimateReservedCloudUnits estimates the cloud units reserved by the
+// machines, disks and public IPs of a cloudspace from their resources. The G8
+// only reports reserved units per account, see
+// AccountService.GetReservedCloudUnits.
+func (s *CloudSpaceServiceOp) EstimateReservedCloudUnits(id int) (*CloudUnits, error) {
+	quotas := s.client.Quotas
+	if quotas == nil {
+		quotas = &QuotaServiceOp{client: s.client}
+	}
+	usage, err := quotas.CloudSpaceUsage(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CloudUnits{
+		CUM: usage.Memory,
+		CUC: float64(usage.Vcpus),
+		CUD: float64(usage.DiskSize),
+		CUI: float64(usage.PublicIPs),
+	}, nil
+}
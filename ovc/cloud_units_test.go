@@ -0,0 +1,45 @@
+package ovc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCloudUnits(t *testing.T) {
+	client, stop := newTestClient(t, func(endpoint string, params map[string]interface{}) (interface{}, bool) {
+		switch endpoint {
+		case "/cloudapi/accounts/getReservedAccountUnits", "/cloudapi/accounts/getConsumedCloudUnits":
+			assert.Equal(t, float64(7), params["accountId"])
+			return map[string]interface{}{"CU_M": 4, "CU_C": 2, "CU_I": 1}, true
+		case "/cloudapi/accounts/getConsumption":
+			assert.Equal(t, map[string]interface{}{"accountId": float64(7), "start": float64(1560988800), "end": float64(1561075200)}, params)
+			return []byte("PK\x03\x04archive"), true
+		case "/cloudapi/cloudspaces/get":
+			return map[string]interface{}{"id": 3, "accountId": 7, "externalnetworkip": "185.15.201.114"}, true
+		case "/cloudapi/machines/list":
+			return []map[string]interface{}{{"id": 10, "memory": 2048, "vcpus": 2, "disks": []int{100}, "status": "RUNNING"}}, true
+		case "/cloudapi/disks/list":
+			return []map[string]interface{}{{"id": 100, "sizeMax": 50, "status": "ASSIGNED"}}, true
+		}
+		return "unexpected call to " + endpoint, false
+	})
+	defer stop()
+	client.Accounts = &AccountServiceOp{client: client}
+	client.CloudSpaces = &CloudSpaceServiceOp{client: client}
+	client.Disks = &DiskServiceOp{client: client}
+
+	units, err := client.Accounts.GetReservedCloudUnits(7)
+	assert.NoError(t, err)
+	assert.Equal(t, &CloudUnits{CUM: 4, CUC: 2, CUI: 1}, units)
+
+	start := time.Date(2019, 6, 20, 0, 0, 0, 0, time.UTC)
+	archive, err := client.Accounts.GetConsumption(7, start, start.Add(24*time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("PK\x03\x04archive"), archive, "the archive should be returned as is")
+
+	units, err = client.CloudSpaces.EstimateReservedCloudUnits(3)
+	assert.NoError(t, err)
+	assert.Equal(t, &CloudUnits{CUM: 2, CUC: 2, CUD: 50, CUI: 1}, units)
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"errors"
 	"strconv"
+	"strings"
 )
 
 // CloudSpaceConfig is used when creating a CloudSpace
@@ -53,6 +54,22 @@ type CloudSpace struct {
 	PrivateNetwork    string         `json:"privatenetwork"`
 	Type              string         `json:"type"`
 	Mode              string         `json:"mode"`
+	AllowedVMSizes    []int          `json:"allowedVMSizes"`
+	// ExternalNetworks are the external networks attached with
+	// AttachExternalNetwork
+	ExternalNetworks []CloudSpaceExternalNetwork `json:"externalnetworks"`
+}
+
+// PublicIP returns the external network IP of the cloudspace without the
+// prefix length the G8 may report it with, as used by port forwards
+func (c *CloudSpace) PublicIP() string {
+	return strings.SplitN(c.Externalnetworkip, "/", 2)[0]
+}
+
+// CloudSpaceExternalNetwork is an additional external network of a cloudspace
+type CloudSpaceExternalNetwork struct {
+	ExternalNetworkID int    `json:"externalnetworkId"`
+	IPAddress         string `json:"ipaddress"`
 }
 
 // CloudSpaceInfo returns a list of CloudSpaces
@@ -81,6 +98,21 @@ type CloudSpaceDeleteConfig struct {
 	Permanently  bool `json:"permanently"`
 }
 
+// Validate checks a CloudSpaceConfig used to create a cloudspace
+func (c *CloudSpaceConfig) Validate() error {
+	v := &validator{}
+	v.requiredID("accountId", c.AccountID)
+	v.required("name", c.Name)
+	v.required("location", c.Location)
+	if c.PrivateNetwork != "" {
+		v.cidr("privatenetwork", c.PrivateNetwork)
+	}
+	if c.ExternalnetworkID < 0 {
+		v.add("externalnetworkId", "must not be negative, got %d", c.ExternalnetworkID)
+	}
+	return v.err()
+}
+
 // CloudSpaceService is an interface for interfacing with the CloudSpace
 // endpoints of the OVC API
 type CloudSpaceService interface {
@@ -91,6 +123,21 @@ type CloudSpaceService interface {
 	Update(*CloudSpaceConfig) error
 	Delete(*CloudSpaceDeleteConfig) error
 	SetDefaultGateway(int, string) error
+	AddUser(int, string, ACLRight) error
+	UpdateUser(int, string, ACLRight) error
+	DeleteUser(int, string, bool) error
+	GetConsumedCloudUnits(int) (*CloudUnits, error)
+	EstimateReservedCloudUnits(int) (*CloudUnits, error)
+	History(int, *HistoryFilter) ([]HistoryEvent, error)
+	GetOpenVPNConfig(int) (*OpenVPNConfig, error)
+	GetDefenseShield(int) (*DefenseShield, error)
+	ResetVFW(int) error
+	RestartVFW(int) error
+	Deploy(int) error
+	Enable(int, string) error
+	Disable(int, string) error
+	AttachExternalNetwork(int, int) error
+	DetachExternalNetwork(int, int) error
 }
 
 // CloudSpaceServiceOp handles communication with the cloudspace related methods of the
@@ -152,6 +199,9 @@ func (s *CloudSpaceServiceOp) GetByNameAndAccount(cloudSpaceName string, account
 
 // Create a new CloudSpace
 func (s *CloudSpaceServiceOp) Create(cloudSpaceConfig *CloudSpaceConfig) (int, error) {
+	if err := cloudSpaceConfig.Validate(); err != nil {
+		return 0, err
+	}
 	body, err := s.client.Post("/cloudapi/cloudspaces/create", *cloudSpaceConfig, OperationalActionTimeout)
 	if err != nil {
 		return 0, err
@@ -0,0 +1,240 @@
+package ovc
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// Cloudspace statuses as reported by the G8
+const (
+	CloudSpaceStatusVirtual   = "VIRTUAL"
+	CloudSpaceStatusDeploying = "DEPLOYING"
+	CloudSpaceStatusDeployed  = "DEPLOYED"
+	CloudSpaceStatusDisabled  = "DISABLED"
+)
+
+// cloudSpacePollInterval is the time between polls when waiting for a
+// cloudspace
+var cloudSpacePollInterval = 5 * time.Second
+
+// DefenseShield holds the credentials of the web interface of the virtual
+// firewall of a cloudspace
+type DefenseShield struct {
+	URL      string `json:"url"`
+	User     string `json:"user"`
+	Password string `json:"password"`
+}
+
+// OpenVPNConfig is the OpenVPN client configuration of a cloudspace with the
+// files it refers to
+type OpenVPNConfig struct {
+	// Config is the client configuration, it may refer to the files below
+	Config string
+	// Files holds the other files of the configuration by name, e.g. ca.crt
+	Files map[string]string
+}
+
+// ovpnFileDirectives are the directives referring to a file which can be
+// inlined in the configuration
+var ovpnFileDirectives = map[string]bool{
+	"ca":        true,
+	"cert":      true,
+	"key":       true,
+	"tls-auth":  true,
+	"tls-crypt": true,
+	"dh":        true,
+}
+
+// WriteOVPN writes the configuration as a single .ovpn file with all files it
+// refers to inlined
+func (c *OpenVPNConfig) WriteOVPN(w io.Writer) error {
+	b := bufio.NewWriter(w)
+	scanner := bufio.NewScanner(strings.NewReader(c.Config))
+	for scanner.Scan() {
+		line := scanner.Text()
+		fields := strings.Fields(line)
+		if len(fields) >= 2 {
+			if tag := fields[0]; ovpnFileDirectives[tag] {
+				if content, ok := c.Files[path.Base(fields[1])]; ok {
+					if tag == "tls-auth" && len(fields) >= 3 {
+						fmt.Fprintf(b, "key-direction %s\n", fields[2])
+					}
+					fmt.Fprintf(b, "<%s>\n%s\n</%s>\n", tag, strings.TrimSpace(content), tag)
+					continue
+				}
+			}
+		}
+		fmt.Fprintln(b, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return b.Flush()
+}
+
+// WriteFile writes the configuration as a .ovpn file readable by the owner only
+func (c *OpenVPNConfig) WriteFile(filename string) error {
+	f, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if err := c.WriteOVPN(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// parseOpenVPNConfig reads the configuration downloaded from the G8, either a
+// zip archive with the configuration and its files or the configuration itself
+func parseOpenVPNConfig(body []byte) (*OpenVPNConfig, error) {
+	config := &OpenVPNConfig{Files: make(map[string]string)}
+	if !bytes.HasPrefix(body, []byte("PK")) {
+		config.Config = string(body)
+		return config, nil
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range archive.File {
+		if file.FileInfo().IsDir() {
+			continue
+		}
+		rc, err := file.Open()
+		if err != nil {
+			return nil, err
+		}
+		data, err := ioutil.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return nil, err
+		}
+		name := path.Base(file.Name)
+		if strings.HasSuffix(name, ".ovpn") || strings.HasSuffix(name, ".conf") {
+			config.Config = string(data)
+		} else {
+			config.Files[name] = string(data)
+		}
+	}
+	if config.Config == "" {
+		return nil, fmt.Errorf("No OpenVPN configuration found in the archive")
+	}
+
+	return config, nil
+}
+
+// GetOpenVPNConfig downloads the OpenVPN client configuration of a cloudspace
+func (s *CloudSpaceServiceOp) GetOpenVPNConfig(id int) (*OpenVPNConfig, error) {
+	cloudSpaceMap := make(map[string]interface{})
+	cloudSpaceMap["cloudspaceId"] = id
+
+	body, err := s.client.download("/cloudapi/cloudspaces/getOpenvpnConfig", cloudSpaceMap, ModelActionTimeout)
+	if err != nil {
+		return nil, err
+	}
+	return parseOpenVPNConfig(body)
+}
+
+// GetDefenseShield returns the credentials of the web interface of the
+// virtual firewall of a cloudspace
+func (s *CloudSpaceServiceOp) GetDefenseShield(id int) (*DefenseShield, error) {
+	cloudSpaceMap := make(map[string]interface{})
+	cloudSpaceMap["cloudspaceId"] = id
+
+	body, err := s.client.Post("/cloudapi/cloudspaces/getDefenseShield", cloudSpaceMap, ModelActionTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defenseShield := new(DefenseShield)
+	err = json.Unmarshal(body, &defenseShield)
+	if err != nil {
+		return nil, err
+	}
+
+	return defenseShield, nil
+}
+
+// routerAction calls an endpoint acting on the virtual firewall of a
+// cloudspace and waits for the cloudspace to reach status
+func (s *CloudSpaceServiceOp) routerAction(endpoint string, id int, params map[string]interface{}, status string) error {
+	_, err := s.client.Post(endpoint, params, OperationalActionTimeout)
+	if err != nil {
+		return err
+	}
+	_, err = s.waitForStatus(id, OperationalActionTimeout, status)
+	return err
+}
+
+// ResetVFW resets the virtual firewall of a cloudspace to its initial
+// configuration, port forwards and tunnels are restored by the G8. It returns
+// when the G8 finished the reset, the cloudspace stays deployed throughout so
+// there is no status to wait for.
+func (s *CloudSpaceServiceOp) ResetVFW(id int) error {
+	cloudSpaceMap := make(map[string]interface{})
+	cloudSpaceMap["cloudspaceId"] = id
+	_, err := s.client.Post("/cloudbroker/cloudspace/resetVfw", cloudSpaceMap, OperationalActionTimeout)
+	return err
+}
+
+// RestartVFW restarts the virtual firewall of a cloudspace. Like ResetVFW it
+// returns when the G8 finished the restart without polling the cloudspace.
+func (s *CloudSpaceServiceOp) RestartVFW(id int) error {
+	cloudSpaceMap := make(map[string]interface{})
+	cloudSpaceMap["cloudspaceId"] = id
+	_, err := s.client.Post("/cloudbroker/cloudspace/restartVfw", cloudSpaceMap, OperationalActionTimeout)
+	return err
+}
+
+// Deploy deploys the virtual firewall of a cloudspace which is still virtual
+func (s *CloudSpaceServiceOp) Deploy(id int) error {
+	cloudSpaceMap := make(map[string]interface{})
+	cloudSpaceMap["cloudspaceId"] = id
+	return s.routerAction("/cloudapi/cloudspaces/deploy", id, cloudSpaceMap, CloudSpaceStatusDeployed)
+}
+
+// Enable enables a disabled cloudspace
+func (s *CloudSpaceServiceOp) Enable(id int, reason string) error {
+	cloudSpaceMap := make(map[string]interface{})
+	cloudSpaceMap["cloudspaceId"] = id
+	cloudSpaceMap["reason"] = reason
+	return s.routerAction("/cloudapi/cloudspaces/enable", id, cloudSpaceMap, CloudSpaceStatusDeployed)
+}
+
+// Disable disables a cloudspace, stopping its machines and virtual firewall
+func (s *CloudSpaceServiceOp) Disable(id int, reason string) error {
+	cloudSpaceMap := make(map[string]interface{})
+	cloudSpaceMap["cloudspaceId"] = id
+	cloudSpaceMap["reason"] = reason
+	return s.routerAction("/cloudapi/cloudspaces/disable", id, cloudSpaceMap, CloudSpaceStatusDisabled)
+}
+
+// waitForStatus polls a cloudspace until it has one of the given statuses
+func (s *CloudSpaceServiceOp) waitForStatus(id int, timeout ResponseTimeout, statuses ...string) (*CloudSpace, error) {
+	deadline := time.Now().Add(time.Duration(timeout))
+	for {
+		cloudSpace, err := s.client.CloudSpaces.Get(id)
+		if err != nil {
+			return nil, err
+		}
+		for _, status := range statuses {
+			if cloudSpace.Status == status {
+				return cloudSpace, nil
+			}
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("Timeout waiting for cloudspace %d to become %v, status is %s", id, statuses, cloudSpace.Status)
+		}
+		time.Sleep(cloudSpacePollInterval)
+	}
+}
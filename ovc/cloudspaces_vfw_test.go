@@ -0,0 +1,105 @@
+package ovc
+
+import (
+	"archive/zip"
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpenVPNConfig(t *testing.T) {
+	buf := &bytes.Buffer{}
+	archive := zip.NewWriter(buf)
+	for name, content := range map[string]string{
+		"openvpn/cloudspace.ovpn": "client\nremote 185.15.201.114 1194\nca ca.crt\ncert client.crt\nkey client.key\ntls-auth ta.key 1\n",
+		"openvpn/ca.crt":          "-----BEGIN CERTIFICATE-----\nCA\n-----END CERTIFICATE-----\n",
+		"openvpn/client.crt":      "CERT",
+		"openvpn/client.key":      "KEY",
+		"openvpn/ta.key":          "TA",
+	} {
+		w, _ := archive.Create(name)
+		w.Write([]byte(content))
+	}
+	archive.Close()
+
+	client, stop := newTestClient(t, func(endpoint string, params map[string]interface{}) (interface{}, bool) {
+		if endpoint != "/cloudapi/cloudspaces/getOpenvpnConfig" {
+			return "unexpected call to " + endpoint, false
+		}
+		switch params["cloudspaceId"] {
+		case float64(3):
+			return buf.Bytes(), true
+		case float64(4):
+			return []byte("client\nremote 185.15.201.115 1194\n"), true
+		}
+		return "cloudspace not found", false
+	})
+	defer stop()
+	client.CloudSpaces = &CloudSpaceServiceOp{client: client}
+
+	config, err := client.CloudSpaces.GetOpenVPNConfig(3)
+	if !assert.NoError(t, err) {
+		return
+	}
+	out := &bytes.Buffer{}
+	assert.NoError(t, config.WriteOVPN(out))
+	assert.Equal(t, "client\nremote 185.15.201.114 1194\n"+
+		"<ca>\n-----BEGIN CERTIFICATE-----\nCA\n-----END CERTIFICATE-----\n</ca>\n"+
+		"<cert>\nCERT\n</cert>\n<key>\nKEY\n</key>\nkey-direction 1\n<tls-auth>\nTA\n</tls-auth>\n", out.String())
+
+	config, err = client.CloudSpaces.GetOpenVPNConfig(4)
+	assert.NoError(t, err)
+	assert.Equal(t, "client\nremote 185.15.201.115 1194\n", config.Config)
+	_, err = client.CloudSpaces.GetOpenVPNConfig(5)
+	assert.Error(t, err)
+}
+
+func TestCloudSpaceDisable(t *testing.T) {
+	cloudSpacePollInterval = time.Millisecond
+	defer func() { cloudSpacePollInterval = 5 * time.Second }()
+
+	status := CloudSpaceStatusDeployed
+	polls := 0
+	client, stop := newTestClient(t, func(endpoint string, params map[string]interface{}) (interface{}, bool) {
+		switch endpoint {
+		case "/cloudapi/cloudspaces/disable":
+			if params["reason"] != "unpaid" {
+				return "missing reason", false
+			}
+			return true, true
+		case "/cloudapi/cloudspaces/get":
+			polls++
+			if polls == 2 {
+				status = CloudSpaceStatusDisabled
+			}
+			return map[string]interface{}{"id": 3, "status": status}, true
+		}
+		return "unexpected call to " + endpoint, false
+	})
+	defer stop()
+	client.CloudSpaces = &CloudSpaceServiceOp{client: client}
+
+	assert.NoError(t, client.CloudSpaces.Disable(3, "unpaid"))
+	assert.Equal(t, 2, polls)
+}
+
+func TestRestartVFW(t *testing.T) {
+	calls := []string{}
+	client, stop := newTestClient(t, func(endpoint string, params map[string]interface{}) (interface{}, bool) {
+		calls = append(calls, endpoint)
+		switch endpoint {
+		case "/cloudbroker/cloudspace/resetVfw", "/cloudbroker/cloudspace/restartVfw":
+			assert.Equal(t, float64(3), params["cloudspaceId"])
+			return true, true
+		}
+		return "unexpected call to " + endpoint, false
+	})
+	defer stop()
+	client.CloudSpaces = &CloudSpaceServiceOp{client: client}
+
+	assert.NoError(t, client.CloudSpaces.ResetVFW(3))
+	assert.NoError(t, client.CloudSpaces.RestartVFW(3))
+	assert.Equal(t, []string{"/cloudbroker/cloudspace/resetVfw", "/cloudbroker/cloudspace/restartVfw"}, calls)
+}
@@ -0,0 +1,260 @@
+package ovc
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+
+	"golang.org/x/net/websocket"
+)
+
+// GetConsoleURL returns the URL of the noVNC console of a machine
+func (s *MachineServiceOp) GetConsoleURL(id int) (string, error) {
+	machineMap := make(map[string]interface{})
+	machineMap["machineId"] = id
+
+	body, err := s.client.Post("/cloudapi/machines/getConsoleUrl", machineMap, ModelActionTimeout)
+	if err != nil {
+		return "", err
+	}
+	consoleURL := ""
+	err = json.Unmarshal(body, &consoleURL)
+	if err != nil {
+		// some G8 versions return the URL unquoted
+		consoleURL = string(body)
+	}
+	if consoleURL == "" {
+		return "", fmt.Errorf("No console available for machine %d", id)
+	}
+
+	return consoleURL, nil
+}
+
+// Console connects to the console of a machine
+func (s *MachineServiceOp) Console(id int) (*Console, error) {
+	consoleURL, err := s.GetConsoleURL(id)
+	if err != nil {
+		return nil, err
+	}
+	return DialConsole(consoleURL)
+}
+
+// Console is a connection to the console websocket proxy of a machine. Read
+// and Write stream the raw console data. The G8 proxies a VNC server: call
+// Handshake once before SendKeys to use the connection as a VNC client.
+type Console struct {
+	conn *websocket.Conn
+	// Name and Width/Height of the desktop, set by Handshake
+	Name   string
+	Width  int
+	Height int
+}
+
+// ConsoleWebsocketURL returns the websocket URL of the proxy behind a noVNC
+// console URL. noVNC connects to the path given in its "path" parameter, or to
+// "websockify" next to the page, passing the token along.
+func ConsoleWebsocketURL(consoleURL string) (string, error) {
+	u, err := url.Parse(consoleURL)
+	if err != nil {
+		return "", err
+	}
+	switch u.Scheme {
+	case "https", "wss":
+		u.Scheme = "wss"
+	case "http", "ws":
+		u.Scheme = "ws"
+	default:
+		return "", fmt.Errorf("Unsupported console URL %q", consoleURL)
+	}
+	query := u.Query()
+	if p := query.Get("path"); p != "" {
+		target, err := url.Parse(p)
+		if err != nil {
+			return "", err
+		}
+		u.Path = "/" + target.Path
+		for key, values := range target.Query() {
+			query[key] = values
+		}
+		query.Del("path")
+	} else {
+		u.Path = path.Join(path.Dir(u.Path), "websockify")
+	}
+	u.RawQuery = query.Encode()
+	u.Fragment = ""
+
+	return u.String(), nil
+}
+
+// DialConsole connects to the websocket proxy behind a console URL as
+// returned by GetConsoleURL
+func DialConsole(consoleURL string) (*Console, error) {
+	wsURL, err := ConsoleWebsocketURL(consoleURL)
+	if err != nil {
+		return nil, err
+	}
+	u, _ := url.Parse(consoleURL)
+	config, err := websocket.NewConfig(wsURL, u.Scheme+"://"+u.Host)
+	if err != nil {
+		return nil, err
+	}
+	config.Protocol = []string{"binary"}
+	conn, err := websocket.DialConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("Could not connect to console: %s", err)
+	}
+	conn.PayloadType = websocket.BinaryFrame
+
+	return &Console{conn: conn}, nil
+}
+
+// Read reads raw console data
+func (c *Console) Read(p []byte) (int, error) {
+	return c.conn.Read(p)
+}
+
+// Write writes raw console data
+func (c *Console) Write(p []byte) (int, error) {
+	return c.conn.Write(p)
+}
+
+// Close closes the console connection
+func (c *Console) Close() error {
+	return c.conn.Close()
+}
+
+// ErrConsoleAuthentication is returned by Handshake when the VNC server
+// requires authentication other than the token in the console URL
+var ErrConsoleAuthentication = errors.New("Console requires unsupported VNC authentication")
+
+const (
+	rfbSecurityNone   = 1
+	rfbKeyEvent       = 4
+	rfbKeysymReturn   = 0xff0d
+	rfbKeysymTab      = 0xff09
+	rfbKeysymBackDel  = 0xff08
+	rfbKeysymEscape   = 0xff1b
+	rfbProtocolLength = 12
+)
+
+// Handshake performs the VNC (RFB 3.8) handshake without authentication, the
+// proxy already authorised the connection with the console token
+func (c *Console) Handshake() error {
+	version := make([]byte, rfbProtocolLength)
+	if _, err := io.ReadFull(c, version); err != nil {
+		return err
+	}
+	if string(version[:4]) != "RFB " {
+		return fmt.Errorf("Console is not a VNC server: %q", version)
+	}
+	if _, err := c.Write([]byte("RFB 003.008\n")); err != nil {
+		return err
+	}
+
+	count := make([]byte, 1)
+	if _, err := io.ReadFull(c, count); err != nil {
+		return err
+	}
+	if count[0] == 0 {
+		return fmt.Errorf("Console refused the connection: %s", c.readReason())
+	}
+	types := make([]byte, count[0])
+	if _, err := io.ReadFull(c, types); err != nil {
+		return err
+	}
+	supported := false
+	for _, t := range types {
+		supported = supported || t == rfbSecurityNone
+	}
+	if !supported {
+		return ErrConsoleAuthentication
+	}
+	if _, err := c.Write([]byte{rfbSecurityNone}); err != nil {
+		return err
+	}
+	var result uint32
+	if err := binary.Read(c, binary.BigEndian, &result); err != nil {
+		return err
+	}
+	if result != 0 {
+		return fmt.Errorf("Console refused the connection: %s", c.readReason())
+	}
+
+	// ClientInit: share the desktop with other clients
+	if _, err := c.Write([]byte{1}); err != nil {
+		return err
+	}
+	var serverInit struct {
+		Width, Height uint16
+		PixelFormat   [16]byte
+		NameLength    uint32
+	}
+	if err := binary.Read(c, binary.BigEndian, &serverInit); err != nil {
+		return err
+	}
+	name := make([]byte, serverInit.NameLength)
+	if _, err := io.ReadFull(c, name); err != nil {
+		return err
+	}
+	c.Width, c.Height, c.Name = int(serverInit.Width), int(serverInit.Height), string(name)
+
+	return nil
+}
+
+func (c *Console) readReason() string {
+	var length uint32
+	if err := binary.Read(c, binary.BigEndian, &length); err != nil {
+		return "unknown reason"
+	}
+	reason := make([]byte, length)
+	if _, err := io.ReadFull(c, reason); err != nil {
+		return "unknown reason"
+	}
+	return string(reason)
+}
+
+// SendKeys types text on the console, newlines press Return
+func (c *Console) SendKeys(text string) error {
+	for _, r := range text {
+		if err := c.SendKey(keysym(r)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SendKey presses and releases a single X11 keysym
+func (c *Console) SendKey(keysym uint32) error {
+	event := make([]byte, 16)
+	for i, down := range []byte{1, 0} {
+		msg := event[i*8 : i*8+8]
+		msg[0] = rfbKeyEvent
+		msg[1] = down
+		binary.BigEndian.PutUint32(msg[4:], keysym)
+	}
+	_, err := c.Write(event)
+	return err
+}
+
+// keysym returns the X11 keysym of a character
+func keysym(r rune) uint32 {
+	switch r {
+	case '\n', '\r':
+		return rfbKeysymReturn
+	case '\t':
+		return rfbKeysymTab
+	case '\b':
+		return rfbKeysymBackDel
+	case 0x1b:
+		return rfbKeysymEscape
+	}
+	if r < 0x100 {
+		// Latin-1 characters map to themselves
+		return uint32(r)
+	}
+	return 0x01000000 | uint32(r)
+}
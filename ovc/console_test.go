@@ -0,0 +1,63 @@
+package ovc
+
+import (
+	"encoding/binary"
+	"io"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/websocket"
+)
+
+func TestConsoleWebsocketURL(t *testing.T) {
+	u, err := ConsoleWebsocketURL("https://be-g8-3.gig.tech/g8vnc/vnc_auto.html?token=abc")
+	assert.NoError(t, err)
+	assert.Equal(t, "wss://be-g8-3.gig.tech/g8vnc/websockify?token=abc", u)
+
+	u, err = ConsoleWebsocketURL("http://10.0.0.1:8091/vnc_auto.html?path=websockify%3Ftoken%3Dxyz")
+	assert.NoError(t, err)
+	assert.Equal(t, "ws://10.0.0.1:8091/websockify?token=xyz", u)
+}
+
+func TestConsole(t *testing.T) {
+	keys := make(chan []byte, 1)
+	// a minimal VNC server behind a websocket, as the G8 console proxy
+	srv := httptest.NewServer(websocket.Handler(func(ws *websocket.Conn) {
+		ws.PayloadType = websocket.BinaryFrame
+		if ws.Request().URL.Query().Get("token") != "abc" {
+			return
+		}
+		ws.Write([]byte("RFB 003.008\n"))
+		io.ReadFull(ws, make([]byte, 12))
+		ws.Write([]byte{1, rfbSecurityNone})
+		io.ReadFull(ws, make([]byte, 1))
+		ws.Write([]byte{0, 0, 0, 0})
+		io.ReadFull(ws, make([]byte, 1))
+		serverInit := make([]byte, 24)
+		binary.BigEndian.PutUint16(serverInit[0:], 800)
+		binary.BigEndian.PutUint16(serverInit[2:], 600)
+		binary.BigEndian.PutUint32(serverInit[20:], 4)
+		ws.Write(append(serverInit, "vm-5"...))
+
+		events := make([]byte, 3*16)
+		io.ReadFull(ws, events)
+		keys <- events
+	}))
+	defer srv.Close()
+
+	console, err := DialConsole(srv.URL + "/vnc_auto.html?token=abc")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer console.Close()
+	assert.NoError(t, console.Handshake())
+	assert.Equal(t, "vm-5", console.Name)
+	assert.Equal(t, 800, console.Width)
+
+	assert.NoError(t, console.SendKeys("ls\n"))
+	events := <-keys
+	assert.Equal(t, []byte{rfbKeyEvent, 1, 0, 0, 0, 0, 0, 'l'}, events[:8])
+	assert.Equal(t, []byte{rfbKeyEvent, 0, 0, 0, 0, 0, 0, 'l'}, events[8:16])
+	assert.Equal(t, uint32(rfbKeysymReturn), binary.BigEndian.Uint32(events[36:40]))
+}
@@ -0,0 +1,269 @@
+package consumption
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// A minimal reader for unpacked Cap'n Proto messages, supporting just what
+// the consumption records need: structs, lists of structs and text.
+// See https://capnproto.org/encoding.html for the wire format.
+
+var errTruncated = errors.New("capnp: message truncated")
+
+// maxTraversal limits the number of words read from a message, protecting
+// against pointer loops and amplification in corrupt files
+const maxTraversal = 64 << 20
+
+type message struct {
+	segments  [][]byte
+	traversed int
+}
+
+func readMessage(data []byte) (*message, error) {
+	if len(data) < 8 {
+		return nil, errTruncated
+	}
+	count := int(binary.LittleEndian.Uint32(data)) + 1
+	if count > 512 {
+		return nil, fmt.Errorf("capnp: too many segments: %d", count)
+	}
+	header := 4 + 4*count
+	if header%8 != 0 {
+		header += 4
+	}
+	if len(data) < header {
+		return nil, errTruncated
+	}
+	m := &message{}
+	offset := header
+	for i := 0; i < count; i++ {
+		size := int(binary.LittleEndian.Uint32(data[4+4*i:])) * 8
+		if size < 0 || offset+size > len(data) {
+			return nil, errTruncated
+		}
+		m.segments = append(m.segments, data[offset:offset+size])
+		offset += size
+	}
+
+	return m, nil
+}
+
+// capStruct is a struct in a message
+type capStruct struct {
+	m        *message
+	segment  int
+	data     int // byte offset of the data section
+	dataSize int // in bytes
+	pointers int // byte offset of the pointer section
+	ptrCount int
+}
+
+func (m *message) word(segment int, offset int) (uint64, error) {
+	if segment < 0 || segment >= len(m.segments) || offset < 0 || offset+8 > len(m.segments[segment]) {
+		return 0, errTruncated
+	}
+	return binary.LittleEndian.Uint64(m.segments[segment][offset:]), nil
+}
+
+// resolve follows far pointers and returns the pointer word with the segment
+// and byte offset of its content
+func (m *message) resolve(segment int, offset int) (uint64, int, int, error) {
+	p, err := m.word(segment, offset)
+	if err != nil || p == 0 {
+		return 0, 0, 0, err
+	}
+	if p&3 != 2 {
+		return p, segment, offset + 8 + int(int32(uint32(p))>>2)*8, nil
+	}
+
+	// far pointer to a landing pad in another segment
+	padSegment := int(p >> 32)
+	padOffset := int((uint32(p)>>3)&0x1fffffff) * 8
+	if p&4 == 0 {
+		pad, err := m.word(padSegment, padOffset)
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		if pad&3 == 2 {
+			return 0, 0, 0, errors.New("capnp: far pointer to far pointer")
+		}
+		return pad, padSegment, padOffset + 8 + int(int32(uint32(pad))>>2)*8, nil
+	}
+	// double far: the pad is a far pointer to the content and a tag
+	far, err := m.word(padSegment, padOffset)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	tag, err := m.word(padSegment, padOffset+8)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	if far&7 != 2 {
+		return 0, 0, 0, errors.New("capnp: invalid double far landing pad")
+	}
+	contentSegment := int(far >> 32)
+	if contentSegment >= len(m.segments) {
+		return 0, 0, 0, errTruncated
+	}
+	return tag, contentSegment, int((uint32(far)>>3)&0x1fffffff) * 8, nil
+}
+
+func (m *message) traverse(words int) error {
+	m.traversed += words
+	if m.traversed > maxTraversal {
+		return errors.New("capnp: traversal limit exceeded")
+	}
+	return nil
+}
+
+func (m *message) root() (capStruct, error) {
+	return m.readStruct(0, 0)
+}
+
+func (m *message) readStruct(segment int, offset int) (capStruct, error) {
+	p, segment, content, err := m.resolve(segment, offset)
+	if err != nil || p == 0 {
+		return capStruct{}, err
+	}
+	if p&3 != 0 {
+		return capStruct{}, errors.New("capnp: expected struct pointer")
+	}
+	s := capStruct{
+		m:        m,
+		segment:  segment,
+		data:     content,
+		dataSize: int(uint16(p>>32)) * 8,
+		ptrCount: int(uint16(p >> 48)),
+	}
+	s.pointers = s.data + s.dataSize
+	if s.pointers+s.ptrCount*8 > len(m.segments[segment]) || content < 0 {
+		return capStruct{}, errTruncated
+	}
+	return s, m.traverse(s.dataSize/8 + s.ptrCount)
+}
+
+func (m *message) readStructList(segment int, offset int) ([]capStruct, error) {
+	p, segment, content, err := m.resolve(segment, offset)
+	if err != nil || p == 0 {
+		return nil, err
+	}
+	if p&3 != 1 || (p>>32)&7 != 7 {
+		return nil, errors.New("capnp: expected list of structs")
+	}
+	words := int(p >> 35)
+	tag, err := m.word(segment, content)
+	if err != nil {
+		return nil, err
+	}
+	count := int((uint32(tag) >> 2) & 0x3fffffff)
+	dataSize := int(uint16(tag>>32)) * 8
+	ptrCount := int(uint16(tag >> 48))
+	size := dataSize + ptrCount*8
+	if content+8+words*8 > len(m.segments[segment]) || count*size > words*8 {
+		return nil, errTruncated
+	}
+	if err := m.traverse(words + 1); err != nil {
+		return nil, err
+	}
+
+	elements := make([]capStruct, count)
+	for i := range elements {
+		data := content + 8 + i*size
+		elements[i] = capStruct{
+			m:        m,
+			segment:  segment,
+			data:     data,
+			dataSize: dataSize,
+			pointers: data + dataSize,
+			ptrCount: ptrCount,
+		}
+	}
+	return elements, nil
+}
+
+func (m *message) readText(segment int, offset int) (string, error) {
+	p, segment, content, err := m.resolve(segment, offset)
+	if err != nil || p == 0 {
+		return "", err
+	}
+	if p&3 != 1 || (p>>32)&7 != 2 {
+		return "", errors.New("capnp: expected text")
+	}
+	count := int(p >> 35)
+	if content < 0 || content+count > len(m.segments[segment]) {
+		return "", errTruncated
+	}
+	if err := m.traverse((count + 7) / 8); err != nil {
+		return "", err
+	}
+	if count == 0 {
+		return "", nil
+	}
+	// strip the NUL terminator
+	return string(m.segments[segment][content : content+count-1]), nil
+}
+
+// bytes returns n bytes of the data section at offset, nil when the struct
+// was written with an older schema without the field
+func (s capStruct) bytes(offset int, n int) []byte {
+	if s.m == nil || offset+n > s.dataSize {
+		return nil
+	}
+	return s.m.segments[s.segment][s.data+offset : s.data+offset+n]
+}
+
+func (s capStruct) int8(offset int) int8 {
+	if b := s.bytes(offset, 1); b != nil {
+		return int8(b[0])
+	}
+	return 0
+}
+
+func (s capStruct) int32(offset int) int32 {
+	if b := s.bytes(offset, 4); b != nil {
+		return int32(binary.LittleEndian.Uint32(b))
+	}
+	return 0
+}
+
+func (s capStruct) int64(offset int) int64 {
+	if b := s.bytes(offset, 8); b != nil {
+		return int64(binary.LittleEndian.Uint64(b))
+	}
+	return 0
+}
+
+func (s capStruct) float32(offset int) float32 {
+	if b := s.bytes(offset, 4); b != nil {
+		return math.Float32frombits(binary.LittleEndian.Uint32(b))
+	}
+	return 0
+}
+
+// pointer returns the location of pointer i, ok is false when the struct has
+// no such pointer
+func (s capStruct) pointer(i int) (int, int, bool) {
+	if s.m == nil || i >= s.ptrCount {
+		return 0, 0, false
+	}
+	return s.segment, s.pointers + i*8, true
+}
+
+func (s capStruct) text(i int) (string, error) {
+	segment, offset, ok := s.pointer(i)
+	if !ok {
+		return "", nil
+	}
+	return s.m.readText(segment, offset)
+}
+
+func (s capStruct) structList(i int) ([]capStruct, error) {
+	segment, offset, ok := s.pointer(i)
+	if !ok {
+		return nil, nil
+	}
+	return s.m.readStructList(segment, offset)
+}
@@ -0,0 +1,330 @@
+// Package consumption parses the consumption export of a G8 account, as
+// downloaded with AccountService.GetConsumption, into typed usage records and
+// aggregates them per cloudspace or machine.
+//
+// The export is a zip archive with a Cap'n Proto message per hour, stored as
+// <year>/<month>/<day>/<hour>/<name>.bin and following this schema:
+//
+//	struct Account {
+//	  accountId @0 :Int32;
+//	  cloudspaces @1 :List(CloudSpace);
+//	}
+//	struct CloudSpace {
+//	  cloudSpaceId @0 :Int32;
+//	  machines @1 :List(VMachine);
+//	}
+//	struct VMachine {
+//	  id @0 :Int32;
+//	  type @1 :Text;
+//	  vcpus @2 :Int8;
+//	  cpuMinutes @3 :Float32;
+//	  mem @4 :Int64;
+//	  networks @5 :List(Nic);
+//	  disks @6 :List(Disk);
+//	  imageName @7 :Text;
+//	  status @8 :Text;
+//	}
+//	struct Nic {
+//	  id @0 :Int32;
+//	  type @1 :Text;
+//	  tx @2 :Float32;
+//	  rx @3 :Float32;
+//	}
+//	struct Disk {
+//	  id @0 :Int32;
+//	  size @1 :Int64;
+//	  iopsRead @2 :Float32;
+//	  iopsWrite @3 :Float32;
+//	  iopsReadMax @4 :Float32;
+//	  iopsWriteMax @5 :Float32;
+//	}
+package consumption
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Record is the resource usage of a machine during one hour
+type Record struct {
+	Time         time.Time `json:"time"`
+	AccountID    int       `json:"accountId"`
+	CloudSpaceID int       `json:"cloudspaceId"`
+	MachineID    int       `json:"machineId"`
+	Type         string    `json:"type"`
+	ImageName    string    `json:"imageName"`
+	Status       string    `json:"status"`
+	Vcpus        int       `json:"vcpus"`
+	CPUMinutes   float64   `json:"cpuMinutes"`
+	// Memory in MB
+	Memory int64       `json:"memory"`
+	NICs   []NICUsage  `json:"nics"`
+	Disks  []DiskUsage `json:"disks"`
+}
+
+// NICUsage is the network transfer of a NIC during one hour
+type NICUsage struct {
+	ID   int    `json:"id"`
+	Type string `json:"type"`
+	// TX and RX in MB
+	TX float64 `json:"tx"`
+	RX float64 `json:"rx"`
+}
+
+// DiskUsage is the size and IO of a disk during one hour
+type DiskUsage struct {
+	ID int `json:"id"`
+	// Size in GB
+	Size         int64   `json:"size"`
+	IOPSRead     float64 `json:"iopsRead"`
+	IOPSWrite    float64 `json:"iopsWrite"`
+	IOPSReadMax  float64 `json:"iopsReadMax"`
+	IOPSWriteMax float64 `json:"iopsWriteMax"`
+}
+
+// ReadFile parses a consumption export stored in a file
+func ReadFile(filename string) ([]*Record, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return Parse(f, info.Size())
+}
+
+// ParseBytes parses a consumption export held in memory
+func ParseBytes(data []byte) ([]*Record, error) {
+	return Parse(bytes.NewReader(data), int64(len(data)))
+}
+
+// Parse parses a consumption export, records are ordered by time, cloudspace
+// and machine
+func Parse(r io.ReaderAt, size int64) ([]*Record, error) {
+	archive, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+	records := []*Record{}
+	for _, file := range archive.File {
+		if file.FileInfo().IsDir() {
+			continue
+		}
+		hour, err := parseHour(file.Name)
+		if err != nil {
+			return nil, err
+		}
+		data, err := readZipFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("Could not read %s: %s", file.Name, err)
+		}
+		hourRecords, err := parseAccount(data, hour)
+		if err != nil {
+			return nil, fmt.Errorf("Could not parse %s: %s", file.Name, err)
+		}
+		records = append(records, hourRecords...)
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		a, b := records[i], records[j]
+		if !a.Time.Equal(b.Time) {
+			return a.Time.Before(b.Time)
+		}
+		if a.CloudSpaceID != b.CloudSpaceID {
+			return a.CloudSpaceID < b.CloudSpaceID
+		}
+		return a.MachineID < b.MachineID
+	})
+
+	return records, nil
+}
+
+func readZipFile(file *zip.File) ([]byte, error) {
+	rc, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return ioutil.ReadAll(rc)
+}
+
+// parseHour returns the hour of a record from its path in the archive
+func parseHour(name string) (time.Time, error) {
+	parts := strings.Split(strings.Trim(name, "/"), "/")
+	if len(parts) >= 5 {
+		numbers := make([]int, 4)
+		valid := true
+		for i, part := range parts[len(parts)-5 : len(parts)-1] {
+			n, err := strconv.Atoi(part)
+			if err != nil {
+				valid = false
+				break
+			}
+			numbers[i] = n
+		}
+		if valid {
+			return time.Date(numbers[0], time.Month(numbers[1]), numbers[2], numbers[3], 0, 0, 0, time.UTC), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("Unexpected file %s in consumption export, expected <year>/<month>/<day>/<hour>/<file>", name)
+}
+
+// parseAccount decodes an Account message into records
+func parseAccount(data []byte, hour time.Time) ([]*Record, error) {
+	m, err := readMessage(data)
+	if err != nil {
+		return nil, err
+	}
+	account, err := m.root()
+	if err != nil {
+		return nil, err
+	}
+	accountID := int(account.int32(0))
+	cloudSpaces, err := account.structList(0)
+	if err != nil {
+		return nil, err
+	}
+
+	records := []*Record{}
+	for _, cloudSpace := range cloudSpaces {
+		cloudSpaceID := int(cloudSpace.int32(0))
+		machines, err := cloudSpace.structList(0)
+		if err != nil {
+			return nil, err
+		}
+		for _, machine := range machines {
+			record, err := parseMachine(machine)
+			if err != nil {
+				return nil, err
+			}
+			record.Time = hour
+			record.AccountID = accountID
+			record.CloudSpaceID = cloudSpaceID
+			records = append(records, record)
+		}
+	}
+
+	return records, nil
+}
+
+func parseMachine(machine capStruct) (*Record, error) {
+	record := &Record{
+		MachineID:  int(machine.int32(0)),
+		Vcpus:      int(machine.int8(4)),
+		CPUMinutes: float64(machine.float32(8)),
+		Memory:     machine.int64(16),
+		NICs:       []NICUsage{},
+		Disks:      []DiskUsage{},
+	}
+	var err error
+	if record.Type, err = machine.text(0); err != nil {
+		return nil, err
+	}
+	if record.ImageName, err = machine.text(3); err != nil {
+		return nil, err
+	}
+	if record.Status, err = machine.text(4); err != nil {
+		return nil, err
+	}
+
+	nics, err := machine.structList(1)
+	if err != nil {
+		return nil, err
+	}
+	for _, nic := range nics {
+		nicType, err := nic.text(0)
+		if err != nil {
+			return nil, err
+		}
+		record.NICs = append(record.NICs, NICUsage{
+			ID:   int(nic.int32(0)),
+			Type: nicType,
+			TX:   float64(nic.float32(4)),
+			RX:   float64(nic.float32(8)),
+		})
+	}
+
+	disks, err := machine.structList(2)
+	if err != nil {
+		return nil, err
+	}
+	for _, disk := range disks {
+		record.Disks = append(record.Disks, DiskUsage{
+			ID:           int(disk.int32(0)),
+			Size:         disk.int64(8),
+			IOPSRead:     float64(disk.float32(4)),
+			IOPSWrite:    float64(disk.float32(16)),
+			IOPSReadMax:  float64(disk.float32(20)),
+			IOPSWriteMax: float64(disk.float32(24)),
+		})
+	}
+
+	return record, nil
+}
+
+// Usage is the resource usage aggregated over a number of records
+type Usage struct {
+	// Hours is the number of hourly records
+	Hours      int     `json:"hours"`
+	CPUMinutes float64 `json:"cpuMinutes"`
+	VcpuHours  float64 `json:"vcpuHours"`
+	// MemoryHours in MB hours
+	MemoryHours float64 `json:"memoryHours"`
+	// DiskHours in GB hours
+	DiskHours float64 `json:"diskHours"`
+	IOPSRead  float64 `json:"iopsRead"`
+	IOPSWrite float64 `json:"iopsWrite"`
+	// TX and RX in MB
+	TX float64 `json:"tx"`
+	RX float64 `json:"rx"`
+}
+
+// Add adds a record to the usage
+func (u *Usage) Add(record *Record) {
+	u.Hours++
+	u.CPUMinutes += record.CPUMinutes
+	u.VcpuHours += float64(record.Vcpus)
+	u.MemoryHours += float64(record.Memory)
+	for _, disk := range record.Disks {
+		u.DiskHours += float64(disk.Size)
+		u.IOPSRead += disk.IOPSRead
+		u.IOPSWrite += disk.IOPSWrite
+	}
+	for _, nic := range record.NICs {
+		u.TX += nic.TX
+		u.RX += nic.RX
+	}
+}
+
+// Aggregate sums the records by the key returned by key
+func Aggregate(records []*Record, key func(*Record) int) map[int]*Usage {
+	usage := make(map[int]*Usage)
+	for _, record := range records {
+		k := key(record)
+		if usage[k] == nil {
+			usage[k] = &Usage{}
+		}
+		usage[k].Add(record)
+	}
+	return usage
+}
+
+// ByCloudSpace sums the records per cloudspace ID
+func ByCloudSpace(records []*Record) map[int]*Usage {
+	return Aggregate(records, func(r *Record) int { return r.CloudSpaceID })
+}
+
+// ByMachine sums the records per machine ID
+func ByMachine(records []*Record) map[int]*Usage {
+	return Aggregate(records, func(r *Record) int { return r.MachineID })
+}
@@ -0,0 +1,206 @@
+package consumption
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/pem"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	jwtLib "github.com/dgrijalva/jwt-go"
+	"github.com/gig-tech/ovc-sdk-go/v4/ovc"
+	"github.com/stretchr/testify/assert"
+)
+
+// segmentBuilder lays out words of a capnp segment for the tests
+type segmentBuilder struct {
+	words []uint64
+}
+
+func (b *segmentBuilder) alloc(n int) int {
+	at := len(b.words)
+	b.words = append(b.words, make([]uint64, n)...)
+	return at
+}
+
+func (b *segmentBuilder) structPointer(at int, target int, dataWords int, ptrs int) {
+	b.words[at] = uint64(uint32(target-at-1)<<2) | uint64(dataWords)<<32 | uint64(ptrs)<<48
+}
+
+func (b *segmentBuilder) listPointer(at int, target int, size int, count int) {
+	b.words[at] = 1 | uint64(uint32(target-at-1)<<2) | uint64(size)<<32 | uint64(count)<<35
+}
+
+// structList writes a composite list tag and returns the word of the first
+// element
+func (b *segmentBuilder) structList(at int, count int, dataWords int, ptrs int) int {
+	tag := b.alloc(1 + count*(dataWords+ptrs))
+	b.listPointer(at, tag, 7, count*(dataWords+ptrs))
+	b.words[tag] = uint64(count)<<2 | uint64(dataWords)<<32 | uint64(ptrs)<<48
+	return tag + 1
+}
+
+func (b *segmentBuilder) text(at int, s string) {
+	data := append([]byte(s), 0)
+	target := b.alloc((len(data) + 7) / 8)
+	b.listPointer(at, target, 2, len(data))
+	padded := make([]byte, (len(data)+7)/8*8)
+	copy(padded, data)
+	for i := 0; i < len(padded)/8; i++ {
+		b.words[target+i] = binary.LittleEndian.Uint64(padded[i*8:])
+	}
+}
+
+func (b *segmentBuilder) setData(word int, byteOffset int, value uint64, bits uint) {
+	shift := uint(byteOffset%8) * 8
+	mask := uint64(1)<<bits - 1
+	w := word + byteOffset/8
+	b.words[w] = b.words[w]&^(mask<<shift) | (value&mask)<<shift
+}
+
+func encodeMessage(segments ...*segmentBuilder) []byte {
+	buf := &bytes.Buffer{}
+	binary.Write(buf, binary.LittleEndian, uint32(len(segments)-1))
+	for _, s := range segments {
+		binary.Write(buf, binary.LittleEndian, uint32(len(s.words)))
+	}
+	if len(segments)%2 == 0 {
+		binary.Write(buf, binary.LittleEndian, uint32(0))
+	}
+	for _, s := range segments {
+		binary.Write(buf, binary.LittleEndian, s.words)
+	}
+	return buf.Bytes()
+}
+
+// accountMessage encodes an hour of an account with one machine, its image
+// name is stored in a second segment behind a far pointer
+func accountMessage(accountID int, cpuMinutes float32) []byte {
+	b := &segmentBuilder{}
+	far := &segmentBuilder{}
+	root := b.alloc(1)
+	account := b.alloc(2)
+	b.structPointer(root, account, 1, 1)
+	b.setData(account, 0, uint64(accountID), 32)
+
+	cloudSpace := b.structList(account+1, 1, 1, 1)
+	b.setData(cloudSpace, 0, 3, 32)
+
+	machine := b.structList(cloudSpace+1, 1, 3, 5)
+	b.setData(machine, 0, 10, 32)
+	b.setData(machine, 4, 2, 8)
+	b.setData(machine, 8, uint64(math.Float32bits(cpuMinutes)), 32)
+	b.setData(machine, 16, 2048, 64)
+	pointers := machine + 3
+	b.text(pointers, "VM")
+
+	nic := b.structList(pointers+1, 1, 2, 1)
+	b.setData(nic, 0, 1, 32)
+	b.setData(nic, 4, uint64(math.Float32bits(100)), 32)
+	b.setData(nic, 8, uint64(math.Float32bits(200)), 32)
+	b.text(nic+2, "PUBLIC")
+
+	disk := b.structList(pointers+2, 1, 4, 0)
+	b.setData(disk, 0, 5, 32)
+	b.setData(disk, 8, 10, 64)
+	b.setData(disk, 4, uint64(math.Float32bits(1.5)), 32)
+	b.setData(disk, 24, uint64(math.Float32bits(40)), 32)
+
+	// single far pointer to a landing pad in segment 1
+	pad := far.alloc(1)
+	far.text(pad, "Ubuntu 18.04")
+	b.words[pointers+3] = 2 | uint64(pad)<<3 | uint64(1)<<32
+	b.text(pointers+4, "RUNNING")
+
+	return encodeMessage(b, far)
+}
+
+// exportArchive returns an export of account 7 with two hours of usage
+func exportArchive(t *testing.T) []byte {
+	buf := &bytes.Buffer{}
+	archive := zip.NewWriter(buf)
+	for name, cpuMinutes := range map[string]float32{
+		"2019/06/20/13/account_capnp.bin": 30,
+		"2019/06/20/12/account_capnp.bin": 12.5,
+	} {
+		w, err := archive.Create(name)
+		assert.NoError(t, err)
+		w.Write(accountMessage(7, cpuMinutes))
+	}
+	assert.NoError(t, archive.Close())
+	return buf.Bytes()
+}
+
+func TestParse(t *testing.T) {
+	records, err := ParseBytes(exportArchive(t))
+	if !assert.NoError(t, err) || !assert.Len(t, records, 2) {
+		return
+	}
+	record := records[0]
+	assert.Equal(t, time.Date(2019, 6, 20, 12, 0, 0, 0, time.UTC), record.Time)
+	assert.Equal(t, 7, record.AccountID)
+	assert.Equal(t, 3, record.CloudSpaceID)
+	assert.Equal(t, 10, record.MachineID)
+	assert.Equal(t, "VM", record.Type)
+	assert.Equal(t, "Ubuntu 18.04", record.ImageName)
+	assert.Equal(t, "RUNNING", record.Status)
+	assert.Equal(t, 2, record.Vcpus)
+	assert.Equal(t, 12.5, record.CPUMinutes)
+	assert.Equal(t, int64(2048), record.Memory)
+	assert.Equal(t, []NICUsage{{ID: 1, Type: "PUBLIC", TX: 100, RX: 200}}, record.NICs)
+	assert.Equal(t, []DiskUsage{{ID: 5, Size: 10, IOPSRead: 1.5, IOPSWriteMax: 40}}, record.Disks)
+
+	usage := ByCloudSpace(records)[3]
+	assert.Equal(t, 2, usage.Hours)
+	assert.Equal(t, 42.5, usage.CPUMinutes)
+	assert.Equal(t, 20.0, usage.DiskHours)
+	assert.Equal(t, 400.0, usage.RX)
+
+	_, err = parseAccount(accountMessage(7, 1)[:40], time.Time{})
+	assert.Error(t, err, "truncated messages should be rejected")
+}
+
+func TestParseDownload(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	assert.NoError(t, err)
+	publicKey, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	assert.NoError(t, err)
+	assert.NoError(t, ovc.SetJWTPublicKey(string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicKey}))))
+	token, err := jwtLib.NewWithClaims(jwtLib.SigningMethodES384, jwtLib.MapClaims{
+		"exp":      time.Now().Add(time.Hour).Unix(),
+		"username": "tester",
+	}).SignedString(key)
+	assert.NoError(t, err)
+
+	export := exportArchive(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/restmachine/cloudapi/accounts/getConsumption" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/zip")
+		w.Write(export)
+	}))
+	defer srv.Close()
+	client, err := ovc.NewClient(&ovc.Config{URL: srv.URL, JWT: token})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	start := time.Date(2019, 6, 20, 0, 0, 0, 0, time.UTC)
+	data, err := client.Accounts.GetConsumption(7, start, start.Add(24*time.Hour))
+	if !assert.NoError(t, err) {
+		return
+	}
+	records, err := ParseBytes(data)
+	assert.NoError(t, err)
+	assert.Len(t, records, 2)
+}
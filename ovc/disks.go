@@ -24,6 +24,27 @@ type DiskConfig struct {
 	Permanently string `json:"permanently,omitempty"`
 }
 
+// Validate checks a DiskConfig used to create a disk, either in an account
+// (Create) or attached to a machine (CreateAndAttach)
+func (c *DiskConfig) Validate() error {
+	v := &validator{}
+	if c.MachineID == 0 {
+		v.requiredID("accountId", c.AccountID)
+		v.requiredID("gid", c.GridID)
+		v.required("name", c.Name)
+	} else if c.Name == "" {
+		v.required("diskName", c.DiskName)
+	}
+	v.positive("size", c.Size)
+	if c.Type != "" {
+		v.oneOf("type", c.Type, "B", "D")
+	}
+	if c.IOPS < 0 {
+		v.add("iops", "must not be negative, got %d", c.IOPS)
+	}
+	return v.err()
+}
+
 // DiskDeleteConfig is used when deleting a disk
 type DiskDeleteConfig struct {
 	DiskID      int  `json:"diskId"`
@@ -212,6 +233,14 @@ func (s *DiskServiceOp) List(accountID int, diskType string) (*[]Disk, error) {
 
 // CreateAndAttach a new Disk and attaches it to a machine
 func (s *DiskServiceOp) CreateAndAttach(diskConfig *DiskConfig) (int, error) {
+	if err := diskConfig.Validate(); err != nil {
+		return 0, err
+	}
+	if s.client.quotaChecks {
+		if err := s.client.Quotas.CheckDiskCreate(diskConfig); err != nil {
+			return 0, err
+		}
+	}
 	defer ReleaseLock(diskConfig.MachineID)
 	GetLock(diskConfig.MachineID)
 	body, err := s.client.Post("/cloudapi/machines/addDisk", *diskConfig, OperationalActionTimeout)
@@ -223,6 +252,14 @@ func (s *DiskServiceOp) CreateAndAttach(diskConfig *DiskConfig) (int, error) {
 
 // Create a new Disk
 func (s *DiskServiceOp) Create(diskConfig *DiskConfig) (int, error) {
+	if err := diskConfig.Validate(); err != nil {
+		return 0, err
+	}
+	if s.client.quotaChecks {
+		if err := s.client.Quotas.CheckDiskCreate(diskConfig); err != nil {
+			return 0, err
+		}
+	}
 	body, err := s.client.Post("/cloudapi/disks/create", *diskConfig, OperationalActionTimeout)
 	if err != nil {
 		return 0, err
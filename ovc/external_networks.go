@@ -3,7 +3,8 @@ package ovc
 import (
 	"encoding/json"
 	"fmt"
-	"strconv"
+	"net"
+	"strings"
 )
 
 // ExternalNetworkConfig is used when getting an external network
@@ -23,11 +24,35 @@ type ExternalNetworkInfo struct {
 	DHCP       bool   `json:"dhcp"`
 }
 
+// IPNet returns the network, which the G8 gives either in CIDR notation or as
+// an address and a dotted or prefix length subnet mask, nil if it is invalid
+func (n *ExternalNetworkInfo) IPNet() *net.IPNet {
+	if strings.Contains(n.Network, "/") {
+		_, ipNet, err := net.ParseCIDR(n.Network)
+		if err != nil {
+			return nil
+		}
+		return ipNet
+	}
+	mask := n.Subnetmask
+	if ip := net.ParseIP(mask); ip != nil && ip.To4() != nil {
+		size, _ := net.IPMask(ip.To4()).Size()
+		mask = fmt.Sprint(size)
+	}
+	_, ipNet, err := net.ParseCIDR(n.Network + "/" + mask)
+	if err != nil {
+		return nil
+	}
+	return ipNet
+}
+
 // ExternalNetworkService is an interface for interfacing with the external networks
 // of the OVC API
 type ExternalNetworkService interface {
 	Get(int) (*ExternalNetworkInfo, error)
 	List(int) (*[]ExternalNetworkInfo, error)
+	GetByName(string, int) (*ExternalNetworkInfo, error)
+	Usage(int, int) (*ExternalNetworkUsage, error)
 }
 
 // ExternalNetworkServiceOp handles communication with the external network related methods of the
@@ -54,13 +79,10 @@ func (s *ExternalNetworkServiceOp) Get(id int) (*ExternalNetworkInfo, error) {
 	return externalNetworkInfo, nil
 }
 
-// GetByName gets an individual external network from its name
-func (s *ExternalNetworkServiceOp) GetByName(name string, accountID string) (*ExternalNetworkInfo, error) {
-	aid, err := strconv.Atoi(accountID)
-	if err != nil {
-		return nil, err
-	}
-	externalNetworks, err := s.List(aid)
+// GetByName gets an individual external network available to an account from
+// its name
+func (s *ExternalNetworkServiceOp) GetByName(name string, accountID int) (*ExternalNetworkInfo, error) {
+	externalNetworks, err := s.List(accountID)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,142 @@
+package ovc
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// ExternalNetworkAddress is an address of an external network in use
+type ExternalNetworkAddress struct {
+	IP           string
+	CloudSpaceID int
+	// MachineID is the machine attached to the external network, 0 for the
+	// address of the virtual firewall of the cloudspace
+	MachineID int
+}
+
+// ExternalNetworkUsage is the address usage of an external network
+type ExternalNetworkUsage struct {
+	ExternalNetwork ExternalNetworkInfo
+	// Size is the number of addresses of the network usable by cloudspaces
+	// and machines, without the network, broadcast and gateway addresses
+	Size int
+	Used []ExternalNetworkAddress
+	// Free is the number of addresses not used by any cloudspace visible to
+	// the client, whatever account Used is limited to
+	Free int
+}
+
+// Usage returns the addresses of an external network used by the cloudspaces
+// of an account and their machines, or by all cloudspaces visible to the
+// client when accountID is 0. The addresses of cloudspaces the network is
+// attached to with AttachExternalNetwork are included. Addresses used by
+// cloudspaces the client can't see are counted as free.
+func (s *ExternalNetworkServiceOp) Usage(id int, accountID int) (*ExternalNetworkUsage, error) {
+	externalNetwork, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	network := externalNetwork.IPNet()
+	if network == nil {
+		return nil, fmt.Errorf("External network %d has an invalid network %s/%s", id, externalNetwork.Network, externalNetwork.Subnetmask)
+	}
+	usage := &ExternalNetworkUsage{
+		ExternalNetwork: *externalNetwork,
+		Size:            usableAddresses(network, externalNetwork.Gateway),
+		Used:            []ExternalNetworkAddress{},
+	}
+
+	cloudSpaces, err := s.client.CloudSpaces.List()
+	if err != nil {
+		return nil, err
+	}
+	inUse := make(map[string]bool)
+	for _, cs := range *cloudSpaces {
+		listed := accountID == 0 || cs.AccountID == accountID
+		use := func(address string, machineID int) {
+			ip, ok := addressIn(address, network)
+			if !ok || inUse[ip] {
+				return
+			}
+			inUse[ip] = true
+			if listed {
+				usage.Used = append(usage.Used, ExternalNetworkAddress{IP: ip, CloudSpaceID: cs.ID, MachineID: machineID})
+			}
+		}
+
+		use(cs.Externalnetworkip, 0)
+		cloudSpace, err := s.client.CloudSpaces.Get(cs.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, attached := range cloudSpace.ExternalNetworks {
+			if attached.ExternalNetworkID == id {
+				use(attached.IPAddress, 0)
+			}
+		}
+		machines, err := s.client.Machines.List(cs.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, machine := range *machines {
+			for _, nic := range machine.Nics {
+				if strings.EqualFold(nic.Type, "PUBLIC") {
+					use(nic.IPAddress, machine.ID)
+				}
+			}
+		}
+	}
+
+	usage.Free = usage.Size - len(inUse)
+	if usage.Free < 0 {
+		usage.Free = 0
+	}
+	return usage, nil
+}
+
+// addressIn returns the address, which may be given in CIDR notation, if it
+// is part of network
+func addressIn(address string, network *net.IPNet) (string, bool) {
+	ip := net.ParseIP(strings.SplitN(address, "/", 2)[0])
+	if ip == nil || !network.Contains(ip) {
+		return "", false
+	}
+	return ip.String(), true
+}
+
+// usableAddresses returns the number of host addresses of an IPv4 network
+// other than the gateway
+func usableAddresses(network *net.IPNet, gateway string) int {
+	ones, bits := network.Mask.Size()
+	if bits != 32 || ones > 30 {
+		return 0
+	}
+	size := 1<<uint(bits-ones) - 2
+	if _, ok := addressIn(gateway, network); ok {
+		size--
+	}
+	return size
+}
+
+// AttachExternalNetwork connects the virtual firewall of a cloudspace to an
+// additional external network
+func (s *CloudSpaceServiceOp) AttachExternalNetwork(id int, externalNetworkID int) error {
+	cloudSpaceMap := make(map[string]interface{})
+	cloudSpaceMap["cloudspaceId"] = id
+	cloudSpaceMap["externalNetworkId"] = externalNetworkID
+
+	_, err := s.client.Post("/cloudapi/cloudspaces/attachExternalNetwork", cloudSpaceMap, OperationalActionTimeout)
+	return err
+}
+
+// DetachExternalNetwork disconnects the virtual firewall of a cloudspace from
+// an additional external network
+func (s *CloudSpaceServiceOp) DetachExternalNetwork(id int, externalNetworkID int) error {
+	cloudSpaceMap := make(map[string]interface{})
+	cloudSpaceMap["cloudspaceId"] = id
+	cloudSpaceMap["externalNetworkId"] = externalNetworkID
+
+	_, err := s.client.Post("/cloudapi/cloudspaces/detachExternalNetwork", cloudSpaceMap, OperationalActionTimeout)
+	return err
+}
@@ -0,0 +1,71 @@
+package ovc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExternalNetworkUsage(t *testing.T) {
+	client, stop := newTestClient(t, func(endpoint string, params map[string]interface{}) (interface{}, bool) {
+		switch endpoint {
+		case "/cloudapi/externalnetwork/get":
+			return map[string]interface{}{"id": 2, "name": "public", "network": "185.1.2.0", "subnetmask": "255.255.255.248", "gateway": "185.1.2.1"}, true
+		case "/cloudapi/externalnetwork/list":
+			return []map[string]interface{}{{"id": 1, "name": "default"}, {"id": 2, "name": "public"}}, true
+		case "/cloudapi/cloudspaces/list":
+			return []map[string]interface{}{
+				{"id": 3, "accountId": 7, "externalnetworkip": "185.1.2.2/29"},
+				{"id": 4, "accountId": 7, "externalnetworkip": "10.0.0.5"},
+				{"id": 5, "accountId": 8, "externalnetworkip": "185.1.2.6"},
+			}, true
+		case "/cloudapi/cloudspaces/get":
+			if params["cloudspaceId"] == float64(3) {
+				return map[string]interface{}{"id": 3, "externalnetworks": []map[string]interface{}{
+					{"externalnetworkId": 2, "ipaddress": "185.1.2.4/29"},
+					{"externalnetworkId": 9, "ipaddress": "185.1.2.5/29"},
+				}}, true
+			}
+			return map[string]interface{}{"id": params["cloudspaceId"]}, true
+		case "/cloudapi/cloudspaces/attachExternalNetwork", "/cloudapi/cloudspaces/detachExternalNetwork":
+			assert.Equal(t, map[string]interface{}{"cloudspaceId": float64(3), "externalNetworkId": float64(2)}, params)
+			return true, true
+		case "/cloudapi/machines/list":
+			if params["cloudspaceId"] == float64(4) {
+				return []map[string]interface{}{{"id": 40, "nics": []map[string]interface{}{
+					{"type": "bridge", "ipAddress": "192.168.103.5"},
+					{"type": "PUBLIC", "ipAddress": "185.1.2.3/29"},
+				}}}, true
+			}
+			return []interface{}{}, true
+		}
+		return "unexpected call to " + endpoint, false
+	})
+	defer stop()
+	client.CloudSpaces = &CloudSpaceServiceOp{client: client}
+	client.ExternalNetworks = &ExternalNetworkServiceOp{client: client}
+
+	usage, err := client.ExternalNetworks.Usage(2, 7)
+	assert.NoError(t, err)
+	assert.Equal(t, 5, usage.Size)
+	assert.Equal(t, []ExternalNetworkAddress{
+		{IP: "185.1.2.2", CloudSpaceID: 3},
+		{IP: "185.1.2.4", CloudSpaceID: 3},
+		{IP: "185.1.2.3", CloudSpaceID: 4, MachineID: 40},
+	}, usage.Used)
+	assert.Equal(t, 1, usage.Free, "addresses of other accounts are not free")
+
+	usage, err = client.ExternalNetworks.Usage(2, 0)
+	assert.NoError(t, err)
+	assert.Len(t, usage.Used, 4)
+	assert.Equal(t, 1, usage.Free)
+
+	assert.NoError(t, client.CloudSpaces.AttachExternalNetwork(3, 2))
+	assert.NoError(t, client.CloudSpaces.DetachExternalNetwork(3, 2))
+
+	externalNetwork, err := client.ExternalNetworks.GetByName("public", 7)
+	assert.NoError(t, err)
+	assert.Equal(t, "185.1.2.0/29", externalNetwork.IPNet().String())
+	_, err = client.ExternalNetworks.GetByName("missing", 7)
+	assert.Error(t, err)
+}
@@ -3,9 +3,8 @@ package ovc
 import (
 	"encoding/json"
 	"fmt"
-	"math/rand"
 	"strconv"
-	"time"
+	"strings"
 )
 
 // PortForwardingConfig is used when creating a portforward
@@ -22,16 +21,92 @@ type PortForwardingConfig struct {
 	ID               int    `json:"id,omitempty"`
 }
 
+// Protocol is the protocol of a port forward
+type Protocol string
+
+// Protocols of port forwards
+const (
+	ProtocolTCP Protocol = "tcp"
+	ProtocolUDP Protocol = "udp"
+)
+
+// normalize returns the protocol in lower case, tcp when empty as the G8
+// defaults to it
+func (p Protocol) normalize() Protocol {
+	if p == "" {
+		return ProtocolTCP
+	}
+	return Protocol(strings.ToLower(string(p)))
+}
+
 // PortForwardingInfo is returned when using the get method
 type PortForwardingInfo struct {
-	Protocol    string `json:"protocol"`
-	LocalPort   string `json:"localPort"`
-	MachineName string `json:"machineName"`
-	PublicIP    string `json:"publicIp"`
-	LocalIP     string `json:"localIp"`
-	MachineID   int    `json:"machineId"`
-	PublicPort  string `json:"publicPort"`
-	ID          int    `json:"id"`
+	Protocol    Protocol `json:"protocol"`
+	LocalPort   int      `json:"localPort"`
+	MachineName string   `json:"machineName"`
+	PublicIP    string   `json:"publicIp"`
+	LocalIP     string   `json:"localIp"`
+	MachineID   int      `json:"machineId"`
+	PublicPort  int      `json:"publicPort"`
+	ID          int      `json:"id"`
+}
+
+// UnmarshalJSON decodes a port forward, the G8 returns its ports as strings
+func (p *PortForwardingInfo) UnmarshalJSON(data []byte) error {
+	type info PortForwardingInfo
+	raw := struct {
+		*info
+		LocalPort  json.RawMessage `json:"localPort"`
+		PublicPort json.RawMessage `json:"publicPort"`
+	}{info: (*info)(p)}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	var err error
+	if p.LocalPort, err = decodePort(raw.LocalPort); err != nil {
+		return err
+	}
+	if p.PublicPort, err = decodePort(raw.PublicPort); err != nil {
+		return err
+	}
+	p.Protocol = p.Protocol.normalize()
+	return nil
+}
+
+// decodePort decodes a port given as a number or a string
+func decodePort(raw json.RawMessage) (int, error) {
+	text := strings.Trim(string(raw), `"`)
+	if text == "" || text == "null" {
+		return 0, nil
+	}
+	port, err := strconv.Atoi(text)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid port %s", raw)
+	}
+	return port, nil
+}
+
+// matches reports whether the port forward is the one of a public IP, port
+// and protocol, an empty public IP or protocol matches any
+func (p *PortForwardingInfo) matches(publicIP string, publicPort int, protocol Protocol) bool {
+	return p.PublicPort == publicPort &&
+		(publicIP == "" || p.PublicIP == publicIP) &&
+		(protocol == "" || p.Protocol == protocol.normalize())
+}
+
+// Validate checks a PortForwardingConfig used to create a port forward, a
+// public port of 0 picks a random free port. The protocol is case-insensitive,
+// the G8 defaults an empty one to tcp. The public IP must be a plain address,
+// CloudSpace.PublicIP returns the one of a cloudspace in that form.
+func (c *PortForwardingConfig) Validate() error {
+	v := &validator{}
+	v.requiredID("cloudspaceId", c.CloudspaceID)
+	v.requiredID("machineId", c.MachineID)
+	v.ip("publicIp", c.PublicIP)
+	v.port("publicPort", c.PublicPort, true)
+	v.port("localPort", c.LocalPort, false)
+	v.oneOf("protocol", string(Protocol(c.Protocol).normalize()), string(ProtocolTCP), string(ProtocolUDP))
+	return v.err()
 }
 
 // ForwardingService is an interface for interfacing with the portforwards
@@ -43,6 +118,8 @@ type ForwardingService interface {
 	DeleteByPort(int, string, int) error
 	Update(*PortForwardingConfig) error
 	Get(*PortForwardingConfig) (*PortForwardingInfo, error)
+	ListByMachine(int) ([]PortForwardingInfo, error)
+	EnsureForwards(int, []PortForwardingRule) ([]PortForwardingInfo, error)
 }
 
 // ForwardingServiceOp handles communication with the machine related methods of the
@@ -51,40 +128,41 @@ type ForwardingServiceOp struct {
 	client *Client
 }
 
-// Get a portforward based on ID
+// Get a portforward based on its public port, public IP and protocol. An empty
+// public IP or protocol matches any.
 func (s *ForwardingServiceOp) Get(portForwardingConfig *PortForwardingConfig) (*PortForwardingInfo, error) {
-	portForwardingList, err := s.List(portForwardingConfig)
+	portForwardingList, err := s.List(&PortForwardingConfig{CloudspaceID: portForwardingConfig.CloudspaceID})
 	if err != nil {
 		return nil, err
 	}
 	for _, portforward := range *portForwardingList {
-		if portforward.PublicPort == strconv.Itoa(portForwardingConfig.PublicPort) {
-			return &PortForwardingInfo{
-				Protocol:    portforward.Protocol,
-				LocalPort:   portforward.LocalPort,
-				MachineName: portforward.MachineName,
-				PublicIP:    portforward.PublicIP,
-				LocalIP:     portforward.LocalIP,
-				MachineID:   portforward.MachineID,
-				PublicPort:  portforward.PublicPort,
-				ID:          portforward.ID,
-			}, nil
+		if portforward.matches(portForwardingConfig.PublicIP, portForwardingConfig.PublicPort, Protocol(portForwardingConfig.Protocol)) {
+			portforward := portforward
+			return &portforward, nil
 		}
 	}
 
 	return nil, fmt.Errorf("Could not find a portforward with publicport %v", portForwardingConfig.PublicPort)
 }
 
-// Create a new portforward
+// Create a new portforward, a public port of 0 is allocated by the port
+// allocator of the public IP
 func (s *ForwardingServiceOp) Create(portForwardingConfig *PortForwardingConfig) (int, error) {
+	if err := portForwardingConfig.Validate(); err != nil {
+		return 0, err
+	}
 	if portForwardingConfig.PublicPort == 0 {
-		portForwardingConfig.PublicPort = s.getRandomPublicPort(portForwardingConfig)
+		allocator := s.client.PortAllocator(portForwardingConfig.CloudspaceID, portForwardingConfig.PublicIP)
+		return allocator.Create(portForwardingConfig)
 	}
 
 	_, err := s.client.Post("/cloudapi/portforwarding/create", *portForwardingConfig, OperationalActionTimeout)
 	if err != nil {
 		return 0, err
 	}
+	if allocator := s.client.portAllocatorIfExists(portForwardingConfig.CloudspaceID, portForwardingConfig.PublicIP); allocator != nil {
+		allocator.markUsed(portForwardingConfig.Protocol, portForwardingConfig.PublicPort)
+	}
 
 	return portForwardingConfig.PublicPort, nil
 }
@@ -92,13 +170,24 @@ func (s *ForwardingServiceOp) Create(portForwardingConfig *PortForwardingConfig)
 // Update an existing portforward
 func (s *ForwardingServiceOp) Update(portForwardingConfig *PortForwardingConfig) error {
 	_, err := s.client.Post("/cloudapi/portforwarding/updateByPort", *portForwardingConfig, OperationalActionTimeout)
-	return err
+	if err != nil {
+		return err
+	}
+	if allocator := s.client.portAllocatorIfExists(portForwardingConfig.CloudspaceID, portForwardingConfig.PublicIP); allocator != nil {
+		allocator.Release(portForwardingConfig.SourceProtocol, portForwardingConfig.SourcePublicPort)
+		allocator.markUsed(portForwardingConfig.Protocol, portForwardingConfig.PublicPort)
+	}
+	return nil
 }
 
 // Delete an existing portforward
 func (s *ForwardingServiceOp) Delete(portForwardingConfig *PortForwardingConfig) error {
 	_, err := s.client.Post("/cloudapi/portforwarding/deleteByPort", *portForwardingConfig, OperationalActionTimeout)
-	return err
+	if err != nil {
+		return err
+	}
+	s.releasePort(portForwardingConfig.CloudspaceID, portForwardingConfig.PublicIP, portForwardingConfig.Protocol, portForwardingConfig.PublicPort)
+	return nil
 }
 
 // List all portforwards
@@ -125,33 +214,24 @@ func (s *ForwardingServiceOp) DeleteByPort(publicPort int, publicIP string, clou
 	pfMap["cloudspaceId"] = cloudSpaceID
 
 	_, err := s.client.Post("/cloudapi/portforwarding/deleteByPort", pfMap, OperationalActionTimeout)
-	return err
-}
-
-func (s *ForwardingServiceOp) getRandomPublicPort(portForwardingConfig *PortForwardingConfig) int {
-	source := rand.NewSource(time.Now().UnixNano())
-	r := rand.New(source)
-	randInt := r.Intn(40000) + 2000
-	for s.hasPublicPort(portForwardingConfig, randInt) {
-		randInt = rand.Intn(40000) + 2000
+	if err != nil {
+		return err
 	}
-
-	return randInt
+	s.releasePort(cloudSpaceID, publicIP, "", publicPort)
+	return nil
 }
 
-func (s *ForwardingServiceOp) hasPublicPort(portForwardingConfig *PortForwardingConfig, publicPort int) bool {
-	config := &PortForwardingConfig{
-		CloudspaceID: portForwardingConfig.CloudspaceID,
+// releasePort frees a deleted public port in the allocator of its public IP,
+// for both protocols when the protocol is unknown
+func (s *ForwardingServiceOp) releasePort(cloudSpaceID int, publicIP string, protocol string, publicPort int) {
+	allocator := s.client.portAllocatorIfExists(cloudSpaceID, publicIP)
+	if allocator == nil {
+		return
 	}
-	list, err := s.List(config)
-	if err != nil {
-		return false
-	}
-	for _, port := range *list {
-		if port.PublicPort == strconv.Itoa(publicPort) {
-			return true
-		}
+	if protocol != "" {
+		allocator.Release(protocol, publicPort)
+		return
 	}
-
-	return false
+	allocator.Release("tcp", publicPort)
+	allocator.Release("udp", publicPort)
 }
@@ -0,0 +1,168 @@
+package ovc
+
+// PortForwardingRule is a port forward wanted on a machine
+type PortForwardingRule struct {
+	// PublicIP defaults to the public IP of the cloudspace
+	PublicIP string
+	// PublicPort of 0 matches an existing forward of the local port or
+	// allocates a free public port
+	PublicPort int
+	LocalPort  int
+	// Protocol defaults to tcp
+	Protocol Protocol
+}
+
+// Validate checks a PortForwardingRule
+func (r *PortForwardingRule) Validate() error {
+	v := &validator{}
+	if r.PublicIP != "" {
+		v.ip("publicIp", r.PublicIP)
+	}
+	v.port("publicPort", r.PublicPort, true)
+	v.port("localPort", r.LocalPort, false)
+	v.oneOf("protocol", string(r.Protocol.normalize()), string(ProtocolTCP), string(ProtocolUDP))
+	return v.err()
+}
+
+// matches reports whether a port forward satisfies the rule
+func (r *PortForwardingRule) matches(pf *PortForwardingInfo) bool {
+	if r.PublicPort != 0 {
+		return pf.matches(r.PublicIP, r.PublicPort, r.Protocol.normalize())
+	}
+	return pf.LocalPort == r.LocalPort && pf.Protocol == r.Protocol.normalize() &&
+		(r.PublicIP == "" || pf.PublicIP == r.PublicIP)
+}
+
+// ListByMachine returns the port forwards to a machine
+func (s *ForwardingServiceOp) ListByMachine(machineID int) ([]PortForwardingInfo, error) {
+	machine, err := s.client.Machines.Get(machineID)
+	if err != nil {
+		return nil, err
+	}
+	return s.listMachine(machine.CloudspaceID, machineID)
+}
+
+func (s *ForwardingServiceOp) listMachine(cloudSpaceID int, machineID int) ([]PortForwardingInfo, error) {
+	portForwardingList, err := s.List(&PortForwardingConfig{CloudspaceID: cloudSpaceID, MachineID: machineID})
+	if err != nil {
+		return nil, err
+	}
+	forwards := []PortForwardingInfo{}
+	if portForwardingList == nil {
+		return forwards, nil
+	}
+	for _, pf := range *portForwardingList {
+		if pf.MachineID == machineID {
+			forwards = append(forwards, pf)
+		}
+	}
+	return forwards, nil
+}
+
+// EnsureForwards creates, updates and deletes the port forwards of a machine
+// so it ends up with exactly the given rules, and returns its port forwards.
+// Rules with a public port are matched first, rules without one keep an
+// existing forward of their local port.
+func (s *ForwardingServiceOp) EnsureForwards(machineID int, rules []PortForwardingRule) ([]PortForwardingInfo, error) {
+	for i := range rules {
+		if err := rules[i].Validate(); err != nil {
+			return nil, err
+		}
+	}
+	machine, err := s.client.Machines.Get(machineID)
+	if err != nil {
+		return nil, err
+	}
+	cloudSpaceID := machine.CloudspaceID
+	cloudSpace, err := s.client.CloudSpaces.Get(cloudSpaceID)
+	if err != nil {
+		return nil, err
+	}
+	actual, err := s.listMachine(cloudSpaceID, machineID)
+	if err != nil {
+		return nil, err
+	}
+
+	claimed := make([]bool, len(actual))
+	claim := func(rule *PortForwardingRule) *PortForwardingInfo {
+		for i := range actual {
+			if !claimed[i] && rule.matches(&actual[i]) {
+				claimed[i] = true
+				return &actual[i]
+			}
+		}
+		return nil
+	}
+	var updates []*PortForwardingConfig
+	var creates []*PortForwardingConfig
+	plan := func(rule *PortForwardingRule) {
+		protocol := string(rule.Protocol.normalize())
+		existing := claim(rule)
+		if existing == nil {
+			publicIP := rule.PublicIP
+			if publicIP == "" {
+				publicIP = cloudSpace.PublicIP()
+			}
+			creates = append(creates, &PortForwardingConfig{
+				CloudspaceID: cloudSpaceID,
+				PublicIP:     publicIP,
+				PublicPort:   rule.PublicPort,
+				MachineID:    machineID,
+				LocalPort:    rule.LocalPort,
+				Protocol:     protocol,
+			})
+			return
+		}
+		if existing.LocalPort != rule.LocalPort {
+			updates = append(updates, &PortForwardingConfig{
+				CloudspaceID:     cloudSpaceID,
+				SourcePublicIP:   existing.PublicIP,
+				SourcePublicPort: existing.PublicPort,
+				SourceProtocol:   string(existing.Protocol),
+				PublicIP:         existing.PublicIP,
+				PublicPort:       existing.PublicPort,
+				MachineID:        machineID,
+				LocalPort:        rule.LocalPort,
+				Protocol:         protocol,
+			})
+		}
+	}
+	for i := range rules {
+		if rules[i].PublicPort != 0 {
+			plan(&rules[i])
+		}
+	}
+	for i := range rules {
+		if rules[i].PublicPort == 0 {
+			plan(&rules[i])
+		}
+	}
+
+	// delete first so the public ports are free for the new forwards
+	for i, pf := range actual {
+		if claimed[i] {
+			continue
+		}
+		err := s.Delete(&PortForwardingConfig{
+			CloudspaceID: cloudSpaceID,
+			PublicIP:     pf.PublicIP,
+			PublicPort:   pf.PublicPort,
+			Protocol:     string(pf.Protocol),
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, config := range updates {
+		if err := s.Update(config); err != nil {
+			return nil, err
+		}
+	}
+	for _, config := range creates {
+		if _, err := s.Create(config); err != nil {
+			return nil, err
+		}
+	}
+
+	return s.listMachine(cloudSpaceID, machineID)
+}
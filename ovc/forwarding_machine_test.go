@@ -0,0 +1,59 @@
+package ovc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnsureForwards(t *testing.T) {
+	forwards := []map[string]interface{}{
+		{"machineId": 5, "publicIp": "185.1.2.3", "publicPort": "80", "localPort": "8080", "protocol": "tcp"},
+		{"machineId": 5, "publicIp": "185.1.2.3", "publicPort": "2222", "localPort": "22", "protocol": "tcp"},
+		{"machineId": 5, "publicIp": "185.1.2.3", "publicPort": "53", "localPort": "53", "protocol": "udp"},
+		{"machineId": 6, "publicIp": "185.1.2.3", "publicPort": "443", "localPort": "443", "protocol": "udp"},
+	}
+	calls := []string{}
+	client, stop := newTestClient(t, func(endpoint string, params map[string]interface{}) (interface{}, bool) {
+		switch endpoint {
+		case "/cloudapi/machines/get":
+			return map[string]interface{}{"id": 5, "cloudspaceid": 3}, true
+		case "/cloudapi/cloudspaces/get":
+			return map[string]interface{}{"id": 3, "externalnetworkip": "185.1.2.3/24"}, true
+		case "/cloudapi/portforwarding/list":
+			return forwards, true
+		case "/cloudapi/portforwarding/create", "/cloudapi/portforwarding/updateByPort", "/cloudapi/portforwarding/deleteByPort":
+			calls = append(calls, endpoint[len("/cloudapi/portforwarding/"):])
+			assert.Equal(t, float64(3), params["cloudspaceId"])
+			assert.Equal(t, "185.1.2.3", params["publicIp"], "the prefix length of the cloudspace IP should be stripped")
+			return true, true
+		}
+		return "unexpected call to " + endpoint, false
+	})
+	defer stop()
+	client.CloudSpaces = &CloudSpaceServiceOp{client: client}
+	client.Portforwards = &ForwardingServiceOp{client: client}
+
+	list, err := client.Portforwards.ListByMachine(5)
+	assert.NoError(t, err)
+	if assert.Len(t, list, 3) {
+		assert.Equal(t, PortForwardingInfo{MachineID: 5, PublicIP: "185.1.2.3", PublicPort: 80, LocalPort: 8080, Protocol: ProtocolTCP}, list[0])
+	}
+
+	pf, err := client.Portforwards.Get(&PortForwardingConfig{CloudspaceID: 3, PublicPort: 443, Protocol: "udp"})
+	assert.NoError(t, err)
+	assert.Equal(t, 6, pf.MachineID)
+	_, err = client.Portforwards.Get(&PortForwardingConfig{CloudspaceID: 3, PublicPort: 443, Protocol: "tcp"})
+	assert.Error(t, err)
+
+	_, err = client.Portforwards.EnsureForwards(5, []PortForwardingRule{
+		{PublicPort: 80, LocalPort: 80},
+		{LocalPort: 22},
+		{PublicPort: 443, LocalPort: 443, Protocol: ProtocolTCP},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"deleteByPort", "updateByPort", "create"}, calls)
+
+	_, err = client.Portforwards.EnsureForwards(5, []PortForwardingRule{{LocalPort: 22, Protocol: "icmp"}})
+	assert.Error(t, err)
+}
@@ -0,0 +1,130 @@
+package ovc
+
+import (
+	"encoding/json"
+	"math"
+	"path"
+	"sort"
+	"time"
+)
+
+// HistoryEvent is an API call made on a machine or cloudspace, as audited by
+// the G8
+type HistoryEvent struct {
+	Time time.Time `json:"time"`
+	// Actor is the user who made the call
+	Actor string `json:"actor"`
+	// Action is the name of the API method, e.g. "stop" or "resize"
+	Action string `json:"action"`
+	// Call is the full API path of the call
+	Call       string                 `json:"call"`
+	Parameters map[string]interface{} `json:"parameters,omitempty"`
+	// Result is AuditOutcomeSuccess or AuditOutcomeFailure
+	Result       string  `json:"result"`
+	StatusCode   int     `json:"statusCode"`
+	ResponseTime float64 `json:"responseTime"`
+}
+
+// HistoryFilter limits the events returned by History
+type HistoryFilter struct {
+	// Start and End bound the time of the events, zero times are unbounded
+	Start time.Time
+	End   time.Time
+	// Size is the maximum number of events fetched from the G8, 0 uses the
+	// default of the G8
+	Size int
+}
+
+// historyEntry is an event as returned by the G8
+type historyEntry struct {
+	User         string          `json:"user"`
+	Call         string          `json:"call"`
+	StatusCode   int             `json:"statuscode"`
+	ResponseTime float64         `json:"responsetime"`
+	Timestamp    float64         `json:"timestamp"`
+	Args         json.RawMessage `json:"args"`
+	Kwargs       json.RawMessage `json:"kwargs"`
+}
+
+func (e *historyEntry) event() HistoryEvent {
+	seconds, fraction := math.Modf(e.Timestamp)
+	event := HistoryEvent{
+		Time:         time.Unix(int64(seconds), int64(fraction*1e9)).UTC(),
+		Actor:        e.User,
+		Action:       path.Base(e.Call),
+		Call:         e.Call,
+		Parameters:   decodeHistoryParameters(e.Kwargs),
+		Result:       AuditOutcomeSuccess,
+		StatusCode:   e.StatusCode,
+		ResponseTime: e.ResponseTime,
+	}
+	if e.StatusCode >= 300 {
+		event.Result = AuditOutcomeFailure
+	}
+	return event
+}
+
+// decodeHistoryParameters decodes the keyword arguments of a call, which the
+// G8 returns either as an object or as a JSON encoded string
+func decodeHistoryParameters(raw json.RawMessage) map[string]interface{} {
+	if len(raw) == 0 {
+		return nil
+	}
+	parameters := make(map[string]interface{})
+	if json.Unmarshal(raw, &parameters) == nil {
+		return parameters
+	}
+	encoded := ""
+	if json.Unmarshal(raw, &encoded) == nil && json.Unmarshal([]byte(encoded), &parameters) == nil {
+		return parameters
+	}
+	return nil
+}
+
+// history fetches, filters and orders the history of a machine or cloudspace
+func (c *Client) history(endpoint string, params map[string]interface{}, filter *HistoryFilter) ([]HistoryEvent, error) {
+	if filter == nil {
+		filter = &HistoryFilter{}
+	}
+	if filter.Size > 0 {
+		params["size"] = filter.Size
+	}
+	body, err := c.Post(endpoint, params, ModelActionTimeout)
+	if err != nil {
+		return nil, err
+	}
+	entries := []historyEntry{}
+	err = json.Unmarshal(body, &entries)
+	if err != nil {
+		return nil, err
+	}
+
+	events := []HistoryEvent{}
+	for _, entry := range entries {
+		event := entry.event()
+		if !filter.Start.IsZero() && event.Time.Before(filter.Start) {
+			continue
+		}
+		if !filter.End.IsZero() && !event.Time.Before(filter.End) {
+			continue
+		}
+		events = append(events, event)
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Time.Before(events[j].Time) })
+
+	return events, nil
+}
+
+// History returns the API calls made on a machine, oldest first
+func (s *MachineServiceOp) History(id int, filter *HistoryFilter) ([]HistoryEvent, error) {
+	machineMap := make(map[string]interface{})
+	machineMap["machineId"] = id
+	return s.client.history("/cloudapi/machines/getHistory", machineMap, filter)
+}
+
+// History returns the API calls made on a cloudspace, oldest first
+func (s *CloudSpaceServiceOp) History(id int, filter *HistoryFilter) ([]HistoryEvent, error) {
+	cloudSpaceMap := make(map[string]interface{})
+	cloudSpaceMap["cloudspaceId"] = id
+	return s.client.history("/cloudapi/cloudspaces/getHistory", cloudSpaceMap, filter)
+}
@@ -0,0 +1,40 @@
+package ovc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMachineHistory(t *testing.T) {
+	client, stop := newTestClient(t, func(endpoint string, params map[string]interface{}) (interface{}, bool) {
+		if endpoint != "/cloudapi/machines/getHistory" {
+			return "unexpected call to " + endpoint, false
+		}
+		return []map[string]interface{}{
+			{"user": "jdoe", "call": "/restmachine/cloudapi/machines/resize", "statuscode": 200, "timestamp": 1561000200.5,
+				"kwargs": `{"machineId": 5, "sizeId": 3}`},
+			{"user": "admin", "call": "/restmachine/cloudapi/machines/stop", "statuscode": 500, "timestamp": 1561000100,
+				"kwargs": map[string]interface{}{"machineId": 5, "force": true}},
+			{"user": "jdoe", "call": "/restmachine/cloudapi/machines/start", "statuscode": 200, "timestamp": 1560000000},
+		}, true
+	})
+	defer stop()
+
+	events, err := client.Machines.History(5, &HistoryFilter{Start: time.Unix(1561000000, 0)})
+	assert.NoError(t, err)
+	if assert.Len(t, events, 2) {
+		assert.Equal(t, "stop", events[0].Action)
+		assert.Equal(t, "admin", events[0].Actor)
+		assert.Equal(t, AuditOutcomeFailure, events[0].Result)
+		assert.Equal(t, true, events[0].Parameters["force"])
+		assert.Equal(t, "resize", events[1].Action)
+		assert.Equal(t, float64(3), events[1].Parameters["sizeId"])
+		assert.Equal(t, time.Unix(1561000200, 5e8).UTC(), events[1].Time)
+	}
+
+	events, err = client.Machines.History(5, &HistoryFilter{End: time.Unix(1561000100, 0)})
+	assert.NoError(t, err)
+	assert.Len(t, events, 1)
+}
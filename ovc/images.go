@@ -2,8 +2,22 @@ package ovc
 
 import (
 	"encoding/json"
+	"fmt"
+	"time"
 )
 
+// Image statuses as reported by the G8
+const (
+	ImageStatusCreating = "CREATING"
+	ImageStatusCreated  = "CREATED"
+	ImageStatusDisabled = "DISABLED"
+	ImageStatusError    = "ERROR"
+	ImageStatusDeleted  = "DELETED"
+)
+
+// imagePollInterval is the time between polls when waiting for an image
+var imagePollInterval = 5 * time.Second
+
 // ImageConfig is used when uploading an image
 type ImageConfig struct {
 	Name      string `json:"name"`
@@ -16,6 +30,14 @@ type ImageConfig struct {
 	AccountID int    `json:"accountId"`
 }
 
+// ImageUpdateConfig is used when renaming an image or changing its
+// description, empty fields are left unchanged
+type ImageUpdateConfig struct {
+	ImageID     int    `json:"imageId"`
+	Name        string `json:"name,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
 // ImageInfo contains information about the image returned by API
 type ImageInfo struct {
 	ID          int    `json:"id"`
@@ -26,12 +48,20 @@ type ImageInfo struct {
 	Type        string `json:"type"`
 	AccountID   int    `json:"accountId"`
 	Username    string `json:"username"`
+	BootType    string `json:"bootType,omitempty"`
 }
 
 // ImageService is an interface for interfacing with the images
 // of the OVC API
 type ImageService interface {
 	Upload(*ImageConfig) error
+	Create(*ImageConfig) (int, error)
+	WaitForImage(int, ResponseTimeout, func(*ImageInfo)) (*ImageInfo, error)
+	Get(int) (*ImageInfo, error)
+	GetByName(string, int) (*ImageInfo, error)
+	Update(*ImageUpdateConfig) error
+	Enable(int) error
+	Disable(int) error
 	Delete(int) error
 	DeleteSystemImage(int, string) error
 	List(int) (*[]ImageInfo, error)
@@ -43,9 +73,109 @@ type ImageServiceOp struct {
 	client *Client
 }
 
-// Upload uploads an image to the system API
+// Upload uploads an image to the system API and waits until it is created
+//
+// Deprecated: use Create, which returns the ID of the image, and WaitForImage.
 func (s *ImageServiceOp) Upload(imageConfig *ImageConfig) error {
-	_, err := s.client.Post("/cloudbroker/image/createImage", *imageConfig, DataActionTimeout)
+	id, err := s.Create(imageConfig)
+	if err != nil {
+		return err
+	}
+	_, err = s.WaitForImage(id, DataActionTimeout, nil)
+	return err
+}
+
+// Create creates an image from the URL of an image file and returns its ID.
+// The G8 only reports the ID once the task importing the image file is done,
+// so failures of the import are returned as well. WaitForImage can be used to
+// wait until the image is CREATED.
+func (s *ImageServiceOp) Create(imageConfig *ImageConfig) (int, error) {
+	body, err := s.client.Post("/cloudbroker/image/createImage", *imageConfig, DataActionTimeout)
+	if err != nil {
+		return 0, err
+	}
+	var id int
+	if err := json.Unmarshal(body, &id); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// WaitForImage polls an image until it is CREATED, calling progress if not nil
+// with the image after every poll. It fails when the image ends in an error
+// status.
+func (s *ImageServiceOp) WaitForImage(id int, timeout ResponseTimeout, progress func(*ImageInfo)) (*ImageInfo, error) {
+	deadline := time.Now().Add(time.Duration(timeout))
+	for {
+		image, err := s.Get(id)
+		if err != nil {
+			return nil, err
+		}
+		if progress != nil {
+			progress(image)
+		}
+		switch image.Status {
+		case ImageStatusCreated:
+			return image, nil
+		case ImageStatusError, ImageStatusDeleted:
+			return nil, fmt.Errorf("Image %d failed, status is %s", id, image.Status)
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("Timeout waiting for image %d to be created, status is %s", id, image.Status)
+		}
+		time.Sleep(imagePollInterval)
+	}
+}
+
+// Get returns an image by ID
+func (s *ImageServiceOp) Get(id int) (*ImageInfo, error) {
+	imageMap := make(map[string]interface{})
+	imageMap["imageId"] = id
+
+	body, err := s.client.Post("/cloudapi/images/get", imageMap, ModelActionTimeout)
+	if err != nil {
+		return nil, err
+	}
+	image := new(ImageInfo)
+	err = json.Unmarshal(body, &image)
+	if err != nil {
+		return nil, err
+	}
+
+	return image, nil
+}
+
+// GetByName returns an image available to an account by name, it fails with
+// an AmbiguousNameError when several images have the name
+func (s *ImageServiceOp) GetByName(name string, accountID int) (*ImageInfo, error) {
+	images, err := s.List(accountID)
+	if err != nil {
+		return nil, err
+	}
+	return findImageByName(*images, name)
+}
+
+// Update renames an image or changes its description
+func (s *ImageServiceOp) Update(imageConfig *ImageUpdateConfig) error {
+	_, err := s.client.Post("/cloudbroker/image/edit", *imageConfig, OperationalActionTimeout)
+	return err
+}
+
+// Enable makes a disabled image available for new machines
+func (s *ImageServiceOp) Enable(id int) error {
+	imageMap := make(map[string]interface{})
+	imageMap["imageId"] = id
+
+	_, err := s.client.Post("/cloudbroker/image/enable", imageMap, OperationalActionTimeout)
+	return err
+}
+
+// Disable prevents new machines from being created from an image
+func (s *ImageServiceOp) Disable(id int) error {
+	imageMap := make(map[string]interface{})
+	imageMap["imageId"] = id
+
+	_, err := s.client.Post("/cloudbroker/image/disable", imageMap, OperationalActionTimeout)
 	return err
 }
 
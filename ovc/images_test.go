@@ -0,0 +1,70 @@
+package ovc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestImageLifecycle(t *testing.T) {
+	defer func(interval time.Duration) { imagePollInterval = interval }(imagePollInterval)
+	imagePollInterval = time.Millisecond
+
+	polls := 0
+	client, stop := newTestClient(t, func(endpoint string, params map[string]interface{}) (interface{}, bool) {
+		switch endpoint {
+		case "/cloudbroker/image/createImage":
+			switch params["name"] {
+			case "ubuntu":
+				return 12, true
+			case "broken":
+				return "could not download image", false
+			}
+			return "unexpected image " + params["name"].(string), false
+		case "/cloudapi/images/get":
+			polls++
+			status := ImageStatusCreating
+			if polls >= 3 {
+				status = ImageStatusCreated
+			}
+			return map[string]interface{}{"id": params["imageId"], "name": "ubuntu", "status": status}, true
+		case "/cloudapi/images/list":
+			return []map[string]interface{}{{"id": 13, "name": "debian"}, {"id": 14, "name": "debian"}, {"id": 12, "name": "ubuntu"}}, true
+		case "/cloudbroker/image/edit":
+			assert.Equal(t, map[string]interface{}{"imageId": float64(12), "description": "LTS"}, params)
+			return true, true
+		case "/cloudbroker/image/enable", "/cloudbroker/image/disable":
+			assert.Equal(t, map[string]interface{}{"imageId": float64(12)}, params)
+			return true, true
+		}
+		return "unexpected call to " + endpoint, false
+	})
+	defer stop()
+	client.Images = &ImageServiceOp{client: client}
+
+	id, err := client.Images.Create(&ImageConfig{Name: "ubuntu", URL: "http://images/ubuntu.qcow2", AccountID: 7})
+	assert.NoError(t, err)
+	assert.Equal(t, 12, id, "the ID should be taken from the task result")
+
+	statuses := []string{}
+	image, err := client.Images.WaitForImage(id, ResponseTimeout(time.Second), func(image *ImageInfo) {
+		statuses = append(statuses, image.Status)
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, ImageStatusCreated, image.Status)
+	assert.Equal(t, []string{ImageStatusCreating, ImageStatusCreating, ImageStatusCreated}, statuses)
+
+	image, err = client.Images.GetByName("ubuntu", 7)
+	assert.NoError(t, err)
+	assert.Equal(t, 12, image.ID)
+	_, err = client.Images.GetByName("debian", 7)
+	assert.Error(t, err, "ambiguous names should be rejected")
+
+	assert.NoError(t, client.Images.Update(&ImageUpdateConfig{ImageID: 12, Description: "LTS"}))
+	assert.NoError(t, client.Images.Disable(12))
+	assert.NoError(t, client.Images.Enable(12))
+
+	_, err = client.Images.Create(&ImageConfig{Name: "broken", URL: "http://images/broken.qcow2", AccountID: 7})
+	assert.Error(t, err, "a failed import should be returned")
+}
@@ -0,0 +1,97 @@
+package inventory
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// WriteDOT writes the inventory as a Graphviz DOT graph. Accounts and
+// cloudspaces are drawn as clusters, machines, disks, external networks and
+// tunnel endpoints as nodes, and port forwards as labelled edges from the
+// public IP of the cloudspace to the machine.
+func (inv *Inventory) WriteDOT(w io.Writer) error {
+	b := bufio.NewWriter(w)
+	fmt.Fprintln(b, "digraph inventory {")
+	fmt.Fprintln(b, "  rankdir=LR;")
+	fmt.Fprintln(b, "  node [fontname=\"Helvetica\" fontsize=10];")
+	fmt.Fprintln(b, "  edge [fontname=\"Helvetica\" fontsize=9];")
+
+	externalNetworks := make(map[int]*ExternalNetwork)
+	for _, account := range inv.Accounts {
+		fmt.Fprintf(b, "  subgraph cluster_account_%d {\n", account.ID)
+		fmt.Fprintf(b, "    label=%s;\n", quote("account "+account.Name))
+		for _, cs := range account.CloudSpaces {
+			writeCloudSpace(b, cs)
+			if cs.ExternalNetwork != nil {
+				externalNetworks[cs.ExternalNetwork.ID] = cs.ExternalNetwork
+			}
+		}
+		for _, disk := range account.UnattachedDisks {
+			fmt.Fprintf(b, "    disk_%d [shape=cylinder style=dashed label=%s];\n", disk.ID, quote(diskLabel(disk)))
+		}
+		fmt.Fprintln(b, "  }")
+	}
+
+	networkIDs := make([]int, 0, len(externalNetworks))
+	for id := range externalNetworks {
+		networkIDs = append(networkIDs, id)
+	}
+	sort.Ints(networkIDs)
+	for _, id := range networkIDs {
+		network := externalNetworks[id]
+		fmt.Fprintf(b, "  extnet_%d [shape=cloud label=%s];\n", network.ID, quote(network.Name+"\n"+network.Network))
+	}
+	for _, account := range inv.Accounts {
+		for _, cs := range account.CloudSpaces {
+			if cs.ExternalNetwork != nil {
+				fmt.Fprintf(b, "  extnet_%d -> cs_%d_ip;\n", cs.ExternalNetwork.ID, cs.ID)
+			}
+			for i, tunnel := range cs.Tunnels {
+				node := fmt.Sprintf("tunnel_%d_%d", cs.ID, i)
+				fmt.Fprintf(b, "  %s [shape=box style=rounded label=%s];\n", node, quote(tunnel.RemoteAddress+"\n"+tunnel.RemoteNetwork))
+				fmt.Fprintf(b, "  cs_%d_ip -> %s [dir=both style=dashed label=\"IPsec\"];\n", cs.ID, node)
+			}
+		}
+	}
+
+	fmt.Fprintln(b, "}")
+	return b.Flush()
+}
+
+func writeCloudSpace(b *bufio.Writer, cs *CloudSpace) {
+	fmt.Fprintf(b, "    subgraph cluster_cloudspace_%d {\n", cs.ID)
+	fmt.Fprintf(b, "      label=%s;\n", quote(cs.Name+" ("+cs.PrivateNetwork+")"))
+	fmt.Fprintf(b, "      cs_%d_ip [shape=diamond label=%s];\n", cs.ID, quote(cs.PublicIP))
+	for _, machine := range cs.Machines {
+		label := fmt.Sprintf("%s\n%s\n%d vcpus, %d MB", machine.Name, machine.Image, machine.Vcpus, machine.Memory)
+		for _, nic := range machine.NICs {
+			if nic.IPAddress != "" {
+				label += "\n" + nic.Type + " " + nic.IPAddress
+			}
+		}
+		fmt.Fprintf(b, "      machine_%d [shape=box label=%s];\n", machine.ID, quote(label))
+		for _, disk := range machine.Disks {
+			fmt.Fprintf(b, "      disk_%d [shape=cylinder label=%s];\n", disk.ID, quote(diskLabel(disk)))
+			fmt.Fprintf(b, "      machine_%d -> disk_%d;\n", machine.ID, disk.ID)
+		}
+		for _, pf := range machine.PortForwards {
+			fmt.Fprintf(b, "      cs_%d_ip -> machine_%d [label=%s];\n", cs.ID, machine.ID,
+				quote(fmt.Sprintf("%d -> %d/%s", pf.PublicPort, pf.LocalPort, pf.Protocol)))
+		}
+	}
+	fmt.Fprintln(b, "    }")
+}
+
+func diskLabel(disk *Disk) string {
+	return fmt.Sprintf("%s (%s)\n%d GB", disk.Name, disk.Type, disk.Size)
+}
+
+// quote returns s as a DOT string literal, newlines become line breaks
+func quote(s string) string {
+	return `"` + dotEscaper.Replace(s) + `"`
+}
+
+var dotEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
@@ -0,0 +1,189 @@
+package inventory
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/gig-tech/ovc-sdk-go/v4/ovc"
+)
+
+// Host describes how to reach a machine over SSH
+type Host struct {
+	Name       string `json:"name"`
+	MachineID  int    `json:"machineId"`
+	CloudSpace string `json:"cloudspace"`
+	Image      string `json:"image"`
+	PrivateIP  string `json:"privateIp"`
+	// Address and Port are the public endpoint for SSH: either a directly
+	// attached external IP or the port forward to the SSH port of the machine.
+	// Address is empty when the machine can't be reached from outside.
+	Address  string `json:"address"`
+	Port     int    `json:"port"`
+	User     string `json:"user,omitempty"`
+	Password string `json:"password,omitempty"`
+}
+
+// HostsOptions tune how hosts are discovered
+type HostsOptions struct {
+	// SSHPort is the port the SSH daemon listens on inside the machines,
+	// defaults to 22
+	SSHPort int
+	// IncludeCredentials adds the initial user and password of the machines
+	// from MachineInfo.Accounts
+	IncludeCredentials bool
+}
+
+// Hosts returns a Host for every machine of a cloudspace, ordered by name
+func Hosts(client *ovc.Client, cloudSpaceID int, options HostsOptions) ([]*Host, error) {
+	if options.SSHPort == 0 {
+		options.SSHPort = 22
+	}
+	cloudSpace, err := client.CloudSpaces.Get(cloudSpaceID)
+	if err != nil {
+		return nil, err
+	}
+	portForwards, err := client.Portforwards.List(&ovc.PortForwardingConfig{CloudspaceID: cloudSpaceID})
+	if err != nil {
+		return nil, err
+	}
+	sshForwards := make(map[int]ovc.PortForwardingInfo)
+	for _, pf := range *portForwards {
+		if pf.LocalPort == options.SSHPort && pf.Protocol == ovc.ProtocolTCP {
+			sshForwards[pf.MachineID] = pf
+		}
+	}
+
+	machines, err := client.Machines.List(cloudSpaceID)
+	if err != nil {
+		return nil, err
+	}
+	hosts := []*Host{}
+	for _, machine := range *machines {
+		info, err := client.Machines.Get(machine.ID)
+		if err != nil {
+			return nil, err
+		}
+		host := &Host{
+			Name:       info.Name,
+			MachineID:  info.ID,
+			CloudSpace: cloudSpace.Name,
+			Image:      info.OsImage,
+		}
+		for _, nic := range info.Interfaces {
+			address := strings.SplitN(nic.IPAddress, "/", 2)[0]
+			if address == "" {
+				continue
+			}
+			if strings.EqualFold(nic.Type, "PUBLIC") {
+				if host.Address == "" {
+					host.Address = address
+					host.Port = options.SSHPort
+				}
+			} else if host.PrivateIP == "" {
+				host.PrivateIP = address
+			}
+		}
+		if pf, ok := sshForwards[info.ID]; ok && host.Address == "" {
+			host.Address = pf.PublicIP
+			host.Port = pf.PublicPort
+		}
+		if options.IncludeCredentials && len(info.Accounts) != 0 {
+			host.User = info.Accounts[0].Login
+			host.Password = info.Accounts[0].Password
+		}
+		hosts = append(hosts, host)
+	}
+	sort.Slice(hosts, func(i, j int) bool { return hosts[i].Name < hosts[j].Name })
+
+	return hosts, nil
+}
+
+var groupNameReplacer = regexp.MustCompile(`[^a-z0-9_]+`)
+
+// groupName turns a cloudspace or image name into a valid Ansible group name
+func groupName(prefix string, name string) string {
+	name = groupNameReplacer.ReplaceAllString(strings.ToLower(name), "_")
+	return prefix + "_" + strings.Trim(name, "_")
+}
+
+// AnsibleInventory returns the reachable hosts in the JSON format of an
+// Ansible dynamic inventory (the output of `--list`), grouped by cloudspace and
+// image. Host variables are included in _meta so Ansible doesn't need to call
+// the inventory per host.
+func AnsibleInventory(hosts []*Host) map[string]interface{} {
+	hostVars := make(map[string]map[string]interface{})
+	groups := make(map[string][]string)
+	for _, host := range hosts {
+		if host.Address == "" {
+			continue
+		}
+		vars := map[string]interface{}{
+			"ansible_host":   host.Address,
+			"ansible_port":   host.Port,
+			"ovc_machine_id": host.MachineID,
+			"ovc_cloudspace": host.CloudSpace,
+			"ovc_image":      host.Image,
+			"ovc_private_ip": host.PrivateIP,
+		}
+		if host.User != "" {
+			vars["ansible_user"] = host.User
+		}
+		if host.Password != "" {
+			vars["ansible_password"] = host.Password
+		}
+		hostVars[host.Name] = vars
+
+		for _, group := range []string{groupName("cloudspace", host.CloudSpace), groupName("image", host.Image)} {
+			groups[group] = append(groups[group], host.Name)
+		}
+	}
+
+	inventory := map[string]interface{}{
+		"_meta": map[string]interface{}{"hostvars": hostVars},
+	}
+	children := make([]string, 0, len(groups))
+	for group, members := range groups {
+		inventory[group] = map[string]interface{}{"hosts": members}
+		children = append(children, group)
+	}
+	sort.Strings(children)
+	inventory["all"] = map[string]interface{}{"children": children}
+
+	return inventory
+}
+
+// WriteAnsibleInventory writes the Ansible dynamic inventory of hosts as JSON
+func WriteAnsibleInventory(w io.Writer, hosts []*Host) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(AnsibleInventory(hosts))
+}
+
+// WriteSSHConfig writes an ~/.ssh/config fragment with a Host entry for every
+// reachable host. identityFile is added to every entry when not empty.
+func WriteSSHConfig(w io.Writer, hosts []*Host, identityFile string) error {
+	b := bufio.NewWriter(w)
+	for _, host := range hosts {
+		if host.Address == "" {
+			fmt.Fprintf(b, "# %s (%s) is not reachable: no public IP or port forward to SSH\n\n", host.Name, host.CloudSpace)
+			continue
+		}
+		fmt.Fprintf(b, "# machine %d in cloudspace %s\n", host.MachineID, host.CloudSpace)
+		fmt.Fprintf(b, "Host %s\n", host.Name)
+		fmt.Fprintf(b, "    HostName %s\n", host.Address)
+		fmt.Fprintf(b, "    Port %d\n", host.Port)
+		if host.User != "" {
+			fmt.Fprintf(b, "    User %s\n", host.User)
+		}
+		if identityFile != "" {
+			fmt.Fprintf(b, "    IdentityFile %s\n", identityFile)
+		}
+		fmt.Fprintln(b)
+	}
+	return b.Flush()
+}
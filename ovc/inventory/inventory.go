@@ -0,0 +1,316 @@
+// Package inventory snapshots the resources of G8 accounts into a single
+// document with the relationships between them resolved, which can be exported
+// as JSON or as a Graphviz DOT graph.
+package inventory
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gig-tech/ovc-sdk-go/v4/ovc"
+)
+
+// Inventory is a snapshot of one or more accounts
+type Inventory struct {
+	GeneratedAt time.Time  `json:"generatedAt"`
+	Location    string     `json:"location"`
+	Accounts    []*Account `json:"accounts"`
+}
+
+// Account is an account with all of its resources
+type Account struct {
+	ID          int           `json:"id"`
+	Name        string        `json:"name"`
+	CloudSpaces []*CloudSpace `json:"cloudspaces"`
+	// UnattachedDisks are disks of the account not attached to any machine
+	UnattachedDisks []*Disk `json:"unattachedDisks,omitempty"`
+}
+
+// CloudSpace is a cloudspace with its machines and tunnels
+type CloudSpace struct {
+	ID              int              `json:"id"`
+	Name            string           `json:"name"`
+	Status          string           `json:"status"`
+	Location        string           `json:"location"`
+	PrivateNetwork  string           `json:"privateNetwork"`
+	PublicIP        string           `json:"publicIp"`
+	ExternalNetwork *ExternalNetwork `json:"externalNetwork,omitempty"`
+	Machines        []*Machine       `json:"machines"`
+	Tunnels         []*Tunnel        `json:"tunnels,omitempty"`
+}
+
+// ExternalNetwork is the external network a cloudspace is connected to
+type ExternalNetwork struct {
+	ID      int    `json:"id"`
+	Name    string `json:"name"`
+	Network string `json:"network"`
+	Gateway string `json:"gateway"`
+}
+
+// Machine is a machine with its disks, NICs and port forwards
+type Machine struct {
+	ID           int            `json:"id"`
+	Name         string         `json:"name"`
+	Status       string         `json:"status"`
+	Vcpus        int            `json:"vcpus"`
+	Memory       int            `json:"memory"`
+	ImageID      int            `json:"imageId"`
+	Image        string         `json:"image"`
+	Disks        []*Disk        `json:"disks"`
+	NICs         []*NIC         `json:"nics"`
+	PortForwards []*PortForward `json:"portForwards,omitempty"`
+}
+
+// Disk is a boot or data disk
+type Disk struct {
+	ID     int    `json:"id"`
+	Name   string `json:"name"`
+	Type   string `json:"type"`
+	Size   int    `json:"size"`
+	Status string `json:"status,omitempty"`
+}
+
+// NIC is a network interface of a machine
+type NIC struct {
+	Type       string `json:"type"`
+	IPAddress  string `json:"ipAddress"`
+	MacAddress string `json:"macAddress"`
+	NetworkID  int    `json:"networkId"`
+}
+
+// PortForward forwards a public port of the cloudspace to a machine
+type PortForward struct {
+	PublicIP   string `json:"publicIp"`
+	PublicPort int    `json:"publicPort"`
+	Protocol   string `json:"protocol"`
+	LocalPort  int    `json:"localPort"`
+}
+
+// Tunnel is an IPsec tunnel of a cloudspace
+type Tunnel struct {
+	RemoteAddress string `json:"remoteAddress"`
+	RemoteNetwork string `json:"remoteNetwork"`
+}
+
+// Snapshot returns the inventory of a single account
+func Snapshot(client *ovc.Client, accountID int) (*Inventory, error) {
+	return snapshot(client, func(account ovc.AccountInfo) bool {
+		return account.ID == accountID
+	})
+}
+
+// SnapshotAll returns the inventory of all accounts the client has access to
+func SnapshotAll(client *ovc.Client) (*Inventory, error) {
+	return snapshot(client, func(ovc.AccountInfo) bool { return true })
+}
+
+func snapshot(client *ovc.Client, include func(ovc.AccountInfo) bool) (*Inventory, error) {
+	accounts, err := client.Accounts.List()
+	if err != nil {
+		return nil, err
+	}
+	cloudSpaces, err := client.CloudSpaces.List()
+	if err != nil {
+		return nil, err
+	}
+
+	inventory := &Inventory{
+		GeneratedAt: time.Now().UTC(),
+		Location:    client.GetLocation(),
+		Accounts:    []*Account{},
+	}
+	for _, accountInfo := range *accounts {
+		if !include(accountInfo) {
+			continue
+		}
+		account, err := snapshotAccount(client, accountInfo, *cloudSpaces)
+		if err != nil {
+			return nil, fmt.Errorf("Could not snapshot account %s: %s", accountInfo.Name, err)
+		}
+		inventory.Accounts = append(inventory.Accounts, account)
+	}
+	if len(inventory.Accounts) == 0 {
+		return nil, fmt.Errorf("No matching account found")
+	}
+
+	return inventory, nil
+}
+
+func snapshotAccount(client *ovc.Client, accountInfo ovc.AccountInfo, cloudSpaces []ovc.CloudSpaceInfo) (*Account, error) {
+	account := &Account{
+		ID:          accountInfo.ID,
+		Name:        accountInfo.Name,
+		CloudSpaces: []*CloudSpace{},
+	}
+
+	externalNetworks, err := client.ExternalNetworks.List(account.ID)
+	if err != nil {
+		return nil, err
+	}
+	images, err := client.Images.List(account.ID)
+	if err != nil {
+		return nil, err
+	}
+	imageNames := make(map[int]string, len(*images))
+	for _, image := range *images {
+		imageNames[image.ID] = image.Name
+	}
+
+	attached := make(map[int]bool)
+	for _, cloudSpaceInfo := range cloudSpaces {
+		if cloudSpaceInfo.AccountID != account.ID {
+			continue
+		}
+		cloudSpace, err := snapshotCloudSpace(client, cloudSpaceInfo.ID, *externalNetworks, imageNames)
+		if err != nil {
+			return nil, fmt.Errorf("cloudspace %s: %s", cloudSpaceInfo.Name, err)
+		}
+		for _, machine := range cloudSpace.Machines {
+			for _, disk := range machine.Disks {
+				attached[disk.ID] = true
+			}
+		}
+		account.CloudSpaces = append(account.CloudSpaces, cloudSpace)
+	}
+
+	disks, err := client.Disks.List(account.ID, "")
+	if err != nil {
+		return nil, err
+	}
+	for _, disk := range *disks {
+		if attached[disk.ID] {
+			continue
+		}
+		account.UnattachedDisks = append(account.UnattachedDisks, &Disk{
+			ID:     disk.ID,
+			Name:   disk.Name,
+			Type:   disk.Type,
+			Size:   disk.Size,
+			Status: disk.Status,
+		})
+	}
+
+	return account, nil
+}
+
+func snapshotCloudSpace(client *ovc.Client, id int, externalNetworks []ovc.ExternalNetworkInfo, imageNames map[int]string) (*CloudSpace, error) {
+	cs, err := client.CloudSpaces.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	cloudSpace := &CloudSpace{
+		ID:             cs.ID,
+		Name:           cs.Name,
+		Status:         cs.Status,
+		Location:       cs.Location,
+		PrivateNetwork: cs.PrivateNetwork,
+		PublicIP:       cs.Externalnetworkip,
+		Machines:       []*Machine{},
+	}
+	cloudSpace.ExternalNetwork = findExternalNetwork(cs.Externalnetworkip, externalNetworks)
+
+	portForwards, err := client.Portforwards.List(&ovc.PortForwardingConfig{CloudspaceID: id})
+	if err != nil {
+		return nil, err
+	}
+	forwardsByMachine := make(map[int][]*PortForward)
+	for _, pf := range *portForwards {
+		forwardsByMachine[pf.MachineID] = append(forwardsByMachine[pf.MachineID], &PortForward{
+			PublicIP:   pf.PublicIP,
+			PublicPort: pf.PublicPort,
+			Protocol:   string(pf.Protocol),
+			LocalPort:  pf.LocalPort,
+		})
+	}
+
+	machines, err := client.Machines.List(id)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range *machines {
+		info, err := client.Machines.Get(m.ID)
+		if err != nil {
+			return nil, fmt.Errorf("machine %s: %s", m.Name, err)
+		}
+		machine := &Machine{
+			ID:           info.ID,
+			Name:         info.Name,
+			Status:       info.Status,
+			Vcpus:        info.Vcpus,
+			Memory:       info.Memory,
+			ImageID:      info.ImageID,
+			Image:        imageNames[info.ImageID],
+			Disks:        []*Disk{},
+			NICs:         []*NIC{},
+			PortForwards: forwardsByMachine[info.ID],
+		}
+		if machine.Image == "" {
+			machine.Image = info.OsImage
+		}
+		for _, disk := range info.Disks {
+			machine.Disks = append(machine.Disks, &Disk{
+				ID:     disk.ID,
+				Name:   disk.Name,
+				Type:   disk.Type,
+				Size:   disk.SizeMax,
+				Status: disk.Status,
+			})
+		}
+		for _, nic := range info.Interfaces {
+			machine.NICs = append(machine.NICs, &NIC{
+				Type:       nic.Type,
+				IPAddress:  nic.IPAddress,
+				MacAddress: nic.MacAddress,
+				NetworkID:  nic.NetworkID,
+			})
+		}
+		cloudSpace.Machines = append(cloudSpace.Machines, machine)
+	}
+	sort.Slice(cloudSpace.Machines, func(i, j int) bool { return cloudSpace.Machines[i].ID < cloudSpace.Machines[j].ID })
+
+	tunnels, err := client.Ipsec.List(&ovc.IpsecConfig{CloudspaceID: id})
+	if err != nil {
+		return nil, err
+	}
+	for _, tunnel := range *tunnels {
+		cloudSpace.Tunnels = append(cloudSpace.Tunnels, &Tunnel{
+			RemoteAddress: tunnel.RemoteAddr,
+			RemoteNetwork: tunnel.RemotePrivateNetwork,
+		})
+	}
+
+	return cloudSpace, nil
+}
+
+// findExternalNetwork returns the external network containing ip, if any
+func findExternalNetwork(ip string, externalNetworks []ovc.ExternalNetworkInfo) *ExternalNetwork {
+	// the external network IP of a cloudspace may be given in CIDR notation
+	address := net.ParseIP(strings.SplitN(ip, "/", 2)[0])
+	if address == nil {
+		return nil
+	}
+	for _, externalNetwork := range externalNetworks {
+		network := externalNetwork.IPNet()
+		if network != nil && network.Contains(address) {
+			return &ExternalNetwork{
+				ID:      externalNetwork.ID,
+				Name:    externalNetwork.Name,
+				Network: network.String(),
+				Gateway: externalNetwork.Gateway,
+			}
+		}
+	}
+	return nil
+}
+
+// WriteJSON writes the inventory as indented JSON
+func (inv *Inventory) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(inv)
+}
@@ -0,0 +1,169 @@
+package inventory
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/gig-tech/ovc-sdk-go/v4/ovc"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFindExternalNetwork(t *testing.T) {
+	externalNetworks := []ovc.ExternalNetworkInfo{
+		{ID: 1, Name: "public", Network: "185.15.201.0", Subnetmask: "255.255.255.0", Gateway: "185.15.201.1"},
+		{ID: 2, Name: "backbone", Network: "10.101.0.0/16"},
+	}
+
+	network := findExternalNetwork("185.15.201.114/24", externalNetworks)
+	if assert.NotNil(t, network) {
+		assert.Equal(t, 1, network.ID)
+		assert.Equal(t, "185.15.201.0/24", network.Network)
+	}
+	network = findExternalNetwork("10.101.3.4", externalNetworks)
+	if assert.NotNil(t, network) {
+		assert.Equal(t, 2, network.ID)
+	}
+	assert.Nil(t, findExternalNetwork("8.8.8.8", externalNetworks))
+	assert.Nil(t, findExternalNetwork("", externalNetworks))
+}
+
+func TestWriteDOT(t *testing.T) {
+	inv := &Inventory{
+		Accounts: []*Account{{
+			ID:   1,
+			Name: "acme",
+			CloudSpaces: []*CloudSpace{{
+				ID:              10,
+				Name:            "web",
+				PrivateNetwork:  "192.168.103.0/24",
+				PublicIP:        "185.15.201.114",
+				ExternalNetwork: &ExternalNetwork{ID: 1, Name: "public", Network: "185.15.201.0/24"},
+				Machines: []*Machine{{
+					ID:           100,
+					Name:         "web-1",
+					Image:        "Ubuntu 18.04",
+					Disks:        []*Disk{{ID: 5, Name: "boot", Type: "B", Size: 10}},
+					PortForwards: []*PortForward{{PublicPort: 2222, LocalPort: 22, Protocol: "tcp"}},
+				}},
+				Tunnels: []*Tunnel{{RemoteAddress: "1.2.3.4", RemoteNetwork: "10.0.0.0/24"}},
+			}},
+			UnattachedDisks: []*Disk{{ID: 6, Name: "orphan \"old\"", Type: "D", Size: 100}},
+		}},
+	}
+
+	buf := &bytes.Buffer{}
+	assert.NoError(t, inv.WriteDOT(buf))
+	dot := buf.String()
+	assert.True(t, strings.HasPrefix(dot, "digraph inventory {"))
+	assert.Contains(t, dot, `machine_100 -> disk_5;`)
+	assert.Contains(t, dot, `cs_10_ip -> machine_100 [label="2222 -> 22/tcp"];`)
+	assert.Contains(t, dot, `extnet_1 -> cs_10_ip;`)
+	assert.Contains(t, dot, `label="orphan \"old\" (D)\n100 GB"`)
+	assert.Contains(t, dot, `cs_10_ip -> tunnel_10_0`)
+}
+
+func TestAnsibleInventory(t *testing.T) {
+	hosts := []*Host{
+		{Name: "web-1", MachineID: 1, CloudSpace: "Web Tier", Image: "Ubuntu 18.04", Address: "1.2.3.4", Port: 7122, User: "cloudscalers", Password: "secret"},
+		{Name: "db-1", MachineID: 2, CloudSpace: "Web Tier", Image: "Ubuntu 18.04"},
+	}
+
+	inv := AnsibleInventory(hosts)
+	assert.Equal(t, map[string]interface{}{"hosts": []string{"web-1"}}, inv["cloudspace_web_tier"])
+	assert.Equal(t, map[string]interface{}{"hosts": []string{"web-1"}}, inv["image_ubuntu_18_04"])
+	hostVars := inv["_meta"].(map[string]interface{})["hostvars"].(map[string]map[string]interface{})
+	assert.Equal(t, 7122, hostVars["web-1"]["ansible_port"])
+	assert.Equal(t, "secret", hostVars["web-1"]["ansible_password"])
+	assert.NotContains(t, hostVars, "db-1", "unreachable hosts should be left out")
+
+	buf := &bytes.Buffer{}
+	assert.NoError(t, WriteSSHConfig(buf, hosts, "~/.ssh/id_ed25519"))
+	assert.Contains(t, buf.String(), "Host web-1\n    HostName 1.2.3.4\n    Port 7122\n    User cloudscalers\n    IdentityFile ~/.ssh/id_ed25519\n")
+	assert.Contains(t, buf.String(), "# db-1 (Web Tier) is not reachable")
+}
+
+// fakeMachines, fakeCloudSpaces and fakePortForwards answer the calls Hosts
+// makes, any other call panics on the nil embedded service
+type fakeMachines struct {
+	ovc.MachineService
+	machines []*ovc.MachineInfo
+}
+
+func (f *fakeMachines) List(cloudSpaceID int) (*[]ovc.Machine, error) {
+	machines := []ovc.Machine{}
+	for _, machine := range f.machines {
+		machines = append(machines, ovc.Machine{ID: machine.ID, Name: machine.Name})
+	}
+	return &machines, nil
+}
+
+func (f *fakeMachines) Get(id int) (*ovc.MachineInfo, error) {
+	for _, machine := range f.machines {
+		if machine.ID == id {
+			return machine, nil
+		}
+	}
+	return nil, ovc.ErrNotFound
+}
+
+type fakeCloudSpaces struct {
+	ovc.CloudSpaceService
+}
+
+func (f *fakeCloudSpaces) Get(id int) (*ovc.CloudSpace, error) {
+	return &ovc.CloudSpace{ID: id, Name: "web"}, nil
+}
+
+type fakePortForwards struct {
+	ovc.ForwardingService
+	forwards []ovc.PortForwardingInfo
+}
+
+func (f *fakePortForwards) List(config *ovc.PortForwardingConfig) (*[]ovc.PortForwardingInfo, error) {
+	return &f.forwards, nil
+}
+
+func TestHosts(t *testing.T) {
+	credentials := []ovc.UserAccount{{Login: "cloudscalers", Password: "secret"}}
+	client := &ovc.Client{
+		CloudSpaces: &fakeCloudSpaces{},
+		Machines: &fakeMachines{machines: []*ovc.MachineInfo{
+			{ID: 1, Name: "web-1", OsImage: "Ubuntu 18.04", Accounts: credentials, Interfaces: []ovc.NIC{
+				{Type: "bridge", IPAddress: "192.168.103.11"},
+				{Type: "PUBLIC", IPAddress: "185.15.201.20/24"},
+			}},
+			{ID: 2, Name: "db-1", Accounts: credentials, Interfaces: []ovc.NIC{{Type: "bridge", IPAddress: "192.168.103.12"}}},
+			{ID: 3, Name: "cache-1", Interfaces: []ovc.NIC{{Type: "bridge", IPAddress: "192.168.103.13"}}},
+		}},
+		Portforwards: &fakePortForwards{forwards: []ovc.PortForwardingInfo{
+			{MachineID: 1, PublicIP: "185.15.201.114", PublicPort: 7022, LocalPort: 22, Protocol: ovc.ProtocolTCP},
+			{MachineID: 2, PublicIP: "185.15.201.114", PublicPort: 7122, LocalPort: 22, Protocol: ovc.ProtocolTCP},
+			{MachineID: 3, PublicIP: "185.15.201.114", PublicPort: 7222, LocalPort: 22, Protocol: ovc.ProtocolUDP},
+			{MachineID: 3, PublicIP: "185.15.201.114", PublicPort: 7223, LocalPort: 2200, Protocol: ovc.ProtocolTCP},
+		}},
+	}
+
+	hosts, err := Hosts(client, 10, HostsOptions{})
+	if !assert.NoError(t, err) || !assert.Len(t, hosts, 3) {
+		return
+	}
+	assert.Equal(t, []string{"cache-1", "db-1", "web-1"}, []string{hosts[0].Name, hosts[1].Name, hosts[2].Name})
+	assert.Equal(t, &Host{Name: "web-1", MachineID: 1, CloudSpace: "web", Image: "Ubuntu 18.04", PrivateIP: "192.168.103.11",
+		Address: "185.15.201.20", Port: 22}, hosts[2], "a public NIC should be preferred over the port forward")
+	assert.Equal(t, "185.15.201.114", hosts[1].Address)
+	assert.Equal(t, 7122, hosts[1].Port)
+	assert.Empty(t, hosts[1].User, "credentials should only be added when asked for")
+	assert.Empty(t, hosts[0].Address, "udp forwards and forwards to other ports should be ignored")
+
+	hosts, err = Hosts(client, 10, HostsOptions{SSHPort: 2200, IncludeCredentials: true})
+	if !assert.NoError(t, err) || !assert.Len(t, hosts, 3) {
+		return
+	}
+	assert.Equal(t, 7223, hosts[0].Port)
+	assert.Empty(t, hosts[1].Address)
+	assert.Equal(t, 2200, hosts[2].Port)
+	assert.Equal(t, "cloudscalers", hosts[1].User)
+	assert.Equal(t, "secret", hosts[1].Password)
+	assert.Empty(t, hosts[0].User)
+}
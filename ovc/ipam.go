@@ -0,0 +1,302 @@
+package ovc
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+)
+
+// NetworkOverlapError is returned when a private network overlaps a network
+// already in use
+type NetworkOverlapError struct {
+	Network  string
+	Existing string
+	// Owner describes what uses the existing network, e.g. "cloudspace 12"
+	Owner string
+}
+
+func (e *NetworkOverlapError) Error() string {
+	return fmt.Sprintf("Network %s overlaps %s of %s", e.Network, e.Existing, e.Owner)
+}
+
+// ipamEntry is a network in use
+type ipamEntry struct {
+	network *net.IPNet
+	owner   string
+	// cloudSpaceID is the cloudspace using the network as private network
+	// or the cloudspace of the tunnel to the network, 0 for reservations
+	cloudSpaceID int
+}
+
+// IPAM allocates non-overlapping private networks for cloudspaces from a
+// supernet. The private networks of the cloudspaces and the remote networks
+// of their IPsec tunnels are listed once, allocated networks are reserved
+// in-process so concurrent creates don't pick the same network.
+type IPAM struct {
+	client     *Client
+	supernet   *net.IPNet
+	accountIDs map[int]bool
+
+	mu      sync.Mutex
+	loaded  bool
+	entries []ipamEntry
+}
+
+// NewIPAM returns an IPAM allocating from an IPv4 supernet, e.g.
+// 10.100.0.0/16, taking the cloudspaces of the given accounts or of all
+// accounts when none are given into account
+func NewIPAM(client *Client, supernet string, accountIDs ...int) (*IPAM, error) {
+	_, network, err := net.ParseCIDR(supernet)
+	if err != nil {
+		return nil, err
+	}
+	if network.IP.To4() == nil {
+		return nil, fmt.Errorf("Supernet %s is not an IPv4 network", supernet)
+	}
+	ipam := &IPAM{
+		client:     client,
+		supernet:   network,
+		accountIDs: make(map[int]bool),
+	}
+	for _, id := range accountIDs {
+		ipam.accountIDs[id] = true
+	}
+	return ipam, nil
+}
+
+// load lists the networks in use, m.mu must be held
+func (m *IPAM) load() error {
+	cloudSpaces, err := m.client.CloudSpaces.List()
+	if err != nil {
+		return err
+	}
+	entries := []ipamEntry{}
+	if cloudSpaces != nil {
+		for _, cs := range *cloudSpaces {
+			if len(m.accountIDs) != 0 && !m.accountIDs[cs.AccountID] {
+				continue
+			}
+			cloudSpace, err := m.client.CloudSpaces.Get(cs.ID)
+			if err != nil {
+				return err
+			}
+			if _, network, err := net.ParseCIDR(cloudSpace.PrivateNetwork); err == nil {
+				entries = append(entries, ipamEntry{
+					network:      network,
+					owner:        "cloudspace " + strconv.Itoa(cs.ID),
+					cloudSpaceID: cs.ID,
+				})
+			}
+			tunnels, err := m.client.Ipsec.List(&IpsecConfig{CloudspaceID: cs.ID})
+			if err != nil {
+				return err
+			}
+			for _, tunnel := range *tunnels {
+				if _, network, err := net.ParseCIDR(tunnel.RemotePrivateNetwork); err == nil {
+					entries = append(entries, ipamEntry{
+						network:      network,
+						owner:        fmt.Sprintf("tunnel to %s of cloudspace %d", tunnel.RemoteAddr, cs.ID),
+						cloudSpaceID: cs.ID,
+					})
+				}
+			}
+		}
+	}
+
+	// keep the in-process reservations
+	for _, entry := range m.entries {
+		if entry.cloudSpaceID == 0 {
+			entries = append(entries, entry)
+		}
+	}
+	m.entries = entries
+	m.loaded = true
+	return nil
+}
+
+// Refresh lists the networks in use again, keeping the reserved networks
+func (m *IPAM) Refresh() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.load()
+}
+
+func (m *IPAM) ensureLoaded() error {
+	if m.loaded {
+		return nil
+	}
+	return m.load()
+}
+
+func overlaps(a *net.IPNet, b *net.IPNet) bool {
+	return a.Contains(b.IP) || b.Contains(a.IP)
+}
+
+func sameNetwork(a *net.IPNet, b *net.IPNet) bool {
+	return a.IP.Equal(b.IP) && a.Mask.String() == b.Mask.String()
+}
+
+// overlap returns a NetworkOverlapError for the first entry overlapping
+// network, ignoring the entries for which skip returns true
+func (m *IPAM) overlap(network *net.IPNet, skip func(*ipamEntry) bool) error {
+	for i := range m.entries {
+		entry := &m.entries[i]
+		if skip != nil && skip(entry) {
+			continue
+		}
+		if overlaps(network, entry.network) {
+			return &NetworkOverlapError{Network: network.String(), Existing: entry.network.String(), Owner: entry.owner}
+		}
+	}
+	return nil
+}
+
+// Allocate reserves the first free subnet of the supernet with the given
+// prefix length, e.g. 24, and returns it in CIDR notation
+func (m *IPAM) Allocate(prefixLength int) (string, error) {
+	ones, bits := m.supernet.Mask.Size()
+	if prefixLength < ones || prefixLength > 30 {
+		return "", fmt.Errorf("Prefix length must be between %d and 30, got %d", ones, prefixLength)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.ensureLoaded(); err != nil {
+		return "", err
+	}
+
+	base := binary.BigEndian.Uint32(m.supernet.IP.To4())
+	size := uint64(1) << uint(bits-prefixLength)
+	count := uint64(1) << uint(prefixLength-ones)
+	mask := net.CIDRMask(prefixLength, bits)
+	for i := uint64(0); i < count; i++ {
+		ip := make(net.IP, net.IPv4len)
+		binary.BigEndian.PutUint32(ip, base+uint32(i*size))
+		candidate := &net.IPNet{IP: ip, Mask: mask}
+		if m.overlap(candidate, nil) == nil {
+			m.entries = append(m.entries, ipamEntry{network: candidate, owner: "a reservation"})
+			return candidate.String(), nil
+		}
+	}
+
+	return "", fmt.Errorf("No free /%d network left in %s", prefixLength, m.supernet)
+}
+
+// Reserve marks a network as used, it fails with a NetworkOverlapError if it
+// overlaps a network in use
+func (m *IPAM) Reserve(network string) error {
+	_, ipNet, err := net.ParseCIDR(network)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.ensureLoaded(); err != nil {
+		return err
+	}
+	if err := m.overlap(ipNet, nil); err != nil {
+		return err
+	}
+	m.entries = append(m.entries, ipamEntry{network: ipNet, owner: "a reservation"})
+	return nil
+}
+
+// Release frees a reserved network
+func (m *IPAM) Release(network string) {
+	_, ipNet, err := net.ParseCIDR(network)
+	if err != nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, entry := range m.entries {
+		if entry.cloudSpaceID == 0 && sameNetwork(entry.network, ipNet) {
+			m.entries = append(m.entries[:i], m.entries[i+1:]...)
+			return
+		}
+	}
+}
+
+// assign records the cloudspace using a reserved network
+func (m *IPAM) assign(network string, cloudSpaceID int) {
+	_, ipNet, _ := net.ParseCIDR(network)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range m.entries {
+		entry := &m.entries[i]
+		if entry.cloudSpaceID == 0 && sameNetwork(entry.network, ipNet) {
+			entry.cloudSpaceID = cloudSpaceID
+			entry.owner = "cloudspace " + strconv.Itoa(cloudSpaceID)
+			return
+		}
+	}
+}
+
+// CreateCloudSpace creates a cloudspace with a private network which doesn't
+// overlap any network in use. An empty PrivateNetwork is allocated with the
+// given prefix length.
+func (m *IPAM) CreateCloudSpace(cloudSpaceConfig *CloudSpaceConfig, prefixLength int) (int, error) {
+	if cloudSpaceConfig.PrivateNetwork == "" {
+		network, err := m.Allocate(prefixLength)
+		if err != nil {
+			return 0, err
+		}
+		cloudSpaceConfig.PrivateNetwork = network
+	} else if err := m.Reserve(cloudSpaceConfig.PrivateNetwork); err != nil {
+		return 0, err
+	}
+
+	id, err := m.client.CloudSpaces.Create(cloudSpaceConfig)
+	if err != nil {
+		m.Release(cloudSpaceConfig.PrivateNetwork)
+		return 0, err
+	}
+	m.assign(cloudSpaceConfig.PrivateNetwork, id)
+	return id, nil
+}
+
+// ValidateTunnel checks that the remote network of a tunnel overlaps neither
+// the private network of its cloudspace nor the other tunnels of the
+// cloudspace. Other networks in use may only be the remote network exactly,
+// e.g. the private network of the cloudspace at the other end of the tunnel.
+func (m *IPAM) ValidateTunnel(ipsecConfig *IpsecConfig) error {
+	_, remote, err := net.ParseCIDR(ipsecConfig.RemotePrivateNetwork)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.ensureLoaded(); err != nil {
+		return err
+	}
+	return m.overlap(remote, func(entry *ipamEntry) bool {
+		if entry.cloudSpaceID == ipsecConfig.CloudspaceID {
+			return false
+		}
+		return sameNetwork(entry.network, remote)
+	})
+}
+
+// CreateTunnel creates an ipsec tunnel after validating its remote network
+// with ValidateTunnel
+func (m *IPAM) CreateTunnel(ipsecConfig *IpsecConfig) (string, error) {
+	if err := m.ValidateTunnel(ipsecConfig); err != nil {
+		return "", err
+	}
+	psk, err := m.client.Ipsec.Create(ipsecConfig)
+	if err != nil {
+		return "", err
+	}
+
+	_, remote, _ := net.ParseCIDR(ipsecConfig.RemotePrivateNetwork)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.entries = append(m.entries, ipamEntry{
+		network:      remote,
+		owner:        fmt.Sprintf("tunnel to %s of cloudspace %d", ipsecConfig.RemotePublicAddr, ipsecConfig.CloudspaceID),
+		cloudSpaceID: ipsecConfig.CloudspaceID,
+	})
+	return psk, nil
+}
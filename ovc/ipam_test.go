@@ -0,0 +1,70 @@
+package ovc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIPAM(t *testing.T) {
+	created := []string{}
+	client, stop := newTestClient(t, func(endpoint string, params map[string]interface{}) (interface{}, bool) {
+		switch endpoint {
+		case "/cloudapi/cloudspaces/list":
+			return []map[string]interface{}{{"id": 1, "accountId": 7}, {"id": 2, "accountId": 7}, {"id": 3, "accountId": 8}}, true
+		case "/cloudapi/cloudspaces/get":
+			networks := map[float64]string{1: "10.100.0.0/24", 2: "10.100.2.0/24", 3: "10.100.1.0/24"}
+			return map[string]interface{}{"id": params["cloudspaceId"], "privatenetwork": networks[params["cloudspaceId"].(float64)]}, true
+		case "/cloudapi/ipsec/listTunnels":
+			if params["cloudspaceId"] == float64(1) {
+				return []map[string]interface{}{
+					{"remoteAddr": "5.6.7.8", "remoteprivatenetwork": "10.100.2.0/24"},
+					{"remoteAddr": "1.2.3.4", "remoteprivatenetwork": "10.100.4.0/23"},
+				}, true
+			}
+			return nil, true
+		case "/cloudapi/cloudspaces/create":
+			created = append(created, params["privatenetwork"].(string))
+			return 10 + len(created), true
+		case "/cloudapi/ipsec/addTunnelToCloudspace":
+			return "psk", true
+		}
+		return "unexpected call to " + endpoint, false
+	})
+	defer stop()
+	client.CloudSpaces = &CloudSpaceServiceOp{client: client}
+	client.Ipsec = &IpsecServiceOp{client: client}
+
+	ipam, err := NewIPAM(client, "10.100.0.0/16", 7)
+	assert.NoError(t, err)
+
+	network, err := ipam.Allocate(24)
+	assert.NoError(t, err)
+	assert.Equal(t, "10.100.1.0/24", network, "cloudspaces of other accounts should be ignored")
+
+	id, err := ipam.CreateCloudSpace(&CloudSpaceConfig{AccountID: 7, Name: "cs", Location: "be-g8-1"}, 24)
+	assert.NoError(t, err)
+	assert.Equal(t, 11, id)
+	assert.Equal(t, []string{"10.100.3.0/24"}, created)
+
+	_, err = ipam.CreateCloudSpace(&CloudSpaceConfig{AccountID: 7, Name: "cs", Location: "be-g8-1", PrivateNetwork: "10.100.5.0/24"}, 0)
+	if assert.IsType(t, &NetworkOverlapError{}, err) {
+		assert.Equal(t, "10.100.4.0/23", err.(*NetworkOverlapError).Existing)
+	}
+
+	ipam.Release(network)
+	network, err = ipam.Allocate(22)
+	assert.NoError(t, err)
+	assert.Equal(t, "10.100.8.0/22", network)
+
+	// the private network of cloudspace 11 at the other end is fine
+	_, err = ipam.CreateTunnel(&IpsecConfig{CloudspaceID: 2, RemotePublicAddr: "9.9.9.9", RemotePrivateNetwork: "10.100.3.0/24"})
+	assert.NoError(t, err)
+	_, err = ipam.CreateTunnel(&IpsecConfig{CloudspaceID: 1, RemotePublicAddr: "9.9.9.9", RemotePrivateNetwork: "10.100.2.0/25"})
+	assert.Error(t, err, "a network partially overlapping another one should be rejected")
+	_, err = ipam.CreateTunnel(&IpsecConfig{CloudspaceID: 2, RemotePublicAddr: "8.8.8.8", RemotePrivateNetwork: "10.100.3.0/24"})
+	assert.Error(t, err, "a second tunnel of the cloudspace to the same network should be rejected")
+
+	_, err = NewIPAM(client, "fd00::/64")
+	assert.Error(t, err)
+}
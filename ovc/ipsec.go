@@ -4,20 +4,85 @@ import (
 	"encoding/json"
 )
 
+// IpsecProposal is an IKE or ESP cipher suite of a tunnel in strongSwan
+// notation: encryption-integrity-DH group
+type IpsecProposal string
+
+// IPsec proposals supported by the G8
+const (
+	IpsecProposalAES128SHA1MODP2048   IpsecProposal = "aes128-sha1-modp2048"
+	IpsecProposalAES128SHA256MODP2048 IpsecProposal = "aes128-sha256-modp2048"
+	IpsecProposalAES256SHA256MODP2048 IpsecProposal = "aes256-sha256-modp2048"
+	IpsecProposalAES256SHA384MODP4096 IpsecProposal = "aes256-sha384-modp4096"
+)
+
+// IpsecProposals lists the proposals accepted in an IpsecConfig
+var IpsecProposals = []IpsecProposal{
+	IpsecProposalAES128SHA1MODP2048,
+	IpsecProposalAES128SHA256MODP2048,
+	IpsecProposalAES256SHA256MODP2048,
+	IpsecProposalAES256SHA384MODP4096,
+}
+
 // IpsecConfig is used when creating/deleting/listing ipsec
 type IpsecConfig struct {
 	CloudspaceID         int    `json:"cloudspaceId"`
 	RemotePublicAddr     string `json:"remotePublicAddr,omitempty"`
 	RemotePrivateNetwork string `json:"remotePrivateNetwork,omitempty"`
 	PskSecret            string `json:"pskSecret,omitempty"`
+	// IKE and ESP select the cipher suites of the tunnel, the defaults of
+	// the G8 are used when empty
+	IKE IpsecProposal `json:"ike,omitempty"`
+	ESP IpsecProposal `json:"esp,omitempty"`
 }
 
+// IPsec tunnel statuses as reported by the G8
+const (
+	IpsecStatusEstablished = "ESTABLISHED"
+	IpsecStatusConnecting  = "CONNECTING"
+	IpsecStatusDown        = "DOWN"
+)
+
 // IpsecInfo is a list of ipsec of a cloudspace
 // Returned when using the List method
 type IpsecInfo struct {
-	RemoteAddr           string `json:"remoteAddr"`
-	RemotePrivateNetwork string `json:"remoteprivatenetwork"`
-	PSK                  string `json:"psk"`
+	RemoteAddr           string        `json:"remoteAddr"`
+	RemotePrivateNetwork string        `json:"remoteprivatenetwork"`
+	PSK                  string        `json:"psk"`
+	IKE                  IpsecProposal `json:"ike,omitempty"`
+	ESP                  IpsecProposal `json:"esp,omitempty"`
+	// Status is one of the IpsecStatus constants, empty when the G8 doesn't
+	// report it
+	Status string `json:"status,omitempty"`
+}
+
+// ID identifies a tunnel within its cloudspace, the G8 has no identifier for
+// tunnels but allows a single tunnel per remote address and network
+func (i *IpsecInfo) ID() string {
+	return i.RemoteAddr + "/" + i.RemotePrivateNetwork
+}
+
+// Validate checks an IpsecConfig used to create a tunnel
+func (c *IpsecConfig) Validate() error {
+	v := &validator{}
+	v.requiredID("cloudspaceId", c.CloudspaceID)
+	v.ip("remotePublicAddr", c.RemotePublicAddr)
+	v.cidr("remotePrivateNetwork", c.RemotePrivateNetwork)
+	if c.IKE != "" {
+		v.oneOf("ike", string(c.IKE), ipsecProposalNames()...)
+	}
+	if c.ESP != "" {
+		v.oneOf("esp", string(c.ESP), ipsecProposalNames()...)
+	}
+	return v.err()
+}
+
+func ipsecProposalNames() []string {
+	names := make([]string, len(IpsecProposals))
+	for i, proposal := range IpsecProposals {
+		names[i] = string(proposal)
+	}
+	return names
 }
 
 // IpsecService is an interface for interfacing with ipsec
@@ -26,6 +91,10 @@ type IpsecService interface {
 	Create(*IpsecConfig) (string, error)
 	List(*IpsecConfig) (*[]IpsecInfo, error)
 	Delete(*IpsecConfig) error
+	Get(*IpsecConfig) (*IpsecInfo, error)
+	Update(*IpsecUpdateConfig) (string, error)
+	Status(*IpsecConfig) (*IpsecStatus, error)
+	EnsureTunnels(int, []IpsecConfig) ([]IpsecInfo, error)
 }
 
 // IpsecServiceOp handles communication with the ipsec related methods of the
@@ -36,6 +105,9 @@ type IpsecServiceOp struct {
 
 // Create a new ipsec tunnel
 func (s *IpsecServiceOp) Create(ipsecConfig *IpsecConfig) (string, error) {
+	if err := ipsecConfig.Validate(); err != nil {
+		return "", err
+	}
 	body, err := s.client.Post("/cloudapi/ipsec/addTunnelToCloudspace", *ipsecConfig, OperationalActionTimeout)
 	if err != nil {
 		return "", err
@@ -63,7 +135,7 @@ func (s *IpsecServiceOp) List(ipsecConfig *IpsecConfig) (*[]IpsecInfo, error) {
 		return nil, err
 	}
 	ipsecList := new([]IpsecInfo)
-	err = json.Unmarshal(body, &ipsecList)
+	err = json.Unmarshal(body, ipsecList)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,229 @@
+package ovc
+
+import (
+	"fmt"
+	"strings"
+)
+
+// IpsecUpdateConfig is used when updating an ipsec tunnel
+type IpsecUpdateConfig struct {
+	CloudspaceID int
+	// RemotePublicAddr and RemotePrivateNetwork identify the tunnel, the
+	// network may be empty when there is a single tunnel to the address
+	RemotePublicAddr     string
+	RemotePrivateNetwork string
+	// NewRemotePrivateNetwork, PskSecret, IKE and ESP keep their current
+	// value when empty
+	NewRemotePrivateNetwork string
+	PskSecret               string
+	IKE                     IpsecProposal
+	ESP                     IpsecProposal
+}
+
+// IpsecStatus is the health of an ipsec tunnel
+type IpsecStatus struct {
+	Tunnel IpsecInfo
+	// CloudSpaceStatus is the status of the cloudspace, tunnels are only up
+	// when its virtual firewall is deployed
+	CloudSpaceStatus string
+	// Up is true when the virtual firewall is deployed and the G8 reports
+	// the tunnel as established
+	Up bool
+	// Known is false when the virtual firewall is deployed but the G8 doesn't
+	// report the status of the tunnel, Up is false then even if the tunnel
+	// is established
+	Known bool
+}
+
+// Get an ipsec tunnel by remote address and, when set, remote network
+func (s *IpsecServiceOp) Get(ipsecConfig *IpsecConfig) (*IpsecInfo, error) {
+	ipsecList, err := s.List(&IpsecConfig{CloudspaceID: ipsecConfig.CloudspaceID})
+	if err != nil {
+		return nil, err
+	}
+	for _, tunnel := range *ipsecList {
+		if tunnel.RemoteAddr != ipsecConfig.RemotePublicAddr {
+			continue
+		}
+		if ipsecConfig.RemotePrivateNetwork == "" || tunnel.RemotePrivateNetwork == ipsecConfig.RemotePrivateNetwork {
+			tunnel := tunnel
+			return &tunnel, nil
+		}
+	}
+
+	return nil, fmt.Errorf("Could not find an IPsec tunnel to %s %s", ipsecConfig.RemotePublicAddr, ipsecConfig.RemotePrivateNetwork)
+}
+
+// Update changes the pre-shared key, remote network or proposals of an ipsec
+// tunnel and returns its pre-shared key. The G8 can't update tunnels, so the
+// tunnel is removed and added again, the old tunnel is restored when adding
+// fails.
+func (s *IpsecServiceOp) Update(updateConfig *IpsecUpdateConfig) (string, error) {
+	existing, err := s.Get(&IpsecConfig{
+		CloudspaceID:         updateConfig.CloudspaceID,
+		RemotePublicAddr:     updateConfig.RemotePublicAddr,
+		RemotePrivateNetwork: updateConfig.RemotePrivateNetwork,
+	})
+	if err != nil {
+		return "", err
+	}
+	old := existing.config(updateConfig.CloudspaceID)
+	config := old
+	if updateConfig.NewRemotePrivateNetwork != "" {
+		config.RemotePrivateNetwork = updateConfig.NewRemotePrivateNetwork
+	}
+	if updateConfig.PskSecret != "" {
+		config.PskSecret = updateConfig.PskSecret
+	}
+	if updateConfig.IKE != "" {
+		config.IKE = updateConfig.IKE
+	}
+	if updateConfig.ESP != "" {
+		config.ESP = updateConfig.ESP
+	}
+	if config == old {
+		return existing.PSK, nil
+	}
+	if err := config.Validate(); err != nil {
+		return "", err
+	}
+
+	return s.replace(&old, &config)
+}
+
+// replace removes a tunnel and adds its replacement, restoring the old tunnel
+// when adding fails
+func (s *IpsecServiceOp) replace(old *IpsecConfig, config *IpsecConfig) (string, error) {
+	if err := s.Delete(&IpsecConfig{
+		CloudspaceID:         old.CloudspaceID,
+		RemotePublicAddr:     old.RemotePublicAddr,
+		RemotePrivateNetwork: old.RemotePrivateNetwork,
+	}); err != nil {
+		return "", err
+	}
+	psk, err := s.Create(config)
+	if err != nil {
+		if _, restoreErr := s.Create(old); restoreErr != nil {
+			return "", fmt.Errorf("%v, restoring the old tunnel failed: %v", err, restoreErr)
+		}
+		return "", err
+	}
+	return psk, nil
+}
+
+// config returns the configuration creating the tunnel
+func (i *IpsecInfo) config(cloudSpaceID int) IpsecConfig {
+	return IpsecConfig{
+		CloudspaceID:         cloudSpaceID,
+		RemotePublicAddr:     i.RemoteAddr,
+		RemotePrivateNetwork: i.RemotePrivateNetwork,
+		PskSecret:            i.PSK,
+		IKE:                  i.IKE,
+		ESP:                  i.ESP,
+	}
+}
+
+// Status returns the health of an ipsec tunnel
+func (s *IpsecServiceOp) Status(ipsecConfig *IpsecConfig) (*IpsecStatus, error) {
+	tunnel, err := s.Get(ipsecConfig)
+	if err != nil {
+		return nil, err
+	}
+	cloudSpace, err := s.client.CloudSpaces.Get(ipsecConfig.CloudspaceID)
+	if err != nil {
+		return nil, err
+	}
+
+	deployed := cloudSpace.Status == CloudSpaceStatusDeployed
+	return &IpsecStatus{
+		Tunnel:           *tunnel,
+		CloudSpaceStatus: cloudSpace.Status,
+		Up:               deployed && strings.EqualFold(tunnel.Status, IpsecStatusEstablished),
+		Known:            !deployed || tunnel.Status != "",
+	}, nil
+}
+
+// satisfies reports whether the tunnel matches a desired configuration, empty
+// pre-shared keys and proposals match any
+func (i *IpsecInfo) satisfies(desired *IpsecConfig) bool {
+	return (desired.PskSecret == "" || desired.PskSecret == i.PSK) &&
+		(desired.IKE == "" || desired.IKE == i.IKE) &&
+		(desired.ESP == "" || desired.ESP == i.ESP)
+}
+
+// EnsureTunnels adds, updates and removes the ipsec tunnels of a cloudspace
+// so it ends up with exactly the desired tunnels, and returns its tunnels.
+// Desired tunnels without a pre-shared key keep the key of an existing tunnel.
+func (s *IpsecServiceOp) EnsureTunnels(cloudSpaceID int, desired []IpsecConfig) ([]IpsecInfo, error) {
+	// the configurations are completed with the cloudspace on a copy to leave
+	// the slice of the caller untouched
+	desired = append([]IpsecConfig(nil), desired...)
+	for i := range desired {
+		desired[i].CloudspaceID = cloudSpaceID
+		if err := desired[i].Validate(); err != nil {
+			return nil, err
+		}
+	}
+	actual, err := s.List(&IpsecConfig{CloudspaceID: cloudSpaceID})
+	if err != nil {
+		return nil, err
+	}
+
+	existing := make(map[string]IpsecInfo, len(*actual))
+	for _, tunnel := range *actual {
+		existing[tunnel.ID()] = tunnel
+	}
+	wanted := make(map[string]bool, len(desired))
+	var updates []*IpsecConfig
+	var creates []*IpsecConfig
+	for i := range desired {
+		config := &desired[i]
+		id := config.RemotePublicAddr + "/" + config.RemotePrivateNetwork
+		wanted[id] = true
+		tunnel, ok := existing[id]
+		switch {
+		case !ok:
+			creates = append(creates, config)
+		case !tunnel.satisfies(config):
+			updates = append(updates, config)
+		}
+	}
+
+	for _, tunnel := range *actual {
+		if wanted[tunnel.ID()] {
+			continue
+		}
+		err := s.Delete(&IpsecConfig{
+			CloudspaceID:         cloudSpaceID,
+			RemotePublicAddr:     tunnel.RemoteAddr,
+			RemotePrivateNetwork: tunnel.RemotePrivateNetwork,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, config := range updates {
+		_, err := s.Update(&IpsecUpdateConfig{
+			CloudspaceID:         cloudSpaceID,
+			RemotePublicAddr:     config.RemotePublicAddr,
+			RemotePrivateNetwork: config.RemotePrivateNetwork,
+			PskSecret:            config.PskSecret,
+			IKE:                  config.IKE,
+			ESP:                  config.ESP,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, config := range creates {
+		if _, err := s.Create(config); err != nil {
+			return nil, err
+		}
+	}
+
+	tunnels, err := s.List(&IpsecConfig{CloudspaceID: cloudSpaceID})
+	if err != nil {
+		return nil, err
+	}
+	return *tunnels, nil
+}
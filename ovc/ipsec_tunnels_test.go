@@ -0,0 +1,80 @@
+package ovc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIpsecTunnels(t *testing.T) {
+	tunnels := []map[string]interface{}{
+		{"remoteAddr": "1.2.3.4", "remoteprivatenetwork": "10.0.1.0/24", "psk": "secret", "status": "ESTABLISHED"},
+		{"remoteAddr": "5.6.7.8", "remoteprivatenetwork": "10.0.2.0/24", "psk": "other"},
+		{"remoteAddr": "9.9.9.9", "remoteprivatenetwork": "10.0.3.0/24", "psk": "gone"},
+	}
+	calls := []string{}
+	failAdd := false
+	client, stop := newTestClient(t, func(endpoint string, params map[string]interface{}) (interface{}, bool) {
+		switch endpoint {
+		case "/cloudapi/ipsec/listTunnels":
+			return tunnels, true
+		case "/cloudapi/cloudspaces/get":
+			return map[string]interface{}{"id": 3, "status": CloudSpaceStatusDeployed}, true
+		case "/cloudapi/ipsec/addTunnelToCloudspace":
+			calls = append(calls, "add "+params["remotePublicAddr"].(string)+" "+params["remotePrivateNetwork"].(string))
+			if failAdd && params["remotePrivateNetwork"] != "10.0.1.0/24" {
+				return "invalid network", false
+			}
+			return "psk", true
+		case "/cloudapi/ipsec/removeTunnelFromCloudspace":
+			calls = append(calls, "remove "+params["remotePublicAddr"].(string)+" "+params["remotePrivateNetwork"].(string))
+			return true, true
+		}
+		return "unexpected call to " + endpoint, false
+	})
+	defer stop()
+	client.CloudSpaces = &CloudSpaceServiceOp{client: client}
+	client.Ipsec = &IpsecServiceOp{client: client}
+
+	status, err := client.Ipsec.Status(&IpsecConfig{CloudspaceID: 3, RemotePublicAddr: "1.2.3.4"})
+	assert.NoError(t, err)
+	assert.True(t, status.Up)
+	assert.True(t, status.Known)
+	assert.Equal(t, "1.2.3.4/10.0.1.0/24", status.Tunnel.ID())
+	status, err = client.Ipsec.Status(&IpsecConfig{CloudspaceID: 3, RemotePublicAddr: "5.6.7.8"})
+	assert.NoError(t, err)
+	assert.False(t, status.Up)
+	assert.False(t, status.Known, "a tunnel without status should not be reported as down")
+
+	psk, err := client.Ipsec.Update(&IpsecUpdateConfig{CloudspaceID: 3, RemotePublicAddr: "1.2.3.4", PskSecret: "secret"})
+	assert.NoError(t, err)
+	assert.Equal(t, "secret", psk, "an unchanged tunnel should not be replaced")
+	assert.Empty(t, calls)
+
+	failAdd = true
+	_, err = client.Ipsec.Update(&IpsecUpdateConfig{CloudspaceID: 3, RemotePublicAddr: "1.2.3.4", NewRemotePrivateNetwork: "10.0.9.0/24"})
+	assert.Error(t, err)
+	assert.Equal(t, []string{"remove 1.2.3.4 10.0.1.0/24", "add 1.2.3.4 10.0.9.0/24", "add 1.2.3.4 10.0.1.0/24"}, calls,
+		"the old tunnel should be restored")
+
+	failAdd = false
+	calls = calls[:0]
+	desired := []IpsecConfig{
+		{RemotePublicAddr: "1.2.3.4", RemotePrivateNetwork: "10.0.1.0/24"},
+		{RemotePublicAddr: "5.6.7.8", RemotePrivateNetwork: "10.0.2.0/24", PskSecret: "rotated"},
+		{RemotePublicAddr: "4.4.4.4", RemotePrivateNetwork: "10.0.4.0/24", IKE: IpsecProposalAES256SHA256MODP2048},
+	}
+	_, err = client.Ipsec.EnsureTunnels(3, desired)
+	assert.NoError(t, err)
+	for _, config := range desired {
+		assert.Zero(t, config.CloudspaceID, "the desired tunnels of the caller should not be modified")
+	}
+	assert.Equal(t, []string{
+		"remove 9.9.9.9 10.0.3.0/24",
+		"remove 5.6.7.8 10.0.2.0/24", "add 5.6.7.8 10.0.2.0/24",
+		"add 4.4.4.4 10.0.4.0/24",
+	}, calls)
+
+	_, err = client.Ipsec.EnsureTunnels(3, []IpsecConfig{{RemotePublicAddr: "4.4.4.4", RemotePrivateNetwork: "10.0.4.0/24", ESP: "des"}})
+	assert.Error(t, err)
+}
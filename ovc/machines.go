@@ -118,6 +118,60 @@ type MachineInfo struct {
 	Description  *string       `json:"description"`
 }
 
+// Machine statuses as reported by the G8
+const (
+	MachineStatusRunning = "RUNNING"
+	MachineStatusHalted  = "HALTED"
+	MachineStatusPaused  = "PAUSED"
+)
+
+// ImageTypes are the image types accepted by EmptyMachineConfig
+var ImageTypes = []string{"Windows", "Unix", "Linux", "BSD", "Darwin", "Other"}
+
+// Validate checks a MachineConfig used to create a machine
+func (c *MachineConfig) Validate() error {
+	v := &validator{}
+	v.requiredID("cloudspaceId", c.CloudspaceID)
+	v.required("name", c.Name)
+	v.requiredID("imageId", c.ImageID)
+	if c.SizeID == 0 {
+		v.positive("memory", c.Memory)
+		v.positive("vcpus", c.Vcpus)
+	}
+	if c.Disksize < 0 {
+		v.add("disksize", "must not be negative, got %d", c.Disksize)
+	}
+	for i, dataDisk := range c.DataDisks {
+		field := fmt.Sprintf("datadisks[%d]", i)
+		switch size := dataDisk.(type) {
+		case int:
+			v.positive(field, size)
+		case float64:
+			v.positive(field, int(size))
+		default:
+			v.add(field, "must be a size in GB, got %v", dataDisk)
+		}
+	}
+	return v.err()
+}
+
+// Validate checks an EmptyMachineConfig
+func (c *EmptyMachineConfig) Validate() error {
+	v := &validator{}
+	v.requiredID("cloudspaceId", c.CloudspaceID)
+	v.required("name", c.Name)
+	v.positive("memory", c.Memory)
+	v.positive("vcpus", c.Vcpus)
+	v.positive("disksize", c.Disksize)
+	if c.Imagetype != "" {
+		v.oneOf("imagetype", c.Imagetype, ImageTypes...)
+	}
+	for i, size := range c.DataDisks {
+		v.positive(fmt.Sprintf("datadisks[%d]", i), size)
+	}
+	return v.err()
+}
+
 // MachineService is an interface for interfacing with the Machine
 // endpoints of the OVC API
 type MachineService interface {
@@ -136,6 +190,25 @@ type MachineService interface {
 	DeleteExternalIP(int, int, string) error
 	Stop(int, bool) error
 	Start(int, int) error
+	Bulk(*MachineSelector, BulkMachineAction, int) (*BulkMachineReport, error)
+	Snapshot(int, string) (*Snapshot, error)
+	ListSnapshots(int) (*[]Snapshot, error)
+	RollbackSnapshot(int, int) error
+	DeleteSnapshot(int, int) error
+	Clone(*MachineCloneConfig) (int, error)
+	MoveToCloudspace(int, int) (int, error)
+	Reboot(int) error
+	Reset(int) error
+	Pause(int) error
+	Resume(int) error
+	EnsureState(int, string) error
+	GetConsoleURL(int) (string, error)
+	Console(int) (*Console, error)
+	AddUser(int, string, ACLRight) error
+	UpdateUser(int, string, ACLRight) error
+	DeleteUser(int, string) error
+	EffectiveRights(int, string) (ACLRight, error)
+	History(int, *HistoryFilter) ([]HistoryEvent, error)
 }
 
 // MachineServiceOp handles communication with the machine related methods of the
@@ -220,6 +293,14 @@ func (s *MachineServiceOp) GetByReferenceID(referenceID string) (*MachineInfo, e
 
 // Create a new machine
 func (s *MachineServiceOp) Create(machineConfig *MachineConfig) (int, error) {
+	if err := machineConfig.Validate(); err != nil {
+		return 0, err
+	}
+	if s.client.quotaChecks {
+		if err := s.client.Quotas.CheckMachineCreate(machineConfig); err != nil {
+			return 0, err
+		}
+	}
 	body, err := s.client.Post("/cloudapi/machines/create", *machineConfig, OperationalActionTimeout)
 	if err != nil {
 		return 0, err
@@ -229,6 +310,9 @@ func (s *MachineServiceOp) Create(machineConfig *MachineConfig) (int, error) {
 
 // CreateEmpty a new "empty" machine (= not based on an existing image)
 func (s *MachineServiceOp) CreateEmpty(emptyMachineConfig *EmptyMachineConfig) (int, error) {
+	if err := emptyMachineConfig.Validate(); err != nil {
+		return 0, err
+	}
 	body, err := s.client.Post("/cloudapi/machines/createEmptyMachine", *emptyMachineConfig, ModelActionTimeout)
 	if err != nil {
 		return 0, err
@@ -248,6 +332,11 @@ func (s *MachineServiceOp) Update(machineConfig *MachineConfig) (string, error)
 
 // Resize an existing machine
 func (s *MachineServiceOp) Resize(machineConfig *MachineConfig) (string, error) {
+	if s.client.quotaChecks {
+		if err := s.client.Quotas.CheckMachineResize(machineConfig); err != nil {
+			return "", err
+		}
+	}
 	body, err := s.client.Post("/cloudapi/machines/resize", *machineConfig, OperationalActionTimeout)
 	if err != nil {
 		return "", err
@@ -270,7 +359,7 @@ func (s *MachineServiceOp) Delete(id int, permanently bool) error {
 func (s *MachineServiceOp) Stop(id int, force bool) error {
 	machineMap := make(map[string]interface{})
 	machineMap["machineId"] = id
-	machineMap["stop"] = force
+	machineMap["force"] = force
 
 	_, err := s.client.Post("/cloudapi/machines/stop", machineMap, OperationalActionTimeout)
 	return err
@@ -298,14 +387,9 @@ func (s *MachineServiceOp) CreateImage(id int, imageName string) error {
 	return err
 }
 
-// Shutdown shuts a machine down
+// Shutdown shuts a machine down gracefully
 func (s *MachineServiceOp) Shutdown(id int) error {
-	machineMap := make(map[string]interface{})
-	machineMap["machineId"] = id
-	machineMap["force"] = false
-
-	_, err := s.client.Post("/cloudapi/machines/stop", machineMap, OperationalActionTimeout)
-	return err
+	return s.Stop(id, false)
 }
 
 // AddExternalIP adds external IP
@@ -0,0 +1,206 @@
+package ovc
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// MachineSelector selects the machines a bulk operation acts on.
+// When CloudspaceID is set all machines of that cloudspace are considered,
+// otherwise IDs lists the machines explicitly. Both selections can be narrowed
+// down with IDs and a NamePattern in the syntax of path.Match (e.g. "web-*").
+type MachineSelector struct {
+	CloudspaceID int
+	IDs          []int
+	NamePattern  string
+}
+
+// BulkMachineAction is executed for every machine selected by a bulk operation
+type BulkMachineAction func(MachineService, int) error
+
+var (
+	// BulkStart starts every selected machine
+	BulkStart BulkMachineAction = func(s MachineService, id int) error {
+		return s.Start(id, 0)
+	}
+	// BulkStop forcefully stops every selected machine
+	BulkStop BulkMachineAction = func(s MachineService, id int) error {
+		return s.Stop(id, true)
+	}
+	// BulkShutdown gracefully shuts every selected machine down
+	BulkShutdown BulkMachineAction = func(s MachineService, id int) error {
+		return s.Shutdown(id)
+	}
+)
+
+// BulkDelete returns an action deleting every selected machine
+func BulkDelete(permanently bool) BulkMachineAction {
+	return func(s MachineService, id int) error {
+		return s.Delete(id, permanently)
+	}
+}
+
+// BulkResize returns an action resizing every selected machine to the given size
+func BulkResize(sizeID int, vcpus int, memory int) BulkMachineAction {
+	return func(s MachineService, id int) error {
+		_, err := s.Resize(&MachineConfig{
+			MachineID: strconv.Itoa(id),
+			SizeID:    sizeID,
+			Vcpus:     vcpus,
+			Memory:    memory,
+		})
+		return err
+	}
+}
+
+// BulkMachineResult contains the outcome of a bulk action on a single machine
+type BulkMachineResult struct {
+	MachineID int
+	Name      string
+	Err       error
+}
+
+// BulkMachineReport contains the outcome of a bulk action on all selected
+// machines, ordered by machine ID
+type BulkMachineReport struct {
+	Results []BulkMachineResult
+}
+
+// Failed returns the results of the machines the action failed for
+func (r *BulkMachineReport) Failed() []BulkMachineResult {
+	failed := []BulkMachineResult{}
+	for _, result := range r.Results {
+		if result.Err != nil {
+			failed = append(failed, result)
+		}
+	}
+	return failed
+}
+
+// Err returns a BulkMachineError aggregating all failures, or nil if the
+// action succeeded for every machine
+func (r *BulkMachineReport) Err() error {
+	failed := r.Failed()
+	if len(failed) == 0 {
+		return nil
+	}
+	return &BulkMachineError{Total: len(r.Results), Failed: failed}
+}
+
+// BulkMachineError aggregates the errors of a bulk machine operation
+type BulkMachineError struct {
+	Total  int
+	Failed []BulkMachineResult
+}
+
+func (e *BulkMachineError) Error() string {
+	msgs := make([]string, 0, len(e.Failed))
+	for _, result := range e.Failed {
+		msgs = append(msgs, fmt.Sprintf("machine %d (%s): %s", result.MachineID, result.Name, result.Err))
+	}
+	return fmt.Sprintf("bulk operation failed for %d of %d machines: %s", len(e.Failed), e.Total, strings.Join(msgs, "; "))
+}
+
+// Bulk executes action on every machine matching selector, running at most
+// parallelism actions at the same time. A parallelism of 0 or less defaults to
+// the number of concurrent requests the client allows.
+// The returned error is either a selection error, in which case no action was
+// executed, or a BulkMachineError listing the machines the action failed for.
+func (s *MachineServiceOp) Bulk(selector *MachineSelector, action BulkMachineAction, parallelism int) (*BulkMachineReport, error) {
+	machines, err := s.selectMachines(selector)
+	if err != nil {
+		return nil, err
+	}
+	if parallelism <= 0 {
+		parallelism = s.client.requestLimit
+	}
+	if parallelism <= 0 {
+		parallelism = 1
+	}
+
+	report := &BulkMachineReport{Results: make([]BulkMachineResult, len(machines))}
+	work := make(chan int)
+	wg := sync.WaitGroup{}
+	for w := 0; w < parallelism && w < len(machines); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range work {
+				s.client.logger.Debugf("Running bulk action on machine %d", machines[i].ID)
+				report.Results[i] = BulkMachineResult{
+					MachineID: machines[i].ID,
+					Name:      machines[i].Name,
+					Err:       action(s.client.Machines, machines[i].ID),
+				}
+			}
+		}()
+	}
+	for i := range machines {
+		work <- i
+	}
+	close(work)
+	wg.Wait()
+
+	return report, report.Err()
+}
+
+// selectMachines returns the machines matching selector, ordered by ID
+func (s *MachineServiceOp) selectMachines(selector *MachineSelector) ([]Machine, error) {
+	if selector == nil {
+		return nil, fmt.Errorf("Machine selector is missing")
+	}
+	if selector.NamePattern != "" {
+		if _, err := path.Match(selector.NamePattern, ""); err != nil {
+			return nil, fmt.Errorf("Invalid machine name pattern %q: %s", selector.NamePattern, err)
+		}
+	}
+
+	wantedIDs := make(map[int]bool, len(selector.IDs))
+	for _, id := range selector.IDs {
+		wantedIDs[id] = true
+	}
+
+	candidates := []Machine{}
+	switch {
+	case selector.CloudspaceID != 0:
+		machines, err := s.client.Machines.List(selector.CloudspaceID)
+		if err != nil {
+			return nil, err
+		}
+		candidates = *machines
+	case len(selector.IDs) != 0:
+		for id := range wantedIDs {
+			machine := Machine{ID: id}
+			if selector.NamePattern != "" {
+				machineInfo, err := s.client.Machines.Get(id)
+				if err != nil {
+					return nil, err
+				}
+				machine.Name = machineInfo.Name
+			}
+			candidates = append(candidates, machine)
+		}
+	default:
+		return nil, fmt.Errorf("Machine selector needs a cloudspace ID or machine IDs")
+	}
+
+	selected := []Machine{}
+	for _, machine := range candidates {
+		if len(wantedIDs) != 0 && !wantedIDs[machine.ID] {
+			continue
+		}
+		if selector.NamePattern != "" {
+			if match, _ := path.Match(selector.NamePattern, machine.Name); !match {
+				continue
+			}
+		}
+		selected = append(selected, machine)
+	}
+	sort.Slice(selected, func(i, j int) bool { return selected[i].ID < selected[j].ID })
+
+	return selected, nil
+}
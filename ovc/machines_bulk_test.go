@@ -0,0 +1,47 @@
+package ovc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBulk(t *testing.T) {
+	client, done := newTestClient(t, func(endpoint string, params map[string]interface{}) (interface{}, bool) {
+		switch endpoint {
+		case "/cloudapi/machines/list":
+			return []Machine{
+				{ID: 3, Name: "web-2"},
+				{ID: 1, Name: "web-1"},
+				{ID: 2, Name: "db-1"},
+			}, true
+		case "/cloudapi/machines/stop":
+			if params["machineId"].(float64) == 3 {
+				return "machine is locked", false
+			}
+			return true, true
+		}
+		return nil, false
+	})
+	defer done()
+
+	report, err := client.Machines.Bulk(&MachineSelector{CloudspaceID: 1, NamePattern: "web-*"}, BulkStop, 0)
+	assert.Error(t, err)
+	if assert.NotNil(t, report) {
+		assert.Len(t, report.Results, 2)
+		assert.Equal(t, 1, report.Results[0].MachineID)
+		assert.NoError(t, report.Results[0].Err)
+		assert.Equal(t, 3, report.Results[1].MachineID)
+		assert.Error(t, report.Results[1].Err)
+	}
+	bulkErr, ok := err.(*BulkMachineError)
+	if assert.True(t, ok) {
+		assert.Equal(t, 2, bulkErr.Total)
+		assert.Len(t, bulkErr.Failed, 1)
+	}
+
+	_, err = client.Machines.Bulk(&MachineSelector{}, BulkStart, 0)
+	assert.Error(t, err)
+	_, err = client.Machines.Bulk(nil, BulkStart, 0)
+	assert.Error(t, err)
+}
@@ -0,0 +1,123 @@
+package ovc
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// MachineCloneConfig is used when cloning a machine
+type MachineCloneConfig struct {
+	MachineID int    `json:"machineId"`
+	Name      string `json:"name"`
+	// CloudspaceID is the cloudspace to create the clone in, defaults to the
+	// cloudspace of the machine
+	CloudspaceID int `json:"cloudspaceId,omitempty"`
+	// SnapshotTimestamp clones the machine as it was at the snapshot with this
+	// epoch, the machine can keep running. Without it the machine needs to be
+	// halted.
+	SnapshotTimestamp int `json:"snapshotTimestamp,omitempty"`
+}
+
+// Validate checks a MachineCloneConfig
+func (c *MachineCloneConfig) Validate() error {
+	v := &validator{}
+	v.requiredID("machineId", c.MachineID)
+	v.required("name", c.Name)
+	if c.CloudspaceID < 0 {
+		v.add("cloudspaceId", "must not be negative, got %d", c.CloudspaceID)
+	}
+	return v.err()
+}
+
+// Clone creates a copy of a machine and all of its attached disks and returns
+// the ID of the new machine
+func (s *MachineServiceOp) Clone(cloneConfig *MachineCloneConfig) (int, error) {
+	if err := cloneConfig.Validate(); err != nil {
+		return 0, err
+	}
+	body, err := s.client.Post("/cloudapi/machines/clone", *cloneConfig, DataActionTimeout)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(string(body))
+}
+
+// MoveToCloudspace moves a machine to another cloudspace of the same account
+// and returns the ID of the machine in its new cloudspace. The machine is
+// stopped, its data disks are detached, the boot disk is cloned into the
+// target cloudspace, the data disks are attached to the clone and the original
+// machine is deleted. The moved machine is left halted.
+func (s *MachineServiceOp) MoveToCloudspace(id int, cloudspaceID int) (int, error) {
+	machine, err := s.client.Machines.Get(id)
+	if err != nil {
+		return 0, err
+	}
+	if machine.CloudspaceID == cloudspaceID {
+		return id, nil
+	}
+	source, err := s.client.CloudSpaces.Get(machine.CloudspaceID)
+	if err != nil {
+		return 0, err
+	}
+	target, err := s.client.CloudSpaces.Get(cloudspaceID)
+	if err != nil {
+		return 0, err
+	}
+	if source.AccountID != target.AccountID {
+		return 0, fmt.Errorf("Cannot move machine %d to cloudspace %d of another account", id, cloudspaceID)
+	}
+
+	if machine.Status != MachineStatusHalted {
+		if err := s.client.Machines.Stop(id, false); err != nil {
+			return 0, err
+		}
+	}
+
+	dataDisks := []int{}
+	for _, disk := range machine.Disks {
+		if disk.Type != "D" {
+			continue
+		}
+		err := s.client.Disks.Detach(&DiskAttachConfig{DiskID: disk.ID, MachineID: id})
+		if err != nil {
+			s.reattachDisks(id, dataDisks)
+			return 0, fmt.Errorf("Could not detach disk %d: %s", disk.ID, err)
+		}
+		dataDisks = append(dataDisks, disk.ID)
+	}
+
+	cloneID, err := s.client.Machines.Clone(&MachineCloneConfig{
+		MachineID:    id,
+		Name:         machine.Name,
+		CloudspaceID: cloudspaceID,
+	})
+	if err != nil {
+		s.reattachDisks(id, dataDisks)
+		return 0, err
+	}
+
+	if err := s.reattachDisks(cloneID, dataDisks); err != nil {
+		return cloneID, fmt.Errorf("Machine %d was cloned to %d but its data disks could not be attached: %s", id, cloneID, err)
+	}
+	if err := s.client.Machines.Delete(id, false); err != nil {
+		return cloneID, fmt.Errorf("Machine %d was moved to %d but could not be deleted: %s", id, cloneID, err)
+	}
+
+	return cloneID, nil
+}
+
+// reattachDisks attaches disks to a machine, continuing past failures
+func (s *MachineServiceOp) reattachDisks(id int, diskIDs []int) error {
+	var failed []int
+	for _, diskID := range diskIDs {
+		err := s.client.Disks.Attach(&DiskAttachConfig{DiskID: diskID, MachineID: id})
+		if err != nil {
+			s.client.logger.Errorf("Could not attach disk %d to machine %d: %s", diskID, id, err)
+			failed = append(failed, diskID)
+		}
+	}
+	if len(failed) != 0 {
+		return fmt.Errorf("Could not attach disks %v to machine %d", failed, id)
+	}
+	return nil
+}
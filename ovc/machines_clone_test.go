@@ -0,0 +1,52 @@
+package ovc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMoveToCloudspace(t *testing.T) {
+	var calls []string
+	client, stop := newTestClient(t, func(endpoint string, params map[string]interface{}) (interface{}, bool) {
+		switch endpoint {
+		case "/cloudapi/machines/get":
+			return map[string]interface{}{
+				"id": 5, "name": "web", "cloudspaceid": 1, "status": MachineStatusRunning,
+				"disks": []map[string]interface{}{{"id": 10, "type": "B"}, {"id": 11, "type": "D"}},
+			}, true
+		case "/cloudapi/cloudspaces/get":
+			return map[string]interface{}{"id": params["cloudspaceId"], "accountId": 7}, true
+		case "/cloudapi/machines/clone":
+			calls = append(calls, endpoint)
+			if params["cloudspaceId"] != float64(2) || params["name"] != "web" {
+				return "unexpected clone parameters", false
+			}
+			return 6, true
+		case "/cloudapi/machines/stop", "/cloudapi/machines/detachDisk", "/cloudapi/machines/attachDisk", "/cloudapi/machines/delete":
+			calls = append(calls, endpoint)
+			if endpoint == "/cloudapi/machines/attachDisk" && params["machineId"] != float64(6) {
+				return "disk attached to the wrong machine", false
+			}
+			return true, true
+		}
+		return "unexpected call to " + endpoint, false
+	})
+	defer stop()
+	client.CloudSpaces = &CloudSpaceServiceOp{client: client}
+	client.Disks = &DiskServiceOp{client: client}
+
+	id, err := client.Machines.MoveToCloudspace(5, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, 6, id)
+	assert.Equal(t, []string{
+		"/cloudapi/machines/stop",
+		"/cloudapi/machines/detachDisk",
+		"/cloudapi/machines/clone",
+		"/cloudapi/machines/attachDisk",
+		"/cloudapi/machines/delete",
+	}, calls)
+
+	_, err = client.Machines.Clone(&MachineCloneConfig{MachineID: 5})
+	assert.EqualError(t, err, "Invalid configuration: name: is required")
+}
@@ -0,0 +1,77 @@
+package ovc
+
+import (
+	"fmt"
+)
+
+// Reboot reboots a machine gracefully
+func (s *MachineServiceOp) Reboot(id int) error {
+	return s.powerAction("reboot", id)
+}
+
+// Reset hard resets a machine
+func (s *MachineServiceOp) Reset(id int) error {
+	return s.powerAction("reset", id)
+}
+
+// Pause pauses a running machine
+func (s *MachineServiceOp) Pause(id int) error {
+	return s.powerAction("pause", id)
+}
+
+// Resume resumes a paused machine
+func (s *MachineServiceOp) Resume(id int) error {
+	return s.powerAction("resume", id)
+}
+
+func (s *MachineServiceOp) powerAction(action string, id int) error {
+	machineMap := make(map[string]interface{})
+	machineMap["machineId"] = id
+
+	_, err := s.client.Post("/cloudapi/machines/"+action, machineMap, OperationalActionTimeout)
+	return err
+}
+
+// EnsureState brings a machine in the given state, which is one of
+// MachineStatusRunning, MachineStatusHalted or MachineStatusPaused. Nothing is
+// done when the machine already is in that state, a machine in a transitional
+// state is waited for first.
+func (s *MachineServiceOp) EnsureState(id int, state string) error {
+	switch state {
+	case MachineStatusRunning, MachineStatusHalted, MachineStatusPaused:
+	default:
+		return fmt.Errorf("Cannot bring machine %d in state %s", id, state)
+	}
+
+	machine, err := s.waitForStatus(id, OperationalActionTimeout, MachineStatusRunning, MachineStatusHalted, MachineStatusPaused)
+	if err != nil {
+		return err
+	}
+	current := machine.Status
+
+	for current != state {
+		switch {
+		case current == MachineStatusHalted:
+			err = s.client.Machines.Start(id, 0)
+			current = MachineStatusRunning
+		case current == MachineStatusPaused:
+			err = s.client.Machines.Resume(id)
+			current = MachineStatusRunning
+		case state == MachineStatusHalted:
+			err = s.client.Machines.Stop(id, false)
+			current = MachineStatusHalted
+		default:
+			err = s.client.Machines.Pause(id)
+			current = MachineStatusPaused
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if current == machine.Status {
+		return nil
+	}
+
+	_, err = s.waitForStatus(id, OperationalActionTimeout, state)
+	return err
+}
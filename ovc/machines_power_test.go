@@ -0,0 +1,43 @@
+package ovc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnsureState(t *testing.T) {
+	machinePollInterval = time.Millisecond
+	defer func() { machinePollInterval = 2 * time.Second }()
+
+	status := MachineStatusPaused
+	var calls []string
+	client, stop := newTestClient(t, func(endpoint string, params map[string]interface{}) (interface{}, bool) {
+		switch endpoint {
+		case "/cloudapi/machines/get":
+			return map[string]interface{}{"id": 5, "status": status}, true
+		case "/cloudapi/machines/resume", "/cloudapi/machines/start":
+			status = MachineStatusRunning
+		case "/cloudapi/machines/stop":
+			if params["force"] != false {
+				return "expected a graceful stop", false
+			}
+			status = MachineStatusHalted
+		default:
+			return "unexpected call to " + endpoint, false
+		}
+		calls = append(calls, endpoint)
+		return true, true
+	})
+	defer stop()
+
+	assert.NoError(t, client.Machines.EnsureState(5, MachineStatusHalted))
+	assert.Equal(t, []string{"/cloudapi/machines/resume", "/cloudapi/machines/stop"}, calls)
+
+	calls = nil
+	assert.NoError(t, client.Machines.EnsureState(5, MachineStatusHalted))
+	assert.Empty(t, calls, "nothing to do for a machine already in the state")
+
+	assert.Error(t, client.Machines.EnsureState(5, "DELETED"))
+}
@@ -0,0 +1,216 @@
+package ovc
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PortRange is an inclusive range of public ports
+type PortRange struct {
+	Min int
+	Max int
+}
+
+// DefaultPortRange is used for public ports when no ranges are configured
+var DefaultPortRange = PortRange{Min: 2000, Max: 41999}
+
+// PortAllocator hands out free public ports of a public IP of a cloudspace.
+// The port forwards are listed once, allocated ports are reserved in-process
+// so concurrent creates don't pick the same port. Use Client.PortAllocator to
+// share an allocator between all users of a client.
+type PortAllocator struct {
+	client       *Client
+	cloudSpaceID int
+	publicIP     string
+	ranges       []PortRange
+	// Retries is the number of other ports tried when creating a port
+	// forward fails because its port was taken outside of this allocator
+	Retries int
+
+	mu     sync.Mutex
+	loaded bool
+	used   map[string]map[int]bool
+	rand   *rand.Rand
+}
+
+// NewPortAllocator returns an allocator for the public IP of a cloudspace,
+// allocating from ranges or DefaultPortRange when none are given
+func NewPortAllocator(client *Client, cloudSpaceID int, publicIP string, ranges ...PortRange) *PortAllocator {
+	if len(ranges) == 0 {
+		ranges = []PortRange{DefaultPortRange}
+	}
+	return &PortAllocator{
+		client:       client,
+		cloudSpaceID: cloudSpaceID,
+		publicIP:     publicIP,
+		ranges:       ranges,
+		Retries:      3,
+		used:         make(map[string]map[int]bool),
+		rand:         rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// PortAllocator returns the allocator shared by this client for the public IP
+// of a cloudspace, using the PublicPortRanges of the client configuration
+func (c *Client) PortAllocator(cloudSpaceID int, publicIP string) *PortAllocator {
+	c.portAllocatorsMu.Lock()
+	defer c.portAllocatorsMu.Unlock()
+	if c.portAllocators == nil {
+		c.portAllocators = make(map[string]*PortAllocator)
+	}
+	key := strconv.Itoa(cloudSpaceID) + "/" + publicIP
+	allocator, ok := c.portAllocators[key]
+	if !ok {
+		allocator = NewPortAllocator(c, cloudSpaceID, publicIP, c.portRanges...)
+		c.portAllocators[key] = allocator
+	}
+	return allocator
+}
+
+// portAllocatorIfExists returns the shared allocator of a public IP if it
+// already exists
+func (c *Client) portAllocatorIfExists(cloudSpaceID int, publicIP string) *PortAllocator {
+	c.portAllocatorsMu.Lock()
+	defer c.portAllocatorsMu.Unlock()
+	return c.portAllocators[strconv.Itoa(cloudSpaceID)+"/"+publicIP]
+}
+
+// usedPorts returns the used ports of a protocol, the G8 defaults to tcp
+func (a *PortAllocator) usedPorts(protocol string) map[int]bool {
+	protocol = strings.ToLower(protocol)
+	if protocol == "" {
+		protocol = "tcp"
+	}
+	if a.used[protocol] == nil {
+		a.used[protocol] = make(map[int]bool)
+	}
+	return a.used[protocol]
+}
+
+// load lists the port forwards of the public IP, a.mu must be held
+func (a *PortAllocator) load() error {
+	list, err := a.client.Portforwards.List(&PortForwardingConfig{CloudspaceID: a.cloudSpaceID})
+	if err != nil {
+		return err
+	}
+	if list == nil {
+		list = &[]PortForwardingInfo{}
+	}
+	for _, pf := range *list {
+		// without a public IP the G8 uses the one of the cloudspace
+		if a.publicIP != "" && pf.PublicIP != a.publicIP {
+			continue
+		}
+		a.usedPorts(string(pf.Protocol))[pf.PublicPort] = true
+	}
+	a.loaded = true
+	return nil
+}
+
+// Refresh lists the port forwards again, keeping the ports reserved in-process
+func (a *PortAllocator) Refresh() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.load()
+}
+
+// Allocate reserves a random free public port for protocol
+func (a *PortAllocator) Allocate(protocol string) (int, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if !a.loaded {
+		if err := a.load(); err != nil {
+			return 0, err
+		}
+	}
+
+	used := a.usedPorts(protocol)
+	free := []int{}
+	for _, r := range a.ranges {
+		for port := r.Min; port <= r.Max; port++ {
+			if !used[port] {
+				free = append(free, port)
+			}
+		}
+	}
+	if len(free) == 0 {
+		return 0, fmt.Errorf("No free %s public port left on %s", protocol, a.publicIP)
+	}
+
+	port := free[a.rand.Intn(len(free))]
+	used[port] = true
+	return port, nil
+}
+
+// Reserve marks a specific public port as used, it fails if it already is
+func (a *PortAllocator) Reserve(protocol string, port int) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if !a.loaded {
+		if err := a.load(); err != nil {
+			return err
+		}
+	}
+	used := a.usedPorts(protocol)
+	if used[port] {
+		return fmt.Errorf("Public port %d/%s on %s is already in use", port, protocol, a.publicIP)
+	}
+	used[port] = true
+	return nil
+}
+
+// Release frees a public port, e.g. after deleting its port forward
+func (a *PortAllocator) Release(protocol string, port int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.usedPorts(protocol), port)
+}
+
+// markUsed records a port used outside of the allocator without listing
+func (a *PortAllocator) markUsed(protocol string, port int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.usedPorts(protocol)[port] = true
+}
+
+// Create creates a port forward on a freshly allocated public port and returns
+// the port. When the create fails and a refresh shows the port was taken in the
+// meantime, another port is tried.
+func (a *PortAllocator) Create(portForwardingConfig *PortForwardingConfig) (int, error) {
+	config := *portForwardingConfig
+	config.CloudspaceID = a.cloudSpaceID
+	config.PublicIP = a.publicIP
+
+	for attempt := 0; ; attempt++ {
+		port, err := a.Allocate(config.Protocol)
+		if err != nil {
+			return 0, err
+		}
+		config.PublicPort = port
+		_, err = a.client.Post("/cloudapi/portforwarding/create", config, OperationalActionTimeout)
+		if err == nil {
+			portForwardingConfig.PublicPort = port
+			return port, nil
+		}
+
+		a.Release(config.Protocol, port)
+		if attempt >= a.Retries || !a.takenElsewhere(config.Protocol, port) {
+			return 0, err
+		}
+		a.client.logger.Debugf("Public port %d/%s on %s was taken concurrently, retrying", port, config.Protocol, a.publicIP)
+	}
+}
+
+// takenElsewhere reports whether a refresh shows the port in use
+func (a *PortAllocator) takenElsewhere(protocol string, port int) bool {
+	if err := a.Refresh(); err != nil {
+		return false
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.usedPorts(protocol)[port]
+}
@@ -0,0 +1,103 @@
+package ovc
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// forwardsServer fakes the port forwarding endpoints, creates fail when the
+// public port is taken
+type forwardsServer struct {
+	forwards []map[string]interface{}
+	lists    int
+}
+
+func (f *forwardsServer) results(endpoint string, params map[string]interface{}) (interface{}, bool) {
+	switch endpoint {
+	case "/cloudapi/portforwarding/list":
+		f.lists++
+		return f.forwards, true
+	case "/cloudapi/portforwarding/create":
+		port := strconv.Itoa(int(params["publicPort"].(float64)))
+		protocol, _ := params["protocol"].(string)
+		if !f.add(port, protocol) {
+			return "port " + port + " is already in use", false
+		}
+		return true, true
+	}
+	return "unexpected call to " + endpoint, false
+}
+
+func (f *forwardsServer) add(port string, protocol string) bool {
+	for _, pf := range f.forwards {
+		if pf["publicPort"] == port && pf["protocol"] == protocol {
+			return false
+		}
+	}
+	f.forwards = append(f.forwards, map[string]interface{}{"publicIp": "185.1.2.3", "publicPort": port, "protocol": protocol})
+	return true
+}
+
+func TestPortAllocatorConcurrentCreates(t *testing.T) {
+	server := &forwardsServer{}
+	server.add("2000", "tcp")
+	client, stop := newTestClient(t, server.results)
+	defer stop()
+	client.Portforwards = &ForwardingServiceOp{client: client}
+	client.portRanges = []PortRange{{Min: 2000, Max: 2008}}
+
+	var wg sync.WaitGroup
+	ports := make(chan int, 8)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			port, err := client.Portforwards.Create(&PortForwardingConfig{
+				CloudspaceID: 3, PublicIP: "185.1.2.3", MachineID: 5, LocalPort: 22, Protocol: "tcp",
+			})
+			assert.NoError(t, err)
+			ports <- port
+		}()
+	}
+	wg.Wait()
+	close(ports)
+
+	seen := make(map[int]bool)
+	for port := range ports {
+		assert.False(t, seen[port], "port %d allocated twice", port)
+		assert.NotEqual(t, 2000, port)
+		seen[port] = true
+	}
+	assert.Len(t, seen, 8)
+	assert.Equal(t, 1, server.lists, "the port forwards should be listed once")
+
+	_, err := client.PortAllocator(3, "185.1.2.3").Allocate("tcp")
+	assert.Error(t, err, "the range should be exhausted")
+	port, err := client.PortAllocator(3, "185.1.2.3").Allocate("udp")
+	assert.NoError(t, err)
+	assert.True(t, port >= 2000 && port <= 2008)
+}
+
+func TestPortAllocatorRetriesOnConflict(t *testing.T) {
+	server := &forwardsServer{}
+	client, stop := newTestClient(t, server.results)
+	defer stop()
+	client.Portforwards = &ForwardingServiceOp{client: client}
+
+	allocator := NewPortAllocator(client, 3, "185.1.2.3", PortRange{Min: 2000, Max: 2002})
+	assert.NoError(t, allocator.Refresh())
+	// taken by another client after the allocator listed the port forwards
+	server.add("2000", "tcp")
+	server.add("2001", "tcp")
+
+	port, err := allocator.Create(&PortForwardingConfig{MachineID: 5, LocalPort: 22, Protocol: "tcp"})
+	assert.NoError(t, err)
+	assert.Equal(t, 2002, port)
+
+	allocator.Release("tcp", 2002)
+	assert.NoError(t, allocator.Reserve("tcp", 2002))
+	assert.Error(t, allocator.Reserve("tcp", 2002))
+}
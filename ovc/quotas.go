@@ -0,0 +1,347 @@
+package ovc
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Names of the resource limits (cloud units) of accounts and cloudspaces
+const (
+	LimitMemory    = "CU_M"
+	LimitVcpus     = "CU_C"
+	LimitDiskSize  = "CU_D"
+	LimitPublicIPs = "CU_I"
+)
+
+// ResourceUsage is the amount of resources counted against the limits of a
+// cloudspace or account. Network transfer (CU_NP) is not tracked.
+type ResourceUsage struct {
+	// Memory in GB
+	Memory float64 `json:"CU_M"`
+	Vcpus  int     `json:"CU_C"`
+	// DiskSize in GB
+	DiskSize  int `json:"CU_D"`
+	PublicIPs int `json:"CU_I"`
+}
+
+// QuotaExceededError is returned by the quota checks when a request doesn't
+// fit in the limits of a cloudspace or account
+type QuotaExceededError struct {
+	// Scope is "cloudspace" or "account"
+	Scope     string
+	ID        int
+	Limit     string
+	Max       float64
+	Used      float64
+	Requested float64
+}
+
+// Shortfall is the amount of the resource missing to fulfil the request
+func (e *QuotaExceededError) Shortfall() float64 {
+	return e.Used + e.Requested - e.Max
+}
+
+func (e *QuotaExceededError) Error() string {
+	return fmt.Sprintf("Quota %s of %s %d exceeded: %s used of %s, %s requested, short by %s",
+		e.Limit, e.Scope, e.ID, formatUnits(e.Used), formatUnits(e.Max), formatUnits(e.Requested), formatUnits(e.Shortfall()))
+}
+
+func formatUnits(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// QuotaService is an interface for computing resource usage and checking
+// requests against the resource limits of cloudspaces and accounts
+type QuotaService interface {
+	CloudSpaceUsage(int) (*ResourceUsage, error)
+	AccountUsage(int) (*ResourceUsage, error)
+	CheckMachineCreate(*MachineConfig) error
+	CheckMachineResize(*MachineConfig) error
+	CheckDiskCreate(*DiskConfig) error
+}
+
+// QuotaServiceOp handles the quota checks using the machine, disk and
+// cloudspace services of the client
+type QuotaServiceOp struct {
+	client *Client
+}
+
+// CloudSpaceUsage returns the resources used by the machines of a cloudspace
+func (s *QuotaServiceOp) CloudSpaceUsage(cloudSpaceID int) (*ResourceUsage, error) {
+	cloudSpace, err := s.client.CloudSpaces.Get(cloudSpaceID)
+	if err != nil {
+		return nil, err
+	}
+	diskSizes, err := s.diskSizes(cloudSpace.AccountID)
+	if err != nil {
+		return nil, err
+	}
+	return s.cloudSpaceUsage(cloudSpace.ID, cloudSpace.Externalnetworkip, diskSizes)
+}
+
+// AccountUsage returns the resources used by all cloudspaces and disks of an
+// account
+func (s *QuotaServiceOp) AccountUsage(accountID int) (*ResourceUsage, error) {
+	diskSizes, err := s.diskSizes(accountID)
+	if err != nil {
+		return nil, err
+	}
+	cloudSpaces, err := s.client.CloudSpaces.List()
+	if err != nil {
+		return nil, err
+	}
+
+	usage := &ResourceUsage{}
+	for _, size := range diskSizes {
+		usage.DiskSize += size
+	}
+	for _, cs := range *cloudSpaces {
+		if cs.AccountID != accountID {
+			continue
+		}
+		// disks are counted once for the whole account above
+		csUsage, err := s.cloudSpaceUsage(cs.ID, cs.Externalnetworkip, nil)
+		if err != nil {
+			return nil, err
+		}
+		usage.Memory += csUsage.Memory
+		usage.Vcpus += csUsage.Vcpus
+		usage.PublicIPs += csUsage.PublicIPs
+	}
+
+	return usage, nil
+}
+
+func (s *QuotaServiceOp) cloudSpaceUsage(cloudSpaceID int, externalNetworkIP string, diskSizes map[int]int) (*ResourceUsage, error) {
+	machines, err := s.client.Machines.List(cloudSpaceID)
+	if err != nil {
+		return nil, err
+	}
+	usage := &ResourceUsage{}
+	if externalNetworkIP != "" {
+		usage.PublicIPs++
+	}
+	for _, machine := range *machines {
+		if isDeletedStatus(machine.Status) {
+			continue
+		}
+		usage.Memory += float64(machine.Memory) / 1024
+		usage.Vcpus += machine.Vcpus
+		for _, diskID := range machine.Disks {
+			usage.DiskSize += diskSizes[diskID]
+		}
+		for _, nic := range machine.Nics {
+			if strings.EqualFold(nic.Type, "PUBLIC") {
+				usage.PublicIPs++
+			}
+		}
+	}
+
+	return usage, nil
+}
+
+// diskSizes returns the size of every disk of an account by ID
+func (s *QuotaServiceOp) diskSizes(accountID int) (map[int]int, error) {
+	disks, err := s.client.Disks.List(accountID, "")
+	if err != nil {
+		return nil, err
+	}
+	sizes := make(map[int]int, len(*disks))
+	for _, disk := range *disks {
+		if !isDeletedStatus(disk.Status) {
+			sizes[disk.ID] = disk.Size
+		}
+	}
+	return sizes, nil
+}
+
+func isDeletedStatus(status string) bool {
+	switch strings.ToUpper(status) {
+	case "DELETED", "DESTROYED", "DESTROYING":
+		return true
+	}
+	return false
+}
+
+// CheckMachineCreate checks if a machine with the given configuration fits in
+// the limits of its cloudspace and account
+func (s *QuotaServiceOp) CheckMachineCreate(machineConfig *MachineConfig) error {
+	requested := &ResourceUsage{
+		Memory:   float64(machineConfig.Memory) / 1024,
+		Vcpus:    machineConfig.Vcpus,
+		DiskSize: machineConfig.Disksize,
+	}
+	if machineConfig.SizeID != 0 {
+		size, err := s.size(machineConfig.SizeID, machineConfig.CloudspaceID)
+		if err != nil {
+			return err
+		}
+		requested.Memory = float64(size.Memory) / 1024
+		requested.Vcpus = size.Vcpus
+	}
+	for _, dataDisk := range machineConfig.DataDisks {
+		switch size := dataDisk.(type) {
+		case int:
+			requested.DiskSize += size
+		case float64:
+			requested.DiskSize += int(size)
+		}
+	}
+
+	return s.check(machineConfig.CloudspaceID, requested)
+}
+
+// CheckMachineResize checks if resizing a machine to the given configuration
+// fits in the limits of its cloudspace and account
+func (s *QuotaServiceOp) CheckMachineResize(machineConfig *MachineConfig) error {
+	machineID, err := strconv.Atoi(machineConfig.MachineID)
+	if err != nil {
+		return fmt.Errorf("Invalid machine ID %q", machineConfig.MachineID)
+	}
+	machine, err := s.client.Machines.Get(machineID)
+	if err != nil {
+		return err
+	}
+	memory, vcpus := machineConfig.Memory, machineConfig.Vcpus
+	if machineConfig.SizeID != 0 {
+		size, err := s.size(machineConfig.SizeID, machine.CloudspaceID)
+		if err != nil {
+			return err
+		}
+		memory, vcpus = size.Memory, size.Vcpus
+	}
+
+	// only growth counts against the limits
+	requested := &ResourceUsage{}
+	if memory > machine.Memory {
+		requested.Memory = float64(memory-machine.Memory) / 1024
+	}
+	if vcpus > machine.Vcpus {
+		requested.Vcpus = vcpus - machine.Vcpus
+	}
+
+	return s.check(machine.CloudspaceID, requested)
+}
+
+// CheckDiskCreate checks if a disk fits in the limits of its account, and of
+// the cloudspace of the machine when it is created attached to a machine
+func (s *QuotaServiceOp) CheckDiskCreate(diskConfig *DiskConfig) error {
+	requested := &ResourceUsage{DiskSize: diskConfig.Size}
+	if diskConfig.MachineID != 0 {
+		machine, err := s.client.Machines.Get(diskConfig.MachineID)
+		if err != nil {
+			return err
+		}
+		return s.check(machine.CloudspaceID, requested)
+	}
+
+	return s.checkAccount(diskConfig.AccountID, requested)
+}
+
+func (s *QuotaServiceOp) size(sizeID int, cloudSpaceID int) (*Size, error) {
+	sizes, err := s.client.Sizes.List(cloudSpaceID)
+	if err != nil {
+		return nil, err
+	}
+	for _, size := range *sizes {
+		if size.ID == sizeID {
+			return &size, nil
+		}
+	}
+	return nil, fmt.Errorf("Size %d not found", sizeID)
+}
+
+// quotaSubject is a cloudspace or account as far as the quota checks need it.
+// The limits are decoded into a map as ResourceLimits can't tell a limit the
+// G8 left out from a limit of 0.
+type quotaSubject struct {
+	AccountID         int                 `json:"accountId"`
+	Externalnetworkip string              `json:"externalnetworkip"`
+	ResourceLimits    map[string]*float64 `json:"resourceLimits"`
+}
+
+// subject fetches the cloudspace or account the limits of which are checked
+func (s *QuotaServiceOp) subject(endpoint string, idField string, id int) (*quotaSubject, error) {
+	subjectMap := make(map[string]interface{})
+	subjectMap[idField] = id
+
+	body, err := s.client.Post(endpoint, subjectMap, ModelActionTimeout)
+	if err != nil {
+		return nil, err
+	}
+	subject := new(quotaSubject)
+	err = json.Unmarshal(body, &subject)
+	if err != nil {
+		return nil, err
+	}
+	return subject, nil
+}
+
+// check checks the requested resources against the limits of a cloudspace and
+// of its account
+func (s *QuotaServiceOp) check(cloudSpaceID int, requested *ResourceUsage) error {
+	cloudSpace, err := s.subject("/cloudapi/cloudspaces/get", "cloudspaceId", cloudSpaceID)
+	if err != nil {
+		return err
+	}
+	diskSizes, err := s.diskSizes(cloudSpace.AccountID)
+	if err != nil {
+		return err
+	}
+	usage, err := s.cloudSpaceUsage(cloudSpaceID, cloudSpace.Externalnetworkip, diskSizes)
+	if err != nil {
+		return err
+	}
+	err = checkLimits("cloudspace", cloudSpaceID, cloudSpace.ResourceLimits, usage, requested)
+	if err != nil {
+		return err
+	}
+
+	return s.checkAccount(cloudSpace.AccountID, requested)
+}
+
+func (s *QuotaServiceOp) checkAccount(accountID int, requested *ResourceUsage) error {
+	account, err := s.subject("/cloudapi/accounts/get", "accountId", accountID)
+	if err != nil {
+		return err
+	}
+	usage, err := s.AccountUsage(accountID)
+	if err != nil {
+		return err
+	}
+	return checkLimits("account", accountID, account.ResourceLimits, usage, requested)
+}
+
+// checkLimits returns a QuotaExceededError for the first limit the requested
+// resources don't fit in. Negative and missing limits are unlimited.
+func checkLimits(scope string, id int, limits map[string]*float64, usage *ResourceUsage, requested *ResourceUsage) error {
+	checks := []struct {
+		limit     string
+		used      float64
+		requested float64
+	}{
+		{LimitMemory, usage.Memory, requested.Memory},
+		{LimitVcpus, float64(usage.Vcpus), float64(requested.Vcpus)},
+		{LimitDiskSize, float64(usage.DiskSize), float64(requested.DiskSize)},
+		{LimitPublicIPs, float64(usage.PublicIPs), float64(requested.PublicIPs)},
+	}
+	for _, c := range checks {
+		max := limits[c.limit]
+		if max == nil || *max < 0 || c.requested <= 0 {
+			continue
+		}
+		if c.used+c.requested > *max {
+			return &QuotaExceededError{
+				Scope:     scope,
+				ID:        id,
+				Limit:     c.limit,
+				Max:       *max,
+				Used:      c.used,
+				Requested: c.requested,
+			}
+		}
+	}
+	return nil
+}
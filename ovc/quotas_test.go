@@ -0,0 +1,88 @@
+package ovc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuotaChecks(t *testing.T) {
+	client, stop := newTestClient(t, func(endpoint string, params map[string]interface{}) (interface{}, bool) {
+		switch endpoint {
+		case "/cloudapi/cloudspaces/get":
+			return map[string]interface{}{
+				"id": 1, "accountId": 7, "externalnetworkip": "185.15.201.114",
+				"resourceLimits": map[string]interface{}{"CU_M": 8, "CU_C": -1, "CU_D": 100, "CU_I": -1, "CU_NP": -1},
+			}, true
+		case "/cloudapi/cloudspaces/list":
+			return []map[string]interface{}{{"id": 1, "accountId": 7, "externalnetworkip": "185.15.201.114"}}, true
+		case "/cloudapi/accounts/get":
+			return map[string]interface{}{
+				// limits left out by the G8 are not enforced
+				"resourceLimits": map[string]interface{}{"CU_D": 120},
+			}, true
+		case "/cloudapi/machines/list":
+			return []map[string]interface{}{
+				{"id": 10, "memory": 4096, "vcpus": 2, "disks": []int{100}, "status": "RUNNING",
+					"nics": []map[string]interface{}{{"type": "PUBLIC", "ipAddress": "185.15.201.115/24"}}},
+			}, true
+		case "/cloudapi/machines/get":
+			return map[string]interface{}{"id": 10, "cloudspaceid": 1, "memory": 4096, "vcpus": 2}, true
+		case "/cloudapi/disks/list":
+			return []map[string]interface{}{
+				{"id": 100, "sizeMax": 50, "status": "ASSIGNED"},
+				{"id": 101, "sizeMax": 40, "status": "CREATED"},
+			}, true
+		case "/cloudapi/sizes/list":
+			return []map[string]interface{}{{"id": 3, "memory": 8192, "vcpus": 4}}, true
+		}
+		return "unexpected call to " + endpoint, false
+	})
+	defer stop()
+	client.CloudSpaces = &CloudSpaceServiceOp{client: client}
+	client.Disks = &DiskServiceOp{client: client}
+	client.Sizes = &SizesServiceOp{client: client}
+	client.Accounts = &AccountServiceOp{client: client}
+	quotas := &QuotaServiceOp{client: client}
+
+	usage, err := quotas.CloudSpaceUsage(1)
+	assert.NoError(t, err)
+	assert.Equal(t, &ResourceUsage{Memory: 4, Vcpus: 2, DiskSize: 50, PublicIPs: 2}, usage)
+	usage, err = quotas.AccountUsage(7)
+	assert.NoError(t, err)
+	assert.Equal(t, 90, usage.DiskSize, "unattached disks count for the account")
+
+	assert.NoError(t, quotas.CheckMachineCreate(&MachineConfig{CloudspaceID: 1, Memory: 4096, Vcpus: 2, Disksize: 20}))
+
+	err = quotas.CheckMachineCreate(&MachineConfig{CloudspaceID: 1, SizeID: 3, Disksize: 20})
+	if assert.IsType(t, &QuotaExceededError{}, err) {
+		quotaErr := err.(*QuotaExceededError)
+		assert.Equal(t, "cloudspace", quotaErr.Scope)
+		assert.Equal(t, LimitMemory, quotaErr.Limit)
+		assert.Equal(t, 4.0, quotaErr.Shortfall())
+	}
+
+	err = quotas.CheckDiskCreate(&DiskConfig{AccountID: 7, Size: 40})
+	if assert.IsType(t, &QuotaExceededError{}, err) {
+		quotaErr := err.(*QuotaExceededError)
+		assert.Equal(t, "account", quotaErr.Scope)
+		assert.Equal(t, LimitDiskSize, quotaErr.Limit)
+		assert.Equal(t, 10.0, quotaErr.Shortfall())
+		assert.Equal(t, "Quota CU_D of account 7 exceeded: 90 used of 120, 40 requested, short by 10", err.Error())
+	}
+
+	err = quotas.CheckMachineResize(&MachineConfig{MachineID: "10", Memory: 16384})
+	if assert.IsType(t, &QuotaExceededError{}, err) {
+		assert.Equal(t, 8.0, err.(*QuotaExceededError).Shortfall())
+	}
+	assert.NoError(t, quotas.CheckMachineResize(&MachineConfig{MachineID: "10", Memory: 2048, Vcpus: 8}))
+}
+
+func TestCheckLimits(t *testing.T) {
+	zero, unlimited := 0.0, -1.0
+	requested := &ResourceUsage{Vcpus: 1}
+	assert.NoError(t, checkLimits("account", 7, map[string]*float64{}, &ResourceUsage{}, requested), "missing limits should be skipped")
+	assert.NoError(t, checkLimits("account", 7, map[string]*float64{LimitVcpus: &unlimited}, &ResourceUsage{}, requested))
+	assert.Error(t, checkLimits("account", 7, map[string]*float64{LimitVcpus: &zero}, &ResourceUsage{}, requested),
+		"a limit of 0 should allow nothing")
+}
@@ -0,0 +1,167 @@
+package reconcile
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Document describes the desired state of a cloudspace
+type Document struct {
+	CloudSpace CloudSpace `json:"cloudspace" yaml:"cloudspace"`
+	Machines   []Machine  `json:"machines,omitempty" yaml:"machines,omitempty"`
+	Tunnels    []Tunnel   `json:"tunnels,omitempty" yaml:"tunnels,omitempty"`
+}
+
+// CloudSpace is the desired state of the cloudspace itself
+type CloudSpace struct {
+	Name              string `json:"name" yaml:"name"`
+	Account           string `json:"account" yaml:"account"`
+	Location          string `json:"location,omitempty" yaml:"location,omitempty"`
+	PrivateNetwork    string `json:"privateNetwork,omitempty" yaml:"privateNetwork,omitempty"`
+	ExternalNetworkID int    `json:"externalNetworkId,omitempty" yaml:"externalNetworkId,omitempty"`
+	Limits            Limits `json:"limits,omitempty" yaml:"limits,omitempty"`
+}
+
+// Limits are the resource limits of the cloudspace. Zero values leave the
+// corresponding limit unmanaged, -1 means unlimited.
+type Limits struct {
+	MaxMemoryCapacity      float64 `json:"maxMemoryCapacity,omitempty" yaml:"maxMemoryCapacity,omitempty"`
+	MaxCPUCapacity         int     `json:"maxCPUCapacity,omitempty" yaml:"maxCPUCapacity,omitempty"`
+	MaxDiskCapacity        int     `json:"maxDiskCapacity,omitempty" yaml:"maxDiskCapacity,omitempty"`
+	MaxNetworkPeerTransfer int     `json:"maxNetworkPeerTransfer,omitempty" yaml:"maxNetworkPeerTransfer,omitempty"`
+	MaxNumPublicIP         int     `json:"maxNumPublicIP,omitempty" yaml:"maxNumPublicIP,omitempty"`
+}
+
+// Machine is the desired state of a machine in the cloudspace.
+// Either SizeID or Vcpus and Memory (in MB) select the size of the machine,
+// either ImageID or Image (the image name) its image.
+type Machine struct {
+	Name         string        `json:"name" yaml:"name"`
+	Description  string        `json:"description,omitempty" yaml:"description,omitempty"`
+	ImageID      int           `json:"imageId,omitempty" yaml:"imageId,omitempty"`
+	Image        string        `json:"image,omitempty" yaml:"image,omitempty"`
+	SizeID       int           `json:"sizeId,omitempty" yaml:"sizeId,omitempty"`
+	Vcpus        int           `json:"vcpus,omitempty" yaml:"vcpus,omitempty"`
+	Memory       int           `json:"memory,omitempty" yaml:"memory,omitempty"`
+	Disksize     int           `json:"disksize,omitempty" yaml:"disksize,omitempty"`
+	Userdata     string        `json:"userdata,omitempty" yaml:"userdata,omitempty"`
+	DataDisks    []DataDisk    `json:"dataDisks,omitempty" yaml:"dataDisks,omitempty"`
+	PortForwards []PortForward `json:"portForwards,omitempty" yaml:"portForwards,omitempty"`
+}
+
+// DataDisk is the desired state of a data disk attached to a machine
+type DataDisk struct {
+	Name        string `json:"name" yaml:"name"`
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+	Size        int    `json:"size" yaml:"size"`
+	IOPS        int    `json:"iops,omitempty" yaml:"iops,omitempty"`
+}
+
+// PortForward is the desired state of a port forward from the public IP of
+// the cloudspace to a machine
+type PortForward struct {
+	PublicPort int    `json:"publicPort" yaml:"publicPort"`
+	LocalPort  int    `json:"localPort" yaml:"localPort"`
+	Protocol   string `json:"protocol,omitempty" yaml:"protocol,omitempty"`
+}
+
+// Tunnel is the desired state of an IPsec tunnel of the cloudspace
+type Tunnel struct {
+	RemoteAddress string `json:"remoteAddress" yaml:"remoteAddress"`
+	RemoteNetwork string `json:"remoteNetwork" yaml:"remoteNetwork"`
+	PSK           string `json:"psk,omitempty" yaml:"psk,omitempty"`
+}
+
+// ParseDocument parses a YAML or JSON document
+func ParseDocument(data []byte) (*Document, error) {
+	doc := &Document{}
+	if err := yaml.UnmarshalStrict(data, doc); err != nil {
+		return nil, fmt.Errorf("Invalid desired state document: %s", err)
+	}
+	if err := doc.validate(); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// ReadDocument reads and parses a YAML or JSON document from r
+func ReadDocument(r io.Reader) (*Document, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return ParseDocument(data)
+}
+
+// validate checks the document for missing and duplicate names and fills
+// in defaults
+func (d *Document) validate() error {
+	if d.CloudSpace.Name == "" {
+		return fmt.Errorf("Desired state document has no cloudspace name")
+	}
+	if d.CloudSpace.Account == "" {
+		return fmt.Errorf("Desired state document has no cloudspace account")
+	}
+
+	machines := make(map[string]bool)
+	for i := range d.Machines {
+		machine := &d.Machines[i]
+		if machine.Name == "" {
+			return fmt.Errorf("Machine %d has no name", i)
+		}
+		if machines[machine.Name] {
+			return fmt.Errorf("Machine %s is declared more than once", machine.Name)
+		}
+		machines[machine.Name] = true
+		if machine.ImageID == 0 && machine.Image == "" {
+			return fmt.Errorf("Machine %s has no image", machine.Name)
+		}
+		if machine.SizeID == 0 && (machine.Vcpus == 0 || machine.Memory == 0) {
+			return fmt.Errorf("Machine %s needs a size ID or vcpus and memory", machine.Name)
+		}
+
+		disks := make(map[string]bool)
+		for _, disk := range machine.DataDisks {
+			if disk.Name == "" {
+				return fmt.Errorf("Machine %s has a data disk without name", machine.Name)
+			}
+			if disks[disk.Name] {
+				return fmt.Errorf("Data disk %s of machine %s is declared more than once", disk.Name, machine.Name)
+			}
+			disks[disk.Name] = true
+		}
+
+		for j := range machine.PortForwards {
+			if machine.PortForwards[j].Protocol == "" {
+				machine.PortForwards[j].Protocol = "tcp"
+			}
+		}
+	}
+
+	tunnels := make(map[string]bool)
+	for _, tunnel := range d.Tunnels {
+		if tunnel.RemoteAddress == "" {
+			return fmt.Errorf("IPsec tunnel without remote address")
+		}
+		if tunnels[tunnel.RemoteAddress] {
+			return fmt.Errorf("IPsec tunnel to %s is declared more than once", tunnel.RemoteAddress)
+		}
+		tunnels[tunnel.RemoteAddress] = true
+	}
+
+	publicPorts := make(map[string]string)
+	for _, machine := range d.Machines {
+		for _, pf := range machine.PortForwards {
+			key := fmt.Sprintf("%d/%s", pf.PublicPort, pf.Protocol)
+			if other, ok := publicPorts[key]; ok {
+				return fmt.Errorf("Public port %s is forwarded to both %s and %s", key, other, machine.Name)
+			}
+			publicPorts[key] = machine.Name
+		}
+	}
+
+	return nil
+}
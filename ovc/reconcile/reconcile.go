@@ -0,0 +1,796 @@
+// Package reconcile computes and applies the changes needed to bring a
+// cloudspace, its machines, data disks, port forwards and IPsec tunnels in
+// line with a declarative desired state document.
+package reconcile
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gig-tech/ovc-sdk-go/v4/ovc"
+)
+
+// Action is the kind of change a Step makes
+type Action string
+
+const (
+	// ActionCreate creates a missing resource
+	ActionCreate Action = "create"
+	// ActionUpdate changes an existing resource in place
+	ActionUpdate Action = "update"
+	// ActionDelete removes a resource that is not (or no longer) desired
+	ActionDelete Action = "delete"
+)
+
+// Resource kinds a Step can act on
+const (
+	ResourceCloudSpace  = "cloudspace"
+	ResourceMachine     = "machine"
+	ResourceDisk        = "disk"
+	ResourcePortForward = "portforward"
+	ResourceTunnel      = "tunnel"
+)
+
+// Options tune the behaviour of the Reconciler
+type Options struct {
+	// Prune deletes machines, data disks and IPsec tunnels of the cloudspace
+	// that are not declared in the document. Port forwards of declared machines
+	// are always reconciled to exactly the declared list.
+	Prune bool
+	// PermanentlyDelete deletes machines and disks permanently instead of
+	// moving them to the recycle bin
+	PermanentlyDelete bool
+}
+
+// Step is a single change in a Plan
+type Step struct {
+	Action   Action `json:"action"`
+	Resource string `json:"resource"`
+	Name     string `json:"name"`
+	Details  string `json:"details,omitempty"`
+
+	key       string
+	dependsOn []string
+	apply     func(*ovc.Client, *runState) error
+}
+
+func (s *Step) String() string {
+	symbol := map[Action]string{ActionCreate: "+", ActionUpdate: "~", ActionDelete: "-"}[s.Action]
+	str := fmt.Sprintf("%s %s %s %s", symbol, s.Action, s.Resource, s.Name)
+	if s.Details != "" {
+		str += " (" + s.Details + ")"
+	}
+	return str
+}
+
+// Plan is the ordered list of steps needed to reach the desired state
+type Plan struct {
+	Steps []*Step `json:"steps"`
+	// Warnings lists differences that can't be reconciled, e.g. a changed
+	// private network of an existing cloudspace
+	Warnings []string `json:"warnings,omitempty"`
+
+	state runState
+}
+
+// Empty reports whether the actual state already matches the desired state
+func (p *Plan) Empty() bool {
+	return len(p.Steps) == 0
+}
+
+func (p *Plan) String() string {
+	lines := []string{}
+	for _, step := range p.Steps {
+		lines = append(lines, step.String())
+	}
+	for _, warning := range p.Warnings {
+		lines = append(lines, "! "+warning)
+	}
+	if len(lines) == 0 {
+		return "No changes, the cloudspace matches the desired state\n"
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// runState holds the IDs that are only known once earlier steps have been applied
+type runState struct {
+	accountID    int
+	cloudSpaceID int
+	publicIP     string
+	machineIDs   map[string]int
+}
+
+// StepFailure is a step that could not be applied
+type StepFailure struct {
+	Step *Step
+	Err  error
+}
+
+// Result reports the outcome of applying a plan
+type Result struct {
+	Applied []*Step
+	Failed  []StepFailure
+	// Skipped contains the steps that were not attempted because a step they
+	// depend on failed
+	Skipped []*Step
+}
+
+// Err returns an error summarizing the failed and skipped steps, or nil if all
+// steps were applied
+func (r *Result) Err() error {
+	if len(r.Failed) == 0 {
+		return nil
+	}
+	msgs := make([]string, 0, len(r.Failed))
+	for _, failure := range r.Failed {
+		msgs = append(msgs, fmt.Sprintf("%s: %s", failure.Step, failure.Err))
+	}
+	return fmt.Errorf("%d of %d steps failed, %d skipped: %s",
+		len(r.Failed), len(r.Applied)+len(r.Failed)+len(r.Skipped), len(r.Skipped), strings.Join(msgs, "; "))
+}
+
+// Reconciler plans and applies desired state documents
+type Reconciler struct {
+	client  *ovc.Client
+	options Options
+}
+
+// NewReconciler returns a Reconciler using client to inspect and change the G8
+func NewReconciler(client *ovc.Client, options Options) *Reconciler {
+	return &Reconciler{client: client, options: options}
+}
+
+// Apply executes the steps of plan in order. A failing step doesn't stop the
+// run, but the steps depending on it are skipped. Running Plan and Apply again
+// retries whatever is left to do.
+func (r *Reconciler) Apply(plan *Plan) (*Result, error) {
+	state := plan.state
+	state.machineIDs = make(map[string]int, len(plan.state.machineIDs))
+	for name, id := range plan.state.machineIDs {
+		state.machineIDs[name] = id
+	}
+
+	result := &Result{}
+	broken := make(map[string]bool)
+	for _, step := range plan.Steps {
+		skip := false
+		for _, dependency := range step.dependsOn {
+			if broken[dependency] {
+				skip = true
+				break
+			}
+		}
+		if skip {
+			broken[step.key] = true
+			result.Skipped = append(result.Skipped, step)
+			continue
+		}
+		if err := step.apply(r.client, &state); err != nil {
+			broken[step.key] = true
+			result.Failed = append(result.Failed, StepFailure{Step: step, Err: err})
+			continue
+		}
+		result.Applied = append(result.Applied, step)
+	}
+
+	return result, result.Err()
+}
+
+// Plan compares doc with the actual state of the G8 and returns the steps
+// needed to converge
+func (r *Reconciler) Plan(doc *Document) (*Plan, error) {
+	p := &planner{
+		client:  r.client,
+		options: r.options,
+		doc:     doc,
+		plan:    &Plan{state: runState{machineIDs: make(map[string]int)}},
+	}
+	if err := p.run(); err != nil {
+		return nil, err
+	}
+	p.plan.Steps = append(p.plan.Steps, p.deletes...)
+	p.plan.Steps = append(p.plan.Steps, p.creates...)
+	return p.plan, nil
+}
+
+// planner holds the intermediate state while computing a Plan. Deleting steps
+// are collected separately so they can run before anything is created.
+type planner struct {
+	client  *ovc.Client
+	options Options
+	doc     *Document
+	plan    *Plan
+	deletes []*Step
+	creates []*Step
+
+	images map[string]int
+}
+
+const cloudSpaceKey = "cloudspace"
+
+func machineKey(name string) string {
+	return "machine/" + name
+}
+
+func (p *planner) addDelete(step *Step) {
+	p.deletes = append(p.deletes, step)
+}
+
+func (p *planner) addCreate(step *Step) {
+	p.creates = append(p.creates, step)
+}
+
+func (p *planner) warn(format string, args ...interface{}) {
+	p.plan.Warnings = append(p.plan.Warnings, fmt.Sprintf(format, args...))
+}
+
+func (p *planner) run() error {
+	desired := p.doc.CloudSpace
+	accountID, err := strconv.Atoi(desired.Account)
+	if err != nil {
+		accountID, err = p.client.Accounts.GetIDByName(desired.Account)
+		if err != nil {
+			return fmt.Errorf("Could not resolve account %s: %s", desired.Account, err)
+		}
+	}
+	p.plan.state.accountID = accountID
+
+	cloudSpaces, err := p.client.CloudSpaces.List()
+	if err != nil {
+		return err
+	}
+	var cloudSpace *ovc.CloudSpace
+	for _, cs := range *cloudSpaces {
+		if cs.AccountID == accountID && cs.Name == desired.Name {
+			cloudSpace, err = p.client.CloudSpaces.Get(cs.ID)
+			if err != nil {
+				return err
+			}
+			break
+		}
+	}
+
+	if cloudSpace == nil {
+		p.planCloudSpaceCreate()
+		for i := range p.doc.Machines {
+			if err := p.planMachine(&p.doc.Machines[i], nil, nil); err != nil {
+				return err
+			}
+		}
+		p.planPortForwards(nil)
+		p.planTunnels(nil)
+		return nil
+	}
+
+	p.plan.state.cloudSpaceID = cloudSpace.ID
+	p.plan.state.publicIP = cloudSpace.PublicIP()
+	p.planCloudSpaceUpdate(cloudSpace)
+
+	machines, err := p.client.Machines.List(cloudSpace.ID)
+	if err != nil {
+		return err
+	}
+	existing := make(map[string]ovc.Machine, len(*machines))
+	for _, machine := range *machines {
+		existing[machine.Name] = machine
+	}
+	portForwards, err := p.client.Portforwards.List(&ovc.PortForwardingConfig{CloudspaceID: cloudSpace.ID})
+	if err != nil {
+		return err
+	}
+
+	declared := make(map[string]bool, len(p.doc.Machines))
+	for i := range p.doc.Machines {
+		desiredMachine := &p.doc.Machines[i]
+		declared[desiredMachine.Name] = true
+		actual, ok := existing[desiredMachine.Name]
+		if !ok {
+			if err := p.planMachine(desiredMachine, nil, nil); err != nil {
+				return err
+			}
+			continue
+		}
+		p.plan.state.machineIDs[actual.Name] = actual.ID
+		info, err := p.client.Machines.Get(actual.ID)
+		if err != nil {
+			return err
+		}
+		if err := p.planMachine(desiredMachine, &actual, info); err != nil {
+			return err
+		}
+	}
+	p.planPortForwards(*portForwards)
+
+	if p.options.Prune {
+		for _, machine := range *machines {
+			if declared[machine.Name] {
+				continue
+			}
+			name := machine.Name
+			id := machine.ID
+			p.addDelete(&Step{
+				Action:   ActionDelete,
+				Resource: ResourceMachine,
+				Name:     name,
+				key:      machineKey(name),
+				apply: func(c *ovc.Client, s *runState) error {
+					return c.Machines.Delete(id, p.options.PermanentlyDelete)
+				},
+			})
+		}
+	}
+
+	tunnels, err := p.client.Ipsec.List(&ovc.IpsecConfig{CloudspaceID: cloudSpace.ID})
+	if err != nil {
+		return err
+	}
+	p.planTunnels(*tunnels)
+
+	return nil
+}
+
+func (p *planner) planCloudSpaceCreate() {
+	desired := p.doc.CloudSpace
+	location := desired.Location
+	if location == "" {
+		location = p.client.GetLocation()
+	}
+	config := &ovc.CloudSpaceConfig{
+		Name:                   desired.Name,
+		Location:               location,
+		PrivateNetwork:         desired.PrivateNetwork,
+		ExternalnetworkID:      desired.ExternalNetworkID,
+		MaxMemoryCapacity:      desired.Limits.MaxMemoryCapacity,
+		MaxCPUCapacity:         desired.Limits.MaxCPUCapacity,
+		MaxDiskCapacity:        desired.Limits.MaxDiskCapacity,
+		MaxNetworkPeerTransfer: desired.Limits.MaxNetworkPeerTransfer,
+		MaxNumPublicIP:         desired.Limits.MaxNumPublicIP,
+	}
+	p.addCreate(&Step{
+		Action:   ActionCreate,
+		Resource: ResourceCloudSpace,
+		Name:     desired.Name,
+		Details:  "location " + location,
+		key:      cloudSpaceKey,
+		apply: func(c *ovc.Client, s *runState) error {
+			config.AccountID = s.accountID
+			config.Access = c.Access
+			id, err := c.CloudSpaces.Create(config)
+			if err != nil {
+				return err
+			}
+			s.cloudSpaceID = id
+			cloudSpace, err := c.CloudSpaces.Get(id)
+			if err != nil {
+				return err
+			}
+			s.publicIP = cloudSpace.PublicIP()
+			return nil
+		},
+	})
+}
+
+func (p *planner) planCloudSpaceUpdate(actual *ovc.CloudSpace) {
+	desired := p.doc.CloudSpace
+	if desired.PrivateNetwork != "" && desired.PrivateNetwork != actual.PrivateNetwork {
+		p.warn("cloudspace %s has private network %s, it can't be changed to %s", actual.Name, actual.PrivateNetwork, desired.PrivateNetwork)
+	}
+	if desired.Location != "" && desired.Location != actual.Location {
+		p.warn("cloudspace %s is in location %s, it can't be moved to %s", actual.Name, actual.Location, desired.Location)
+	}
+
+	limits := actual.ResourceLimits
+	changes := []string{}
+	if desired.Limits.MaxMemoryCapacity != 0 && desired.Limits.MaxMemoryCapacity != limits.CUM {
+		changes = append(changes, fmt.Sprintf("memory %v -> %v", limits.CUM, desired.Limits.MaxMemoryCapacity))
+	}
+	if desired.Limits.MaxCPUCapacity != 0 && desired.Limits.MaxCPUCapacity != limits.CUC {
+		changes = append(changes, fmt.Sprintf("vcpus %v -> %v", limits.CUC, desired.Limits.MaxCPUCapacity))
+	}
+	if desired.Limits.MaxDiskCapacity != 0 && desired.Limits.MaxDiskCapacity != limits.CUD {
+		changes = append(changes, fmt.Sprintf("disk %v -> %v", limits.CUD, desired.Limits.MaxDiskCapacity))
+	}
+	if desired.Limits.MaxNetworkPeerTransfer != 0 && desired.Limits.MaxNetworkPeerTransfer != limits.CUNP {
+		changes = append(changes, fmt.Sprintf("transfer %v -> %v", limits.CUNP, desired.Limits.MaxNetworkPeerTransfer))
+	}
+	if desired.Limits.MaxNumPublicIP != 0 && desired.Limits.MaxNumPublicIP != limits.CUI {
+		changes = append(changes, fmt.Sprintf("public IPs %v -> %v", limits.CUI, desired.Limits.MaxNumPublicIP))
+	}
+	if len(changes) == 0 {
+		return
+	}
+
+	config := &ovc.CloudSpaceConfig{
+		CloudSpaceID:           actual.ID,
+		MaxMemoryCapacity:      desired.Limits.MaxMemoryCapacity,
+		MaxCPUCapacity:         desired.Limits.MaxCPUCapacity,
+		MaxDiskCapacity:        desired.Limits.MaxDiskCapacity,
+		MaxNetworkPeerTransfer: desired.Limits.MaxNetworkPeerTransfer,
+		MaxNumPublicIP:         desired.Limits.MaxNumPublicIP,
+	}
+	p.addCreate(&Step{
+		Action:   ActionUpdate,
+		Resource: ResourceCloudSpace,
+		Name:     actual.Name,
+		Details:  strings.Join(changes, ", "),
+		key:      cloudSpaceKey + "/limits",
+		apply: func(c *ovc.Client, s *runState) error {
+			return c.CloudSpaces.Update(config)
+		},
+	})
+}
+
+// planMachine plans the machine and its data disks. actual and info are nil
+// when the machine doesn't exist yet.
+func (p *planner) planMachine(desired *Machine, actual *ovc.Machine, info *ovc.MachineInfo) error {
+	key := machineKey(desired.Name)
+	name := desired.Name
+
+	if actual == nil {
+		imageID, err := p.imageID(desired)
+		if err != nil {
+			return err
+		}
+		config := &ovc.MachineConfig{
+			Name:        desired.Name,
+			Description: desired.Description,
+			SizeID:      desired.SizeID,
+			Vcpus:       desired.Vcpus,
+			Memory:      desired.Memory,
+			ImageID:     imageID,
+			Disksize:    desired.Disksize,
+			Userdata:    desired.Userdata,
+		}
+		p.addCreate(&Step{
+			Action:    ActionCreate,
+			Resource:  ResourceMachine,
+			Name:      name,
+			Details:   machineSize(desired),
+			key:       key,
+			dependsOn: []string{cloudSpaceKey},
+			apply: func(c *ovc.Client, s *runState) error {
+				config.CloudspaceID = s.cloudSpaceID
+				id, err := c.Machines.Create(config)
+				if err != nil {
+					return err
+				}
+				s.machineIDs[name] = id
+				return nil
+			},
+		})
+		for _, disk := range desired.DataDisks {
+			p.planDiskCreate(name, disk)
+		}
+		return nil
+	}
+
+	if desired.ImageID != 0 && desired.ImageID != actual.ImageID {
+		p.warn("machine %s runs image %d, it has to be recreated to use image %d", name, actual.ImageID, desired.ImageID)
+	}
+
+	resize := (desired.SizeID != 0 && desired.SizeID != actual.SizeID) ||
+		(desired.SizeID == 0 && (desired.Vcpus != actual.Vcpus || desired.Memory != actual.Memory))
+	if resize {
+		config := &ovc.MachineConfig{
+			MachineID: strconv.Itoa(actual.ID),
+			SizeID:    desired.SizeID,
+			Vcpus:     desired.Vcpus,
+			Memory:    desired.Memory,
+		}
+		p.addCreate(&Step{
+			Action:   ActionUpdate,
+			Resource: ResourceMachine,
+			Name:     name,
+			Details:  fmt.Sprintf("resize from %d vcpus/%d MB to %s", actual.Vcpus, actual.Memory, machineSize(desired)),
+			key:      key,
+			apply: func(c *ovc.Client, s *runState) error {
+				_, err := c.Machines.Resize(config)
+				return err
+			},
+		})
+	}
+
+	if desired.Description != "" && (info.Description == nil || *info.Description != desired.Description) {
+		config := &ovc.MachineConfig{
+			MachineID:   strconv.Itoa(actual.ID),
+			Description: desired.Description,
+		}
+		p.addCreate(&Step{
+			Action:   ActionUpdate,
+			Resource: ResourceMachine,
+			Name:     name,
+			Details:  "description",
+			key:      key,
+			apply: func(c *ovc.Client, s *runState) error {
+				_, err := c.Machines.Update(config)
+				return err
+			},
+		})
+	}
+
+	actualDisks := make(map[string]ovc.MachineDisk)
+	for _, disk := range info.Disks {
+		if disk.Type == "D" {
+			actualDisks[disk.Name] = disk
+		}
+	}
+	declaredDisks := make(map[string]bool)
+	for _, disk := range desired.DataDisks {
+		declaredDisks[disk.Name] = true
+		actualDisk, ok := actualDisks[disk.Name]
+		switch {
+		case !ok:
+			p.planDiskCreate(name, disk)
+		case disk.Size < actualDisk.SizeMax:
+			p.warn("data disk %s of machine %s is %d GB, it can't shrink to %d GB", disk.Name, name, actualDisk.SizeMax, disk.Size)
+		case disk.Size > actualDisk.SizeMax:
+			config := &ovc.DiskConfig{DiskID: actualDisk.ID, Size: disk.Size}
+			p.addCreate(&Step{
+				Action:    ActionUpdate,
+				Resource:  ResourceDisk,
+				Name:      name + "/" + disk.Name,
+				Details:   fmt.Sprintf("resize from %d GB to %d GB", actualDisk.SizeMax, disk.Size),
+				key:       "disk/" + name + "/" + disk.Name,
+				dependsOn: []string{key},
+				apply: func(c *ovc.Client, s *runState) error {
+					return c.Disks.Resize(config)
+				},
+			})
+		}
+	}
+	if p.options.Prune {
+		for _, disk := range info.Disks {
+			if disk.Type != "D" || declaredDisks[disk.Name] {
+				continue
+			}
+			diskName := disk.Name
+			config := &ovc.DiskDeleteConfig{DiskID: disk.ID, Detach: true, Permanently: p.options.PermanentlyDelete}
+			p.addDelete(&Step{
+				Action:   ActionDelete,
+				Resource: ResourceDisk,
+				Name:     name + "/" + diskName,
+				key:      "disk/" + name + "/" + diskName,
+				apply: func(c *ovc.Client, s *runState) error {
+					return c.Disks.Delete(config)
+				},
+			})
+		}
+	}
+
+	return nil
+}
+
+func (p *planner) planDiskCreate(machineName string, disk DataDisk) {
+	config := &ovc.DiskConfig{
+		DiskName:    disk.Name,
+		Description: disk.Description,
+		Size:        disk.Size,
+		IOPS:        disk.IOPS,
+		Type:        "D",
+	}
+	p.addCreate(&Step{
+		Action:    ActionCreate,
+		Resource:  ResourceDisk,
+		Name:      machineName + "/" + disk.Name,
+		Details:   fmt.Sprintf("%d GB", disk.Size),
+		key:       "disk/" + machineName + "/" + disk.Name,
+		dependsOn: []string{machineKey(machineName)},
+		apply: func(c *ovc.Client, s *runState) error {
+			config.MachineID = s.machineIDs[machineName]
+			_, err := c.Disks.CreateAndAttach(config)
+			return err
+		},
+	})
+}
+
+// planPortForwards reconciles the port forwards of all declared machines
+func (p *planner) planPortForwards(actual []ovc.PortForwardingInfo) {
+	machineNames := make(map[int]string)
+	for name, id := range p.plan.state.machineIDs {
+		machineNames[id] = name
+	}
+
+	type forwardKey struct {
+		port     int
+		protocol string
+	}
+	actualByPort := make(map[forwardKey]ovc.PortForwardingInfo)
+	for _, pf := range actual {
+		actualByPort[forwardKey{pf.PublicPort, string(pf.Protocol)}] = pf
+	}
+
+	wanted := make(map[forwardKey]bool)
+	for _, machine := range p.doc.Machines {
+		for _, desired := range machine.PortForwards {
+			key := forwardKey{desired.PublicPort, strings.ToLower(desired.Protocol)}
+			wanted[key] = true
+			existing, ok := actualByPort[key]
+			if ok && machineNames[existing.MachineID] == machine.Name {
+				if existing.LocalPort != desired.LocalPort {
+					p.planPortForwardUpdate(machine.Name, desired)
+				}
+				continue
+			}
+			if ok && machineNames[existing.MachineID] == "" && !p.options.Prune {
+				p.warn("public port %d/%s is forwarded to unmanaged machine %s, enable pruning to take it over for %s",
+					key.port, key.protocol, existing.MachineName, machine.Name)
+				continue
+			}
+			if ok {
+				p.planPortForwardDelete(existing, machineNames[existing.MachineID])
+			}
+			p.planPortForwardCreate(machine.Name, desired)
+		}
+	}
+
+	for _, pf := range actual {
+		if wanted[forwardKey{pf.PublicPort, string(pf.Protocol)}] {
+			continue
+		}
+		if _, managed := machineNames[pf.MachineID]; managed || p.options.Prune {
+			p.planPortForwardDelete(pf, machineNames[pf.MachineID])
+		}
+	}
+}
+
+func portForwardName(machineName string, publicPort int, protocol string) string {
+	return fmt.Sprintf("%s/%d/%s", machineName, publicPort, strings.ToLower(protocol))
+}
+
+func (p *planner) planPortForwardCreate(machineName string, desired PortForward) {
+	name := portForwardName(machineName, desired.PublicPort, desired.Protocol)
+	p.addCreate(&Step{
+		Action:    ActionCreate,
+		Resource:  ResourcePortForward,
+		Name:      name,
+		Details:   fmt.Sprintf("to local port %d", desired.LocalPort),
+		key:       "portforward/" + name,
+		dependsOn: []string{machineKey(machineName), cloudSpaceKey},
+		apply: func(c *ovc.Client, s *runState) error {
+			_, err := c.Portforwards.Create(&ovc.PortForwardingConfig{
+				CloudspaceID: s.cloudSpaceID,
+				PublicIP:     s.publicIP,
+				PublicPort:   desired.PublicPort,
+				MachineID:    s.machineIDs[machineName],
+				LocalPort:    desired.LocalPort,
+				Protocol:     desired.Protocol,
+			})
+			return err
+		},
+	})
+}
+
+func (p *planner) planPortForwardUpdate(machineName string, desired PortForward) {
+	name := portForwardName(machineName, desired.PublicPort, desired.Protocol)
+	p.addCreate(&Step{
+		Action:    ActionUpdate,
+		Resource:  ResourcePortForward,
+		Name:      name,
+		Details:   fmt.Sprintf("to local port %d", desired.LocalPort),
+		key:       "portforward/" + name,
+		dependsOn: []string{machineKey(machineName)},
+		apply: func(c *ovc.Client, s *runState) error {
+			return c.Portforwards.Update(&ovc.PortForwardingConfig{
+				CloudspaceID:     s.cloudSpaceID,
+				SourcePublicIP:   s.publicIP,
+				SourcePublicPort: desired.PublicPort,
+				SourceProtocol:   desired.Protocol,
+				PublicIP:         s.publicIP,
+				PublicPort:       desired.PublicPort,
+				MachineID:        s.machineIDs[machineName],
+				LocalPort:        desired.LocalPort,
+				Protocol:         desired.Protocol,
+			})
+		},
+	})
+}
+
+func (p *planner) planPortForwardDelete(actual ovc.PortForwardingInfo, machineName string) {
+	if machineName == "" {
+		machineName = actual.MachineName
+	}
+	publicPort := actual.PublicPort
+	name := portForwardName(machineName, publicPort, string(actual.Protocol))
+	publicIP := actual.PublicIP
+	p.addDelete(&Step{
+		Action:   ActionDelete,
+		Resource: ResourcePortForward,
+		Name:     name,
+		key:      "portforward/" + name,
+		apply: func(c *ovc.Client, s *runState) error {
+			return c.Portforwards.DeleteByPort(publicPort, publicIP, s.cloudSpaceID)
+		},
+	})
+}
+
+func (p *planner) planTunnels(actual []ovc.IpsecInfo) {
+	actualByAddress := make(map[string]ovc.IpsecInfo, len(actual))
+	for _, tunnel := range actual {
+		actualByAddress[tunnel.RemoteAddr] = tunnel
+	}
+
+	declared := make(map[string]bool, len(p.doc.Tunnels))
+	for _, desired := range p.doc.Tunnels {
+		declared[desired.RemoteAddress] = true
+		existing, ok := actualByAddress[desired.RemoteAddress]
+		if ok {
+			if existing.RemotePrivateNetwork == desired.RemoteNetwork && (desired.PSK == "" || desired.PSK == existing.PSK) {
+				continue
+			}
+			p.planTunnelDelete(existing)
+		}
+		config := &ovc.IpsecConfig{
+			RemotePublicAddr:     desired.RemoteAddress,
+			RemotePrivateNetwork: desired.RemoteNetwork,
+			PskSecret:            desired.PSK,
+		}
+		p.addCreate(&Step{
+			Action:    ActionCreate,
+			Resource:  ResourceTunnel,
+			Name:      desired.RemoteAddress,
+			Details:   "remote network " + desired.RemoteNetwork,
+			key:       "tunnel/" + desired.RemoteAddress,
+			dependsOn: []string{cloudSpaceKey, "tunnel/" + desired.RemoteAddress + "/delete"},
+			apply: func(c *ovc.Client, s *runState) error {
+				config.CloudspaceID = s.cloudSpaceID
+				_, err := c.Ipsec.Create(config)
+				return err
+			},
+		})
+	}
+
+	if p.options.Prune {
+		for _, tunnel := range actual {
+			if !declared[tunnel.RemoteAddr] {
+				p.planTunnelDelete(tunnel)
+			}
+		}
+	}
+}
+
+func (p *planner) planTunnelDelete(actual ovc.IpsecInfo) {
+	config := &ovc.IpsecConfig{
+		RemotePublicAddr:     actual.RemoteAddr,
+		RemotePrivateNetwork: actual.RemotePrivateNetwork,
+	}
+	p.addDelete(&Step{
+		Action:   ActionDelete,
+		Resource: ResourceTunnel,
+		Name:     actual.RemoteAddr,
+		key:      "tunnel/" + actual.RemoteAddr + "/delete",
+		apply: func(c *ovc.Client, s *runState) error {
+			config.CloudspaceID = s.cloudSpaceID
+			return c.Ipsec.Delete(config)
+		},
+	})
+}
+
+// imageID resolves the image of a machine to be created
+func (p *planner) imageID(desired *Machine) (int, error) {
+	if desired.ImageID != 0 {
+		return desired.ImageID, nil
+	}
+	if p.images == nil {
+		images, err := p.client.Images.List(p.plan.state.accountID)
+		if err != nil {
+			return 0, err
+		}
+		p.images = make(map[string]int, len(*images))
+		for _, image := range *images {
+			p.images[image.Name] = image.ID
+		}
+	}
+	id, ok := p.images[desired.Image]
+	if !ok {
+		return 0, fmt.Errorf("Image %s of machine %s not found", desired.Image, desired.Name)
+	}
+	return id, nil
+}
+
+func machineSize(machine *Machine) string {
+	if machine.SizeID != 0 {
+		return fmt.Sprintf("size %d", machine.SizeID)
+	}
+	return fmt.Sprintf("%d vcpus/%d MB", machine.Vcpus, machine.Memory)
+}
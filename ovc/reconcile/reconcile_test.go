@@ -0,0 +1,153 @@
+package reconcile
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/gig-tech/ovc-sdk-go/v4/ovc"
+	"github.com/stretchr/testify/assert"
+)
+
+const testDocument = `
+cloudspace:
+  name: web
+  account: acme
+  limits:
+    maxCPUCapacity: 16
+machines:
+  - name: web-1
+    imageId: 7
+    sizeId: 2
+    dataDisks:
+      - name: data
+        size: 20
+    portForwards:
+      - publicPort: 80
+        localPort: 8080
+  - name: web-2
+    imageId: 7
+    sizeId: 2
+    portForwards:
+      - publicPort: 443
+        localPort: 443
+tunnels:
+  - remoteAddress: 1.2.3.4
+    remoteNetwork: 10.0.0.0/24
+`
+
+type fakeAccounts struct{ ovc.AccountService }
+
+func (fakeAccounts) GetIDByName(name string) (int, error) { return 1, nil }
+
+type fakeCloudSpaces struct{ ovc.CloudSpaceService }
+
+func (fakeCloudSpaces) List() (*[]ovc.CloudSpaceInfo, error) {
+	return &[]ovc.CloudSpaceInfo{{ID: 10, AccountID: 1, Name: "web"}}, nil
+}
+
+func (fakeCloudSpaces) Get(id int) (*ovc.CloudSpace, error) {
+	return &ovc.CloudSpace{ID: id, Name: "web", Externalnetworkip: "5.6.7.8/24", ResourceLimits: ovc.ResourceLimits{CUC: 8}}, nil
+}
+
+type fakeMachines struct {
+	ovc.MachineService
+	created []string
+}
+
+func (fakeMachines) List(cloudSpaceID int) (*[]ovc.Machine, error) {
+	return &[]ovc.Machine{{ID: 100, Name: "web-1", SizeID: 2, ImageID: 7}, {ID: 101, Name: "old", SizeID: 2}}, nil
+}
+
+func (fakeMachines) Get(id int) (*ovc.MachineInfo, error) {
+	return &ovc.MachineInfo{ID: id, Disks: []ovc.MachineDisk{{ID: 1, Name: "boot", Type: "B"}, {ID: 2, Name: "data", Type: "D", SizeMax: 10}}}, nil
+}
+
+func (m *fakeMachines) Create(config *ovc.MachineConfig) (int, error) {
+	m.created = append(m.created, config.Name)
+	return 0, errors.New("not enough resources")
+}
+
+type fakeForwards struct {
+	ovc.ForwardingService
+	updated []*ovc.PortForwardingConfig
+}
+
+func (f *fakeForwards) Update(config *ovc.PortForwardingConfig) error {
+	f.updated = append(f.updated, config)
+	return config.Validate()
+}
+
+func (*fakeForwards) List(*ovc.PortForwardingConfig) (*[]ovc.PortForwardingInfo, error) {
+	return &[]ovc.PortForwardingInfo{
+		{MachineID: 100, MachineName: "web-1", PublicIP: "5.6.7.8", PublicPort: 80, LocalPort: 80, Protocol: "tcp"},
+		{MachineID: 100, MachineName: "web-1", PublicIP: "5.6.7.8", PublicPort: 22, LocalPort: 22, Protocol: "tcp"},
+	}, nil
+}
+
+type fakeIpsec struct{ ovc.IpsecService }
+
+func (fakeIpsec) List(*ovc.IpsecConfig) (*[]ovc.IpsecInfo, error) {
+	return &[]ovc.IpsecInfo{{RemoteAddr: "1.2.3.4", RemotePrivateNetwork: "10.0.1.0/24"}}, nil
+}
+
+func TestPlanAndApply(t *testing.T) {
+	doc, err := ParseDocument([]byte(testDocument))
+	assert.NoError(t, err)
+
+	machines := &fakeMachines{}
+	forwards := &fakeForwards{}
+	client := &ovc.Client{
+		Accounts:     fakeAccounts{},
+		CloudSpaces:  fakeCloudSpaces{},
+		Machines:     machines,
+		Portforwards: forwards,
+		Ipsec:        fakeIpsec{},
+	}
+	reconciler := NewReconciler(client, Options{})
+	plan, err := reconciler.Plan(doc)
+	assert.NoError(t, err)
+
+	steps := []string{}
+	for _, step := range plan.Steps {
+		steps = append(steps, step.String())
+	}
+	assert.Equal(t, []string{
+		"- delete portforward web-1/22/tcp",
+		"- delete tunnel 1.2.3.4",
+		"~ update cloudspace web (vcpus 8 -> 16)",
+		"~ update disk web-1/data (resize from 10 GB to 20 GB)",
+		"+ create machine web-2 (size 2)",
+		"~ update portforward web-1/80/tcp (to local port 8080)",
+		"+ create portforward web-2/443/tcp (to local port 443)",
+		"+ create tunnel 1.2.3.4 (remote network 10.0.0.0/24)",
+	}, steps, "machine old should only be deleted when pruning")
+
+	// only run the machine creation and the steps depending on it
+	portForwardUpdate := plan.Steps[5]
+	plan.Steps = []*Step{plan.Steps[4], plan.Steps[6]}
+	result, err := reconciler.Apply(plan)
+	assert.Error(t, err)
+	assert.Equal(t, []string{"web-2"}, machines.created)
+	assert.Len(t, result.Failed, 1)
+	assert.Len(t, result.Skipped, 1)
+	assert.Empty(t, result.Applied)
+
+	plan.Steps = []*Step{portForwardUpdate}
+	_, err = reconciler.Apply(plan)
+	assert.NoError(t, err)
+	if assert.Len(t, forwards.updated, 1) {
+		assert.Equal(t, "5.6.7.8", forwards.updated[0].PublicIP, "the prefix length of the cloudspace IP should be stripped")
+	}
+}
+
+func TestParseDocumentValidation(t *testing.T) {
+	_, err := ParseDocument([]byte(`{"cloudspace": {"name": "web", "account": "acme"}, "machines": [{"name": "a", "imageId": 1}]}`))
+	assert.Error(t, err, "a machine without size should be rejected")
+
+	_, err = ParseDocument([]byte("cloudspace:\n  name: web\n  account: acme\n  unknown: field\n"))
+	assert.Error(t, err, "unknown fields should be rejected")
+
+	doc, err := ParseDocument([]byte(`{"cloudspace": {"name": "web", "account": "acme"}, "machines": [{"name": "a", "imageId": 1, "sizeId": 1, "portForwards": [{"publicPort": 22, "localPort": 22}]}]}`))
+	assert.NoError(t, err)
+	assert.Equal(t, "tcp", doc.Machines[0].PortForwards[0].Protocol)
+}
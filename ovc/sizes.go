@@ -20,6 +20,7 @@ type Size struct {
 type SizesService interface {
 	List(int) (*[]Size, error)
 	GetByVcpusAndMemory(int, int, int) (*Size, error)
+	Select(int, *SizeRequirements) (*SizeSelection, error)
 }
 
 // SizesServiceOp handles communication with the size related methods of the
@@ -0,0 +1,143 @@
+package ovc
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// SizeRequirements are the minimum resources of a machine used to select a
+// size
+type SizeRequirements struct {
+	Vcpus int
+	// Memory in MiB
+	Memory int
+	// BootDisk is the size of the boot disk in GB
+	BootDisk int
+	// AllowedVMSizes limits the sizes to these IDs, the allowed sizes of the
+	// cloudspace are used when empty
+	AllowedVMSizes []int
+	// Cost ranks the matching sizes, the cheapest is selected. DefaultSizeCost
+	// is used when nil.
+	Cost func(size *Size, bootDisk int) float64
+}
+
+// DefaultSizeCost weighs a vCPU as much as a GiB of memory and 100 GB of disk
+func DefaultSizeCost(size *Size, bootDisk int) float64 {
+	return float64(size.Vcpus) + float64(size.Memory)/1024 + float64(bootDisk)/100
+}
+
+// SizeRejection explains why a size was not selected
+type SizeRejection struct {
+	Size    Size
+	Reasons []string
+}
+
+// SizeSelection is a size selected for requirements
+type SizeSelection struct {
+	Size Size
+	// BootDisk is the smallest boot disk size of the size in GB meeting the
+	// requirements
+	BootDisk int
+	Cost     float64
+	// Rejected lists the other sizes with the reasons they weren't selected
+	Rejected []SizeRejection
+}
+
+// NoMatchingSizeError is returned when no size meets the requirements
+type NoMatchingSizeError struct {
+	Rejected []SizeRejection
+}
+
+func (e *NoMatchingSizeError) Error() string {
+	reasons := make([]string, len(e.Rejected))
+	for i, rejection := range e.Rejected {
+		reasons[i] = fmt.Sprintf("%s: %s", rejection.Size.Name, strings.Join(rejection.Reasons, ", "))
+	}
+	return "No size meets the requirements: " + strings.Join(reasons, "; ")
+}
+
+// reject returns the reasons a size doesn't meet the requirements and the
+// smallest boot disk size meeting them
+func (r *SizeRequirements) reject(size *Size, allowed map[int]bool) ([]string, int) {
+	reasons := []string{}
+	if len(allowed) != 0 && !allowed[size.ID] {
+		reasons = append(reasons, "not allowed in the cloudspace")
+	}
+	if size.Vcpus < r.Vcpus {
+		reasons = append(reasons, fmt.Sprintf("%d vCPUs, need %d", size.Vcpus, r.Vcpus))
+	}
+	if size.Memory < r.Memory {
+		reasons = append(reasons, fmt.Sprintf("%d MiB memory, need %d", size.Memory, r.Memory))
+	}
+	bootDisk := 0
+	for _, disk := range size.Disks {
+		if disk >= r.BootDisk && (bootDisk == 0 || disk < bootDisk) {
+			bootDisk = disk
+		}
+	}
+	if bootDisk == 0 {
+		reasons = append(reasons, fmt.Sprintf("no boot disk of %d GB", r.BootDisk))
+	}
+	return reasons, bootDisk
+}
+
+// Select returns the cheapest size of a cloudspace meeting the requirements,
+// with the reasons the other sizes were rejected. Sizes with equal cost are
+// ordered by ID.
+func (s *SizesServiceOp) Select(cloudspaceID int, requirements *SizeRequirements) (*SizeSelection, error) {
+	allowedIDs := requirements.AllowedVMSizes
+	if len(allowedIDs) == 0 {
+		cloudSpace, err := s.client.CloudSpaces.Get(cloudspaceID)
+		if err != nil {
+			return nil, err
+		}
+		allowedIDs = cloudSpace.AllowedVMSizes
+	}
+	allowed := make(map[int]bool, len(allowedIDs))
+	for _, id := range allowedIDs {
+		allowed[id] = true
+	}
+	cost := requirements.Cost
+	if cost == nil {
+		cost = DefaultSizeCost
+	}
+
+	sizes, err := s.List(cloudspaceID)
+	if err != nil {
+		return nil, err
+	}
+	sorted := append([]Size{}, *sizes...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].ID < sorted[j].ID })
+
+	candidates := []SizeSelection{}
+	rejected := []SizeRejection{}
+	for i := range sorted {
+		size := &sorted[i]
+		reasons, bootDisk := requirements.reject(size, allowed)
+		if len(reasons) != 0 {
+			rejected = append(rejected, SizeRejection{Size: *size, Reasons: reasons})
+			continue
+		}
+		candidates = append(candidates, SizeSelection{Size: *size, BootDisk: bootDisk, Cost: cost(size, bootDisk)})
+	}
+	if len(candidates) == 0 {
+		return nil, &NoMatchingSizeError{Rejected: rejected}
+	}
+
+	selected := &candidates[0]
+	for i := range candidates {
+		if candidates[i].Cost < selected.Cost {
+			selected = &candidates[i]
+		}
+	}
+	for _, candidate := range candidates {
+		if candidate.Size.ID != selected.Size.ID {
+			rejected = append(rejected, SizeRejection{Size: candidate.Size, Reasons: []string{
+				fmt.Sprintf("costs %g, %s costs %g", candidate.Cost, selected.Size.Name, selected.Cost),
+			}})
+		}
+	}
+	selected.Rejected = rejected
+	return selected, nil
+}
@@ -0,0 +1,49 @@
+package ovc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSelectSize(t *testing.T) {
+	client, stop := newTestClient(t, func(endpoint string, params map[string]interface{}) (interface{}, bool) {
+		switch endpoint {
+		case "/cloudapi/cloudspaces/get":
+			return map[string]interface{}{"id": 3, "allowedVMSizes": []int{1, 2, 3}}, true
+		case "/cloudapi/sizes/list":
+			return []map[string]interface{}{
+				{"id": 4, "name": "huge", "vcpus": 8, "memory": 16384, "disks": []int{10, 100}},
+				{"id": 3, "name": "large", "vcpus": 4, "memory": 8192, "disks": []int{10, 50, 100}},
+				{"id": 2, "name": "medium", "vcpus": 2, "memory": 4096, "disks": []int{10, 20}},
+				{"id": 1, "name": "small", "vcpus": 1, "memory": 2048, "disks": []int{10, 50}},
+			}, true
+		}
+		return "unexpected call to " + endpoint, false
+	})
+	defer stop()
+	client.CloudSpaces = &CloudSpaceServiceOp{client: client}
+	client.Sizes = &SizesServiceOp{client: client}
+
+	selection, err := client.Sizes.Select(3, &SizeRequirements{Vcpus: 2, Memory: 2048, BootDisk: 20})
+	assert.NoError(t, err)
+	assert.Equal(t, "medium", selection.Size.Name)
+	assert.Equal(t, 20, selection.BootDisk)
+	assert.Equal(t, []SizeRejection{
+		{Size: Size{ID: 1, Name: "small", Vcpus: 1, Memory: 2048, Disks: []int{10, 50}}, Reasons: []string{"1 vCPUs, need 2"}},
+		{Size: Size{ID: 4, Name: "huge", Vcpus: 8, Memory: 16384, Disks: []int{10, 100}}, Reasons: []string{"not allowed in the cloudspace"}},
+		{Size: Size{ID: 3, Name: "large", Vcpus: 4, Memory: 8192, Disks: []int{10, 50, 100}}, Reasons: []string{"costs 12.5, medium costs 6.2"}},
+	}, selection.Rejected)
+
+	// prefer the fewest vCPUs, disks are cheap
+	selection, err = client.Sizes.Select(3, &SizeRequirements{Vcpus: 1, BootDisk: 50, AllowedVMSizes: []int{1, 3, 4},
+		Cost: func(size *Size, bootDisk int) float64 { return float64(size.Vcpus) }})
+	assert.NoError(t, err)
+	assert.Equal(t, "small", selection.Size.Name)
+	assert.Equal(t, 50, selection.BootDisk)
+
+	_, err = client.Sizes.Select(3, &SizeRequirements{Vcpus: 16})
+	if assert.IsType(t, &NoMatchingSizeError{}, err) {
+		assert.Len(t, err.(*NoMatchingSizeError).Rejected, 4)
+	}
+}
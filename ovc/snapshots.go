@@ -0,0 +1,116 @@
+package ovc
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Snapshot is a point-in-time snapshot of the disks of a machine
+type Snapshot struct {
+	Name string `json:"name"`
+	// Epoch is the creation time of the snapshot in seconds since the Unix
+	// epoch, it identifies the snapshot in rollback and delete calls
+	Epoch int `json:"epoch"`
+	// Disks are the IDs of the disks included in the snapshot
+	Disks []int `json:"disks"`
+}
+
+// Time returns the creation time of the snapshot
+func (s *Snapshot) Time() time.Time {
+	return time.Unix(int64(s.Epoch), 0)
+}
+
+// machinePollInterval is the time between polls when waiting for a machine
+var machinePollInterval = 2 * time.Second
+
+// Snapshot takes a snapshot of all disks of a machine
+func (s *MachineServiceOp) Snapshot(id int, name string) (*Snapshot, error) {
+	machineMap := make(map[string]interface{})
+	machineMap["machineId"] = id
+	machineMap["name"] = name
+
+	_, err := s.client.Post("/cloudapi/machines/snapshot", machineMap, DataActionTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshots, err := s.ListSnapshots(id)
+	if err != nil {
+		return nil, err
+	}
+	// the most recent snapshot with the name is the one just taken
+	for i := len(*snapshots) - 1; i >= 0; i-- {
+		if (*snapshots)[i].Name == name {
+			return &(*snapshots)[i], nil
+		}
+	}
+	return nil, fmt.Errorf("Snapshot %s of machine %d not found after creating it", name, id)
+}
+
+// ListSnapshots lists the snapshots of a machine, oldest first
+func (s *MachineServiceOp) ListSnapshots(id int) (*[]Snapshot, error) {
+	machineMap := make(map[string]interface{})
+	machineMap["machineId"] = id
+
+	body, err := s.client.Post("/cloudapi/machines/listSnapshots", machineMap, ModelActionTimeout)
+	if err != nil {
+		return nil, err
+	}
+	snapshots := new([]Snapshot)
+	err = json.Unmarshal(body, &snapshots)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(*snapshots, func(i, j int) bool { return (*snapshots)[i].Epoch < (*snapshots)[j].Epoch })
+
+	return snapshots, nil
+}
+
+// RollbackSnapshot rolls the disks of a machine back to the snapshot taken at
+// epoch and waits for the machine to be halted again. The machine needs to be
+// stopped first.
+func (s *MachineServiceOp) RollbackSnapshot(id int, epoch int) error {
+	machineMap := make(map[string]interface{})
+	machineMap["machineId"] = id
+	machineMap["epoch"] = epoch
+
+	_, err := s.client.Post("/cloudapi/machines/rollbackSnapshot", machineMap, DataActionTimeout)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.waitForStatus(id, DataActionTimeout, MachineStatusHalted)
+	return err
+}
+
+// DeleteSnapshot deletes the snapshot of a machine taken at epoch
+func (s *MachineServiceOp) DeleteSnapshot(id int, epoch int) error {
+	machineMap := make(map[string]interface{})
+	machineMap["machineId"] = id
+	machineMap["epoch"] = epoch
+
+	_, err := s.client.Post("/cloudapi/machines/deleteSnapshot", machineMap, OperationalActionTimeout)
+	return err
+}
+
+// waitForStatus polls a machine until it has one of the given statuses
+func (s *MachineServiceOp) waitForStatus(id int, timeout ResponseTimeout, statuses ...string) (*MachineInfo, error) {
+	deadline := time.Now().Add(time.Duration(timeout))
+	for {
+		machine, err := s.client.Machines.Get(id)
+		if err != nil {
+			return nil, err
+		}
+		for _, status := range statuses {
+			if machine.Status == status {
+				return machine, nil
+			}
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("Timeout waiting for machine %d to become %v, status is %s", id, statuses, machine.Status)
+		}
+		time.Sleep(machinePollInterval)
+	}
+}
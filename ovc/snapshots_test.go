@@ -0,0 +1,52 @@
+package ovc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnapshots(t *testing.T) {
+	machinePollInterval = time.Millisecond
+	defer func() { machinePollInterval = 2 * time.Second }()
+
+	gets := 0
+	client, stop := newTestClient(t, func(endpoint string, params map[string]interface{}) (interface{}, bool) {
+		switch endpoint {
+		case "/cloudapi/machines/snapshot":
+			return "pre-upgrade", true
+		case "/cloudapi/machines/listSnapshots":
+			return []map[string]interface{}{
+				{"name": "pre-upgrade", "epoch": 1560000300, "disks": []int{1, 2}},
+				{"name": "nightly", "epoch": 1560000000, "disks": []int{1, 2}},
+				{"name": "pre-upgrade", "epoch": 1550000000, "disks": []int{1}},
+			}, true
+		case "/cloudapi/machines/rollbackSnapshot":
+			if params["epoch"] != float64(1560000000) {
+				return "wrong epoch", false
+			}
+			return true, true
+		case "/cloudapi/machines/get":
+			gets++
+			if gets < 3 {
+				return map[string]interface{}{"id": 5, "status": "ROLLING_BACK"}, true
+			}
+			return map[string]interface{}{"id": 5, "status": MachineStatusHalted}, true
+		}
+		return "unexpected call to " + endpoint, false
+	})
+	defer stop()
+
+	snapshots, err := client.Machines.ListSnapshots(5)
+	assert.NoError(t, err)
+	assert.Equal(t, "nightly", (*snapshots)[1].Name)
+	assert.Equal(t, 1550000000, (*snapshots)[0].Epoch)
+
+	snapshot, err := client.Machines.Snapshot(5, "pre-upgrade")
+	assert.NoError(t, err)
+	assert.Equal(t, &Snapshot{Name: "pre-upgrade", Epoch: 1560000300, Disks: []int{1, 2}}, snapshot)
+
+	assert.NoError(t, client.Machines.RollbackSnapshot(5, 1560000000))
+	assert.Equal(t, 3, gets)
+}
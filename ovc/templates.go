@@ -1,26 +1,54 @@
 package ovc
 
 import (
-	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
 )
 
-// Template is a list of templates
+// Template is an image machines can be created from
 // Returned when using the List method
-type Template struct {
-	Username    interface{} `json:"username"`
-	Status      string      `json:"status"`
-	Description string      `json:"description"`
-	Name        string      `json:"name"`
-	Size        int         `json:"size"`
-	Type        string      `json:"type"`
-	ID          int         `json:"id"`
-	AccountID   int         `json:"accountId"`
+type Template = ImageInfo
+
+// TemplateFilter selects templates in Search, empty fields match any
+type TemplateFilter struct {
+	// Type is the OS type, e.g. Linux or Windows
+	Type string
+	// Name is a shell pattern as used by path.Match, e.g. "Ubuntu 18*"
+	Name     string
+	Status   string
+	BootType string
+}
+
+// ImageUser is a machine created from an image
+type ImageUser struct {
+	MachineID    int
+	MachineName  string
+	CloudSpaceID int
+}
+
+// AmbiguousNameError is returned when looking up an image by a name used by
+// several images
+type AmbiguousNameError struct {
+	Name string
+	IDs  []int
+}
+
+func (e *AmbiguousNameError) Error() string {
+	ids := make([]string, len(e.IDs))
+	for i, id := range e.IDs {
+		ids[i] = fmt.Sprint(id)
+	}
+	return fmt.Sprintf("Image name %s is ambiguous, it is used by images %s", e.Name, strings.Join(ids, ", "))
 }
 
 // TemplateService is an interface for interfacing with the Images
 // endpoints of the OVC API
 type TemplateService interface {
 	List(int) (*[]Template, error)
+	Search(int, *TemplateFilter) ([]Template, error)
+	GetByName(string, int) (*Template, error)
+	UsedBy(int) (map[int][]ImageUser, error)
 }
 
 // TemplateServiceOp handles communication with the image related methods of the
@@ -29,21 +57,105 @@ type TemplateServiceOp struct {
 	client *Client
 }
 
-// List all images
+// List all images available to an account
 func (s *TemplateServiceOp) List(accountID int) (*[]Template, error) {
-	templateMap := make(map[string]interface{})
-	templateMap["accountId"] = 4
+	return s.client.Images.List(accountID)
+}
+
+// matches reports whether a template is selected by the filter
+func (f *TemplateFilter) matches(template *Template) (bool, error) {
+	if f.Type != "" && !strings.EqualFold(template.Type, f.Type) {
+		return false, nil
+	}
+	if f.Status != "" && !strings.EqualFold(template.Status, f.Status) {
+		return false, nil
+	}
+	if f.BootType != "" && !strings.EqualFold(template.BootType, f.BootType) {
+		return false, nil
+	}
+	if f.Name != "" {
+		return path.Match(strings.ToLower(f.Name), strings.ToLower(template.Name))
+	}
+	return true, nil
+}
 
-	body, err := s.client.Post("/cloudapi/images/list", templateMap, ModelActionTimeout)
+// Search returns the images available to an account selected by filter,
+// matching case-insensitively
+func (s *TemplateServiceOp) Search(accountID int, filter *TemplateFilter) ([]Template, error) {
+	templates, err := s.List(accountID)
 	if err != nil {
 		return nil, err
 	}
+	if filter == nil {
+		filter = &TemplateFilter{}
+	}
+	found := []Template{}
+	for _, template := range *templates {
+		ok, err := filter.matches(&template)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			found = append(found, template)
+		}
+	}
+
+	return found, nil
+}
+
+// GetByName returns an image available to an account by name, it fails with
+// an AmbiguousNameError when several images have the name
+func (s *TemplateServiceOp) GetByName(name string, accountID int) (*Template, error) {
+	templates, err := s.List(accountID)
+	if err != nil {
+		return nil, err
+	}
+	return findImageByName(*templates, name)
+}
 
-	templates := new([]Template)
-	err = json.Unmarshal(body, &templates)
+// findImageByName returns the only image with a name
+func findImageByName(images []ImageInfo, name string) (*ImageInfo, error) {
+	var found *ImageInfo
+	ids := []int{}
+	for i := range images {
+		if images[i].Name == name {
+			found = &images[i]
+			ids = append(ids, found.ID)
+		}
+	}
+	switch len(ids) {
+	case 0:
+		return nil, fmt.Errorf("Image %s not found", name)
+	case 1:
+		return found, nil
+	}
+	return nil, &AmbiguousNameError{Name: name, IDs: ids}
+}
+
+// UsedBy returns the machines of the cloudspaces of an account by the ID of
+// the image they were created from
+func (s *TemplateServiceOp) UsedBy(accountID int) (map[int][]ImageUser, error) {
+	cloudSpaces, err := s.client.CloudSpaces.List()
 	if err != nil {
 		return nil, err
 	}
+	users := make(map[int][]ImageUser)
+	for _, cs := range *cloudSpaces {
+		if cs.AccountID != accountID {
+			continue
+		}
+		machines, err := s.client.Machines.List(cs.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, machine := range *machines {
+			users[machine.ImageID] = append(users[machine.ImageID], ImageUser{
+				MachineID:    machine.ID,
+				MachineName:  machine.Name,
+				CloudSpaceID: cs.ID,
+			})
+		}
+	}
 
-	return templates, nil
+	return users, nil
 }
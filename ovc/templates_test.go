@@ -0,0 +1,53 @@
+package ovc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTemplateCatalog(t *testing.T) {
+	client, stop := newTestClient(t, func(endpoint string, params map[string]interface{}) (interface{}, bool) {
+		switch endpoint {
+		case "/cloudapi/images/list":
+			assert.Equal(t, float64(7), params["accountId"])
+			return []map[string]interface{}{
+				{"id": 1, "name": "Ubuntu 18.04", "type": "Linux", "status": "CREATED", "bootType": "bios"},
+				{"id": 2, "name": "Ubuntu 16.04", "type": "Linux", "status": "DISABLED", "bootType": "bios"},
+				{"id": 3, "name": "Windows 2016", "type": "Windows", "status": "CREATED", "bootType": "uefi"},
+				{"id": 4, "name": "Windows 2016", "type": "Windows", "status": "CREATED", "bootType": "bios"},
+			}, true
+		case "/cloudapi/cloudspaces/list":
+			return []map[string]interface{}{{"id": 3, "accountId": 7}, {"id": 4, "accountId": 8}}, true
+		case "/cloudapi/machines/list":
+			assert.Equal(t, float64(3), params["cloudspaceId"])
+			return []map[string]interface{}{{"id": 10, "name": "web", "imageId": 1}, {"id": 11, "name": "db", "imageId": 1}}, true
+		}
+		return "unexpected call to " + endpoint, false
+	})
+	defer stop()
+	client.CloudSpaces = &CloudSpaceServiceOp{client: client}
+	client.Images = &ImageServiceOp{client: client}
+	client.Templates = &TemplateServiceOp{client: client}
+
+	templates, err := client.Templates.Search(7, &TemplateFilter{Type: "linux", Name: "ubuntu*", Status: ImageStatusCreated})
+	assert.NoError(t, err)
+	if assert.Len(t, templates, 1) {
+		assert.Equal(t, 1, templates[0].ID)
+	}
+	templates, err = client.Templates.Search(7, &TemplateFilter{BootType: "UEFI"})
+	assert.NoError(t, err)
+	assert.Len(t, templates, 1)
+
+	template, err := client.Templates.GetByName("Ubuntu 16.04", 7)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, template.ID)
+	_, err = client.Templates.GetByName("Windows 2016", 7)
+	if assert.IsType(t, &AmbiguousNameError{}, err) {
+		assert.Equal(t, []int{3, 4}, err.(*AmbiguousNameError).IDs)
+	}
+
+	users, err := client.Templates.UsedBy(7)
+	assert.NoError(t, err)
+	assert.Equal(t, map[int][]ImageUser{1: {{MachineID: 10, MachineName: "web", CloudSpaceID: 3}, {MachineID: 11, MachineName: "db", CloudSpaceID: 3}}}, users)
+}
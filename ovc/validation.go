@@ -0,0 +1,94 @@
+package ovc
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// ValidationError is a problem with a single field of a config struct, Field
+// is the JSON name of the field as sent to the API
+type ValidationError struct {
+	Field   string
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+// ValidationErrors holds all problems found when validating a config struct.
+// Validate methods return either nil or a non-empty ValidationErrors.
+type ValidationErrors []*ValidationError
+
+func (e ValidationErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return "Invalid configuration: " + strings.Join(messages, "; ")
+}
+
+// validator collects validation errors
+type validator struct {
+	errors ValidationErrors
+}
+
+func (v *validator) add(field string, format string, args ...interface{}) {
+	v.errors = append(v.errors, &ValidationError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) required(field string, value string) {
+	if strings.TrimSpace(value) == "" {
+		v.add(field, "is required")
+	}
+}
+
+func (v *validator) requiredID(field string, id int) {
+	if id <= 0 {
+		v.add(field, "is required")
+	}
+}
+
+func (v *validator) positive(field string, value int) {
+	if value <= 0 {
+		v.add(field, "must be greater than 0, got %d", value)
+	}
+}
+
+func (v *validator) port(field string, port int, optional bool) {
+	if optional && port == 0 {
+		return
+	}
+	if port < 1 || port > 65535 {
+		v.add(field, "must be a port between 1 and 65535, got %d", port)
+	}
+}
+
+func (v *validator) ip(field string, ip string) {
+	if net.ParseIP(ip) == nil {
+		v.add(field, "must be an IP address, got %q", ip)
+	}
+}
+
+func (v *validator) cidr(field string, network string) {
+	if _, _, err := net.ParseCIDR(network); err != nil {
+		v.add(field, "must be a network in CIDR notation, got %q", network)
+	}
+}
+
+func (v *validator) oneOf(field string, value string, allowed ...string) {
+	for _, a := range allowed {
+		if value == a {
+			return
+		}
+	}
+	v.add(field, "must be one of %s, got %q", strings.Join(allowed, ", "), value)
+}
+
+func (v *validator) err() error {
+	if len(v.errors) == 0 {
+		return nil
+	}
+	return v.errors
+}
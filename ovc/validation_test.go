@@ -0,0 +1,38 @@
+package ovc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidate(t *testing.T) {
+	assert.NoError(t, (&MachineConfig{CloudspaceID: 1, Name: "vm", ImageID: 2, SizeID: 3, Disksize: 10, DataDisks: []interface{}{20}}).Validate())
+	assert.NoError(t, (&PortForwardingConfig{CloudspaceID: 1, MachineID: 2, PublicIP: "185.15.201.114", LocalPort: 22, Protocol: "tcp"}).Validate())
+	for _, protocol := range []string{"", "TCP", "Udp"} {
+		assert.NoError(t, (&PortForwardingConfig{CloudspaceID: 1, MachineID: 2, PublicIP: "185.15.201.114", LocalPort: 22, Protocol: protocol}).Validate(),
+			"protocol %q was accepted by the G8 before validation", protocol)
+	}
+	cloudSpace := &CloudSpace{Externalnetworkip: "185.15.201.114/24"}
+	assert.Equal(t, "185.15.201.114", cloudSpace.PublicIP())
+	assert.NoError(t, (&PortForwardingConfig{CloudspaceID: 1, MachineID: 2, PublicIP: cloudSpace.PublicIP(), LocalPort: 22}).Validate(),
+		"the external IP of a cloudspace in CIDR notation should be usable once stripped")
+	assert.NoError(t, (&DiskConfig{MachineID: 2, DiskName: "data", Size: 10, Type: "D"}).Validate())
+
+	err := (&EmptyMachineConfig{Name: "vm", Memory: 1024, Vcpus: 1, Disksize: 10, Imagetype: "Plan9"}).Validate()
+	if assert.IsType(t, ValidationErrors{}, err) {
+		errs := err.(ValidationErrors)
+		assert.Len(t, errs, 2)
+		assert.Equal(t, "cloudspaceId", errs[0].Field)
+		assert.Equal(t, "imagetype", errs[1].Field)
+	}
+
+	err = (&PortForwardingConfig{CloudspaceID: 1, MachineID: 2, PublicIP: "185.15.201.114", PublicPort: 70000, LocalPort: 22, Protocol: "icmp"}).Validate()
+	assert.EqualError(t, err, `Invalid configuration: publicPort: must be a port between 1 and 65535, got 70000; protocol: must be one of tcp, udp, got "icmp"`)
+
+	err = (&IpsecConfig{CloudspaceID: 1, RemotePublicAddr: "1.2.3.4", RemotePrivateNetwork: "10.0.0.0"}).Validate()
+	assert.EqualError(t, err, `Invalid configuration: remotePrivateNetwork: must be a network in CIDR notation, got "10.0.0.0"`)
+
+	err = (&CloudSpaceConfig{AccountID: 1, Name: "cs", Location: "be-g8-3", PrivateNetwork: "192.168.300.0/24"}).Validate()
+	assert.Error(t, err)
+}